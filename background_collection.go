@@ -0,0 +1,150 @@
+/*
+ *
+ * title           :background_collection.go
+ * description     :Collection of the metrics on the exporter schedule, into
+ *                  a snapshot served by /metrics without querying Cloudera
+ *                  Manager on every scrape
+ * author          :Enes Erdoğan
+ * date            :2025/08/04
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "sync/atomic"
+  "time"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Gatherer of the metrics of the last background collection in the scope
+type snapshot_gatherer struct {
+  scope cl.Scrape_scope
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Metrics of the last background collection ([]*dto.MetricFamily)
+var collection_snapshot atomic.Value
+
+// Whether /metrics serves the background collections, set at startup
+var background_collection = false
+
+var (
+  background_collections_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "background_collections_total",
+    Help:      "Total number of background collections of the metrics.",
+  })
+  background_collection_errors_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "background_collection_errors_total",
+    Help:      "Total number of background collections that failed to gather the metrics.",
+  })
+  snapshot_timestamp_seconds = prometheus.NewGauge(prometheus.GaugeOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "snapshot_timestamp_seconds",
+    Help:      "Timestamp of the background collection served by /metrics.",
+  })
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Gather implements prometheus.Gatherer. Nothing is returned until the
+// first collection finishes
+func (g snapshot_gatherer) Gather() ([]*dto.MetricFamily, error) {
+  families, _ := collection_snapshot.Load().([]*dto.MetricFamily)
+  if g.scope.Is_empty() {
+    return families, nil
+  }
+
+  // The snapshot is shared by the scrapes, so the families in the scope are
+  // copied
+  scoped := make([]*dto.MetricFamily, 0, len(families))
+  for _, family := range families {
+    metrics := make([]*dto.Metric, 0, len(family.GetMetric()))
+    for _, metric := range family.GetMetric() {
+      labels := make(map[string]string, len(metric.GetLabel()))
+      for _, label := range metric.GetLabel() {
+        labels[label.GetName()] = label.GetValue()
+      }
+      if g.scope.Includes_labels(labels) {
+        metrics = append(metrics, metric)
+      }
+    }
+    if len(metrics) > 0 {
+      scoped = append(scoped, &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type, Metric: metrics})
+    }
+  }
+  return scoped, nil
+}
+
+
+// Collect the metrics with the current configuration and keep them as the
+// snapshot. The metrics gathered with errors are kept too, as a scrape
+// would expose them
+func collect_snapshot(interval time.Duration, metrics cl.Metrics) {
+  // The collection has the interval to finish, as a scrape has its timeout
+  ctx, cancel := context.WithTimeout(shutdown_ctx, interval)
+  defer cancel()
+  state := get_serving_state()
+  families, err := collect_once(ctx, state.config, metrics, state.scrapers)
+  if err != nil {
+    log.Err_msg("Background collection failed: %s", err)
+    background_collection_errors_total.Inc()
+  }
+  if len(families) > 0 {
+    collection_snapshot.Store(families)
+    snapshot_timestamp_seconds.Set(float64(time.Now().Unix()))
+    record_cardinality(families)
+  }
+}
+
+
+// Collect the metrics on every interval. The collections don't overlap: if
+// one takes longer than the interval, the next starts when it finishes
+func background_collection_loop(interval time.Duration) {
+  prometheus.MustRegister(background_collections_total, background_collection_errors_total, snapshot_timestamp_seconds)
+  metrics := cl.NewMetrics()
+
+  log.Info_msg("Collecting the metrics every %s", interval)
+  ticker := time.NewTicker(interval)
+  defer ticker.Stop()
+  for {
+    background_collections_total.Inc()
+    collect_snapshot(interval, metrics)
+    <-ticker.C
+  }
+}
@@ -0,0 +1,80 @@
+/*
+ *
+ * title           :buildinfo/buildinfo.go
+ * description     :Build information of the running binary: version, target
+ *                  platform, CGO state and variant (standard or FIPS)
+ * author          :Enes Erdoğan
+ * date            :2025/08/25
+ * version         :1.0
+ *
+ */
+package buildinfo
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "runtime"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/common/version"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Variants of the binary, selected with the fips build tag
+const (
+  VARIANT_STANDARD = "standard"
+  VARIANT_FIPS = "fips"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Build information of the binary
+type Info struct {
+  Version string `json:"version"`
+  Revision string `json:"revision"`
+  Branch string `json:"branch"`
+  Build_user string `json:"build_user"`
+  Build_date string `json:"build_date"`
+  Go_version string `json:"go_version"`
+  Os string `json:"os"`
+  Arch string `json:"arch"`
+  Cgo_enabled bool `json:"cgo_enabled"`
+  Variant string `json:"variant"`
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the build information of the running binary. The version fields
+// are the ones of the prometheus/common/version package
+func Get() Info {
+  return Info {
+    Version: version.Version,
+    Revision: version.Revision,
+    Branch: version.Branch,
+    Build_user: version.BuildUser,
+    Build_date: version.BuildDate,
+    Go_version: runtime.Version(),
+    Os: runtime.GOOS,
+    Arch: runtime.GOARCH,
+    Cgo_enabled: cgo_enabled,
+    Variant: variant,
+  }
+}
@@ -0,0 +1,14 @@
+// +build cgo
+
+/*
+ *
+ * title           :buildinfo/cgo.go
+ * description     :CGO state of the binaries built with CGO
+ * author          :Enes Erdoğan
+ * date            :2025/08/25
+ * version         :1.0
+ *
+ */
+package buildinfo
+
+const cgo_enabled = true
@@ -0,0 +1,15 @@
+// +build fips
+
+/*
+ *
+ * title           :buildinfo/fips.go
+ * description     :Variant of the binaries built with the fips tag, linked
+ *                  against a FIPS validated crypto module
+ * author          :Enes Erdoğan
+ * date            :2025/08/25
+ * version         :1.0
+ *
+ */
+package buildinfo
+
+const variant = VARIANT_FIPS
@@ -0,0 +1,14 @@
+// +build !cgo
+
+/*
+ *
+ * title           :buildinfo/nocgo.go
+ * description     :CGO state of the binaries built without CGO
+ * author          :Enes Erdoğan
+ * date            :2025/08/25
+ * version         :1.0
+ *
+ */
+package buildinfo
+
+const cgo_enabled = false
@@ -0,0 +1,14 @@
+// +build !fips
+
+/*
+ *
+ * title           :buildinfo/standard.go
+ * description     :Variant of the binaries built without the fips tag
+ * author          :Enes Erdoğan
+ * date            :2025/08/25
+ * version         :1.0
+ *
+ */
+package buildinfo
+
+const variant = VARIANT_STANDARD
@@ -0,0 +1,146 @@
+/*
+ *
+ * title           :cardinality.go
+ * description     :Report of the number of series per metric and per label
+ *                  of the last collection, in /debug/cardinality
+ * author          :Enes Erdoğan
+ * date            :2025/07/21
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "encoding/json"
+  "net/http"
+  "sort"
+  "sync/atomic"
+  "time"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Cardinality of a metric: its series and the distinct values of each label
+type metric_cardinality struct {
+  Name string `json:"name"`
+  Series int `json:"series"`
+  Labels map[string]int `json:"labels"`
+}
+
+// Cardinality of the last collection, with the metrics sorted by series
+type cardinality_report struct {
+  Collected_at time.Time `json:"collected_at"`
+  Total_series int `json:"total_series"`
+  Metrics []metric_cardinality `json:"metrics"`
+}
+
+// Gatherer that records the cardinality of the gathered metrics
+type cardinality_gatherer struct {
+  prometheus.Gatherer
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Report of the last collection (*cardinality_report)
+var last_cardinality atomic.Value
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the number of series of a metric as exposed to Prometheus: a
+// summary has its quantiles plus the sum and count, and a histogram its
+// buckets plus +Inf, the sum and the count
+func count_series(metric *dto.Metric) int {
+  switch {
+  case metric.Summary != nil:
+    return len(metric.Summary.GetQuantile()) + 2
+  case metric.Histogram != nil:
+    return len(metric.Histogram.GetBucket()) + 3
+  }
+  return 1
+}
+
+
+// Returns the cardinality report of the metric families
+func new_cardinality_report(families []*dto.MetricFamily) *cardinality_report {
+  report := &cardinality_report{Collected_at: time.Now(), Metrics: make([]metric_cardinality, 0, len(families))}
+  for _, family := range families {
+    cardinality := metric_cardinality{Name: family.GetName(), Labels: map[string]int{}}
+    label_values := map[string]map[string]bool{}
+    for _, metric := range family.GetMetric() {
+      cardinality.Series += count_series(metric)
+      for _, label := range metric.GetLabel() {
+        if label_values[label.GetName()] == nil {
+          label_values[label.GetName()] = map[string]bool{}
+        }
+        label_values[label.GetName()][label.GetValue()] = true
+      }
+    }
+    for name, values := range label_values {
+      cardinality.Labels[name] = len(values)
+    }
+    report.Total_series += cardinality.Series
+    report.Metrics = append(report.Metrics, cardinality)
+  }
+  sort.Slice(report.Metrics, func(i, j int) bool {
+    if report.Metrics[i].Series != report.Metrics[j].Series {
+      return report.Metrics[i].Series > report.Metrics[j].Series
+    }
+    return report.Metrics[i].Name < report.Metrics[j].Name
+  })
+  return report
+}
+
+
+// Record the cardinality of the collected metrics
+func record_cardinality(families []*dto.MetricFamily) {
+  if len(families) > 0 {
+    last_cardinality.Store(new_cardinality_report(families))
+  }
+}
+
+
+// Gather implements prometheus.Gatherer. The metrics gathered with errors are
+// recorded too, as they are exposed
+func (g cardinality_gatherer) Gather() ([]*dto.MetricFamily, error) {
+  families, err := g.Gatherer.Gather()
+  record_cardinality(families)
+  return families, err
+}
+
+
+// Create and returns a Handler that reports the cardinality of the last
+// collection (scrape or OTLP push)
+func newCardinalityHandler() http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    report, ok := last_cardinality.Load().(*cardinality_report)
+    if !ok {
+      http.Error(w, "No metrics collected yet", http.StatusServiceUnavailable)
+      return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(report)
+  }
+}
@@ -25,9 +25,11 @@ import (
   "runtime"
   "fmt"
   "strings"
+  "encoding/json"
 
 
   // Own libraries
+  "keedio/cloudera_exporter/buildinfo"
   cl "keedio/cloudera_exporter/collector"
   cp "keedio/cloudera_exporter/config_parser"
   log "keedio/cloudera_exporter/logger"
@@ -52,6 +54,10 @@ import (
  // Exporter Configuration Struct
 var config *cp.CE_config
 
+// Read the config file and apply the execution flags. Set with the flags, so
+// the configuration can be reloaded
+var load_config func() (*cp.CE_config, error)
+
 // Timeout Offset for Prometheus TimeStamping
 var timeoutOffset = 0.0
 
@@ -78,12 +84,26 @@ func init() {
 }
 
 
-// Create and returns a Handler for the Collector
-func newHandler(metrics cl.Metrics, scrapers []cl.Scraper) http.HandlerFunc {
+// Create and returns a Handler for the Collector. Each request uses the
+// current configuration, so a reload does not affect the in-flight scrapes.
+// With the background collection, the last snapshot is served instead
+func newHandler(metrics cl.Metrics) http.HandlerFunc {
   return func(w http.ResponseWriter, r *http.Request) {
+    // The cluster and service parameters restrict the scrape to them
+    scope := cl.Scrape_scope { Clusters: r.URL.Query()["cluster"], Services: r.URL.Query()["service"] }
+
+    if background_collection {
+      gatherers := prometheus.Gatherers { prometheus.DefaultGatherer, snapshot_gatherer{scope} }
+      promhttp.HandlerFor(const_labels_gatherer{gatherers, get_serving_state().config.Const_labels}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+      return
+    }
 
-    // Use request context for cancellation when connection gets closed.
-    ctx := r.Context()
+    state := get_serving_state()
+
+    // Use request context for cancellation when connection gets closed or
+    // the shutdown grace period expires.
+    ctx, cancel_scrape := with_shutdown(r.Context())
+    defer cancel_scrape()
 
     // If a timeout is configured via the Prometheus header, add it to the context.
     if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
@@ -109,21 +129,117 @@ func newHandler(metrics cl.Metrics, scrapers []cl.Scraper) http.HandlerFunc {
       }
     }
 
+    ctx = cl.With_scrape_scope(ctx, scope)
+
     // Create Prometheus registry with filtererd scrapers
     registry := prometheus.NewRegistry()
 
-    // Register the collector with the data connection struct in the registry
-    registry.MustRegister(cl.New(ctx, config.Connection, metrics, scrapers))
+    // Register the collector with the data connection struct in the registry.
+    // The identity labels of the exporter are added to every collected metric
+    prometheus.WrapRegistererWith(state.config.Identity_labels, registry).MustRegister(cl.New(ctx, state.config.Connection, metrics, state.scrapers))
 
     gatherers := prometheus.Gatherers { prometheus.DefaultGatherer, registry }
 
     // Delegate http serving to Prometheus client library, which will call collector.Collect.
-    h := promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+    h := promhttp.HandlerFor(cardinality_gatherer{const_labels_gatherer{gatherers, state.config.Const_labels}}, promhttp.HandlerOpts{})
     h.ServeHTTP(w, r)
   }
 }
 
 
+// Create and returns a Handler that puts the exporter in standby or
+// activates it. Only POST requests with the standby token are accepted, and
+// only if the endpoints are enabled, as they stop or start all the queries to
+// Cloudera Manager
+func newStandbyHandler(enabled bool) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    current := get_serving_state().config
+    if !authorize_control_request(w, r, current.Standby_endpoint, current.Standby_token) {
+      return
+    }
+    cl.Set_standby(enabled)
+    if enabled {
+      fmt.Fprintln(w, "Exporter in standby")
+    } else {
+      fmt.Fprintln(w, "Exporter activated")
+    }
+  }
+}
+
+
+// Create and returns a Handler that lists the feature flags (GET) or toggles
+// one of them (POST with the name and enabled parameters). The flags are
+// toggled with the same switch and token of /-/reload, as they change the
+// behavior of the exporter as a reload does
+func newFeatureFlagsHandler() http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+    case http.MethodPost:
+      current := get_serving_state().config
+      if !current.Reload_endpoint {
+        w.Header().Set("Allow", http.MethodGet)
+        http.Error(w, "Only GET requests allowed, the flags are toggled with reload_endpoint enabled", http.StatusMethodNotAllowed)
+        return
+      }
+      if !authorize_control_request(w, r, current.Reload_endpoint, current.Reload_token) {
+        return
+      }
+      enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+      if err != nil {
+        http.Error(w, "Invalid enabled parameter: " + r.FormValue("enabled"), http.StatusBadRequest)
+        return
+      }
+      if err := cl.Set_feature_flag(r.FormValue("name"), enabled); err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+      }
+    default:
+      w.Header().Set("Allow", http.MethodGet + ", " + http.MethodPost)
+      http.Error(w, "Only GET and POST requests allowed", http.StatusMethodNotAllowed)
+      return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(cl.Get_feature_flags())
+  }
+}
+
+
+// Create and returns a Handler that publishes the ZooKeeper servers managed by
+// Cloudera Manager as targets in the Prometheus HTTP SD format
+func newServiceDiscoveryHandler() http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    config := get_serving_state().config
+    if !config.Service_discovery {
+      http.NotFound(w, r)
+      return
+    }
+    if cl.Is_standby() {
+      http.Error(w, "Exporter in standby", http.StatusServiceUnavailable)
+      return
+    }
+    groups, err := cl.DiscoverZookeeperTargets(r.Context(), config.Connection, config.Sd_target_port)
+    if err != nil {
+      log.Err_msg("Cannot discover the ZooKeeper targets: %s", err)
+      http.Error(w, "Cannot discover the ZooKeeper targets", http.StatusBadGateway)
+      return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(groups)
+  }
+}
+
+
+// Create and returns a Handler that returns the build information of the
+// binary (version, target platform, CGO state and variant)
+func newVersionHandler() http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(buildinfo.Get())
+  }
+}
+
+
 // Set the version properties of the Cloudera Exporter
 func set_version_properties() {
   version.Version="1.3"
@@ -135,11 +251,11 @@ func set_version_properties() {
 }
 
 
-// Prepare and parse the execution flags
-func parse_exec_flags () {
+// Prepare and parse the execution flags. Returns the selected command
+func parse_exec_flags () string {
   kingpin.Version(version.Print("cloudera_exporter"))
   kingpin.HelpFlag.Short('h')
-  kingpin.Parse()
+  return kingpin.Parse()
 }
 
 
@@ -158,82 +274,188 @@ func register_scrapers (config *cp.CE_config) []cl.Scraper{
 
 
 // Read the flags and the config file and set all the values of the
-// Configuration Structure
-func parse_flags_and_config_file() error {
+// Configuration Structure. Returns the selected command
+func parse_flags_and_config_file() (string, error) {
   var err error
 
   // Parse flags and config file
   configFile := kingpin.Flag("config-file", "Path to ini file.", ).Default(path.Join(os.Getenv("HOME"), "config.ini")).String()
   arg_host := *(kingpin.Flag("web.listen-address", "Listent Address.",).Default("").String())
+  arg_api_version := kingpin.Flag("api-version", "Pin the Cloudera Manager API version (vXX) instead of negotiating it.",).Default("").String()
   arg_num_procs := *(kingpin.Flag("num-procs", "Number Processes for parallel execution",).Default("0").Int())
   arg_log_level := *(kingpin.Flag("log-level", "Debug Log Mode",).Default("0").Int())
   timeoutOffset = *(kingpin.Flag("timeout-offset", "Time to subtract from timeout in seconds.", ).Default("0.25").Float64())
-  parse_exec_flags()
+  arg_user_file := kingpin.Flag("cm.username-file", "File with the Cloudera Manager username.",).Default("").String()
+  arg_password_file := kingpin.Flag("cm.password-file", "File with the Cloudera Manager password.",).Default("").String()
+  arg_shard_index := kingpin.Flag("shard.index", "Index (from 0) of the shard of the collection of this replica. Overrides shard_index of the config file.",).Default("-1").Int()
+  arg_shard_total := kingpin.Flag("shard.total", "Number of replicas the collection is split between. Overrides shard_total of the config file.",).Default("0").Int()
+  command := parse_exec_flags()
+
+  load_config = func() (*cp.CE_config, error) {
+    // The credential files of the execution flags have priority over the
+    // configuration file
+    new_config, err := cp.Parse_config(*configFile, cp.Secret_files{User_file: *arg_user_file, Password_file: *arg_password_file})
+    if err != nil {
+      return nil, err
+    }
 
-  if config, err = cp.Parse_config(*configFile); err != nil {
-    return err
-  }
+    // If host, num_procs or log_level are defined in the execution flags, they
+    // have priority over the configuration file
+    if arg_host != "" {
+      new_config.Connection.Host = arg_host
+    }
+    if arg_num_procs != 0 {
+      new_config.Num_procs = arg_num_procs
+    }
+    if arg_log_level != 0 {
+      new_config.Log_level = arg_log_level
+    }
+    if *arg_api_version != "" {
+      new_config.Connection.Api_version = *arg_api_version
+      new_config.Connection.Api_version_pinned = true
+    }
+    if *arg_shard_index >= 0 || *arg_shard_total > 0 {
+      shard := new_config.Connection.Shard
+      if *arg_shard_index >= 0 {
+        shard.Index = *arg_shard_index
+      }
+      if *arg_shard_total > 0 {
+        shard.Total = *arg_shard_total
+      }
+      if err = cp.Set_shard(new_config, shard); err != nil {
+        return nil, err
+      }
+    }
 
-  // If host, num_procs or log_level are defined in the execution flags, they
-  // have priority over the configuration file
-  if arg_host != "" {
-    config.Connection.Host = arg_host
-  }
-  if arg_num_procs != 0 {
-    config.Num_procs = arg_num_procs
-  }
-  if arg_log_level != 0 {
-    config.Log_level = arg_log_level
+    // Check if Api_version is pinned on the config file or flags, else, the
+    // version is negotiated with Cloudera Manager API and negotiated again if
+    // Cloudera Manager is upgraded. In standby, it is negotiated on activation
+    if !new_config.Connection.Api_version_pinned && !(new_config.Standby && command == serve_command.FullCommand() && !*once_flag) {
+      if new_config.Connection.Api_version, err = cl.Get_api_cloudera_version(nil, new_config.Connection); err != nil {
+        return nil, err
+      }
+    }
+    return new_config, nil
   }
 
-
-  // Check if Api_version is defined on the config file, else, the version is
-  // obtained by Cloudera Manager API
-  if config.Connection.Api_version == "" {
-    if config.Connection.Api_version, err = cl.Get_api_cloudera_version(nil, config.Connection); err != nil {
-      return err
-    }
+  // The feature flags are only set once the configuration is valid
+  config, err = load_config()
+  if err == nil {
+    err = cl.Set_feature_flags(config.Feature_flags)
   }
-  return nil
+  return command, err
 }
 
 // Main function
 func main(){
-  // Starting Logging
-  log.Init(os.Stdout, os.Stdout, os.Stdout, os.Stderr, os.Stdout, 0)
-  log.Info_msg("================================================================================")
-  log.Info_msg("Starting Keedio Cloudera's Metrics Exporter")
-
-  // Setting code version properties
-  log.Info_msg("Exporter Version: %s", version.Version)
+  // Starting Logging. Until the command is known, the logs go to the standard
+  // error, so they are not mixed with the output of the query command
+  log.Init(os.Stderr, os.Stderr, os.Stderr, os.Stderr, os.Stderr, 0)
 
   // Parse Flags and config file
-  if err := parse_flags_and_config_file(); err != nil {
+  command, err := parse_flags_and_config_file()
+  if err != nil {
     log.Err_msg(err.Error())
+    if *once_flag {
+      os.Exit(1)
+    }
+    return
+  }
+
+  // Query command: print the metric window and exit
+  if command == query_command.FullCommand() {
+    log.Init(os.Stderr, os.Stderr, os.Stderr, os.Stderr, os.Stderr, config.Log_level)
+    query_main()
+    return
+  }
+
+  // Fixtures command: collect once, print the promtool fixtures and exit
+  if command == fixtures_command.FullCommand() {
+    log.Init(os.Stderr, os.Stderr, os.Stderr, os.Stderr, os.Stderr, config.Log_level)
+    fixtures_main(register_scrapers(config))
+    return
+  }
+
+  // One-shot mode: collect once, print the metrics and exit. Standby is
+  // ignored, as the collection is explicitly requested
+  if *once_flag {
+    log.Init(os.Stderr, os.Stderr, os.Stderr, os.Stderr, os.Stderr, config.Log_level)
+    once_main(register_scrapers(config))
     return
   }
+
   log.Init(os.Stdout, os.Stdout, os.Stdout, os.Stderr, os.Stdout, config.Log_level)
+  log.Info_msg("================================================================================")
+  log.Info_msg("Starting Keedio Cloudera's Metrics Exporter")
+
+  // Setting code version properties
+  log.Info_msg("Exporter Version: %s", version.Version)
 
   //Parallel Execution
   runtime.GOMAXPROCS(config.Num_procs)
   log.Info_msg("Cores allocated: %s", strconv.Itoa(config.Num_procs))
+  if config.Connection.Shard.Is_enabled() {
+    log.Info_msg("Collecting the shard %d of %d", config.Connection.Shard.Index, config.Connection.Shard.Total)
+  }
 
   // Run info
   log.Info_msg("Build context %s", version.BuildContext())
+  build_info := buildinfo.Get()
+  log.Info_msg("Build variant %s (%s/%s, cgo_enabled=%t)", build_info.Variant, build_info.Os, build_info.Arch, build_info.Cgo_enabled)
 
-  // Exporter creation
+  // Cold-standby mode
+  cl.Set_standby(config.Standby)
+
+  // Exporter creation. The configuration is reloaded on SIGHUP or with the
+  // /-/reload endpoint
   log.Info_msg("Registering Handlers")
-  handlerFunc := newHandler(cl.NewMetrics(), register_scrapers(config))
+  set_serving_state(config)
+  prometheus.MustRegister(config_last_reload_successful, config_last_reload_success_timestamp_seconds)
+  config_last_reload_successful.Set(1)
+  config_last_reload_success_timestamp_seconds.Set(float64(time.Now().Unix()))
+  go reload_on_sighup()
+  // Background collection served by /metrics
+  if config.Collection_interval > 0 {
+    background_collection = true
+    go background_collection_loop(config.Collection_interval)
+  }
+  handlerFunc := newHandler(cl.NewMetrics())
   http.Handle(metrics_path, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handlerFunc))
+  http.Handle("/-/activate", newStandbyHandler(false))
+  http.Handle("/-/standby", newStandbyHandler(true))
+  http.Handle("/-/flags", newFeatureFlagsHandler())
+  http.Handle("/-/reload", newReloadHandler())
+  http.Handle("/sd", newServiceDiscoveryHandler())
+  http.Handle("/debug/cardinality", newCardinalityHandler())
+  http.Handle("/debug/cm", newCmDebugHandler())
+  http.Handle("/version", newVersionHandler())
   http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write(landingPage) })
   log.Ok_msg("Landing Page and Handlers are running")
 
+  // Push of the metrics to an OpenTelemetry collector
+  if config.Otlp != nil {
+    go otlp_push_loop(*config.Otlp)
+  }
+
+  // Push of the metrics to a Prometheus remote write endpoint
+  if config.Remote_write != nil {
+    go remote_write_push_loop(*config.Remote_write)
+  }
+
+  // Check of the latest release of the exporter
+  if config.Update_check != nil {
+    go update_check_loop(*config.Update_check)
+  }
+
 
   // Exporter HTTP connection
   log.Info_msg("Target to scraping metrics from: %s:%s", config.Connection.Host, config.Connection.Port)
   ip := func () string {if config.Deploy_ip == "" { return "0.0.0.0" } else { return config.Deploy_ip }}
   log.Info_msg("Metrics published on: %s:%d", ip(), config.Deploy_port)
   log.Ok_msg("Keedio's Cloudera Exporter running")
-  log.Err_msg(http.ListenAndServe(fmt.Sprintf("%s:%d", config.Deploy_ip, config.Deploy_port), nil).Error())
+  server := &http.Server{Addr: fmt.Sprintf("%s:%d", config.Deploy_ip, config.Deploy_port)}
+  if err := serve_until_signal(server, config.Shutdown_grace_period); err != nil {
+    log.Err_msg(err.Error())
+  }
   return
 }
@@ -0,0 +1,126 @@
+/*
+ *
+ * title           :cm_client/cluster_endpoint.go
+ * description     :Authentication of the requests and per-cluster overrides
+ *                  of the base URL, credentials and TLS settings
+ * author          :Enes Erdoğan
+ * date            :2025/04/28
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "net/http"
+  "net/url"
+  "strings"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Authentication modules
+const (
+  AUTH_BASIC =  "basic"
+  AUTH_BEARER = "bearer"
+  AUTH_NONE =   "none"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Authentication of the requests. Basic authentication if the module is blank
+type Auth struct {
+  Module string
+  User string
+  Passwd string
+  Token string
+}
+
+// Settings of the requests to the resources of a cluster, when its API is
+// fronted by a different proxy path, credentials or TLS settings
+type Cluster_endpoint struct {
+  // Replaces the scheme, host, port and path prefix of the target. Nil to
+  // keep them
+  Base_url *url.URL
+  Auth Auth
+  Http_client *Http_client
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns true if the authentication module is supported
+func Is_valid_auth_module(module string) bool {
+  switch module {
+  case "", AUTH_BASIC, AUTH_BEARER, AUTH_NONE:
+    return true
+  }
+  return false
+}
+
+
+// Set the authentication of the request
+func (a Auth) apply(req *http.Request) {
+  switch a.Module {
+  case AUTH_NONE:
+  case AUTH_BEARER:
+    req.Header.Set("Authorization", "Bearer " + a.Token)
+  default:
+    req.SetBasicAuth(a.User, a.Passwd)
+  }
+}
+
+
+// Returns the cluster of an API URL (http://host:port/api/vXX/clusters/<cluster>/...),
+// or "" if the resource does not belong to a cluster
+func Get_uri_cluster(uri string) string {
+  u, err := url.Parse(uri)
+  if err != nil {
+    return ""
+  }
+  segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+  if len(segments) < 4 || segments[0] != "api" || segments[2] != "clusters" {
+    return ""
+  }
+  return segments[3]
+}
+
+
+// Returns the URL with the scheme, host and port of the base URL, and its
+// path prefixed with the path of the base URL
+func Rebase_url(uri string, base *url.URL) (string, error) {
+  if base == nil {
+    return uri, nil
+  }
+  u, err := url.Parse(uri)
+  if err != nil {
+    return "", err
+  }
+  rebased := *u
+  rebased.Scheme = base.Scheme
+  rebased.Host = base.Host
+  rebased.User = base.User
+  rebased.Path = strings.TrimSuffix(base.Path, "/") + u.Path
+  rebased.RawPath = ""
+  if u.RawPath != "" || base.RawPath != "" {
+    rebased.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + u.EscapedPath()
+  }
+  return rebased.String(), nil
+}
@@ -0,0 +1,156 @@
+/*
+ *
+ * title           :cm_client/cm_client.go
+ * description     :Client of the Cloudera Manager API shared by all the
+ *                  service collectors: HTTP requests, authentication and
+ *                  status errors
+ * author          :Enes Erdoğan
+ * date            :2025/03/24
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "net/http"
+  "strconv"
+  "strings"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Bytes of the body of an invalid HTTP response kept in its error, enough
+// for the message of Cloudera Manager
+const ERROR_BODY_SIZE = 1024
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Error returned when Cloudera Manager answers with an invalid HTTP status
+type Http_status_error struct {
+  Status_code int
+  Status string
+  // Start of the body, with the message of Cloudera Manager
+  Body string
+}
+
+func (e *Http_status_error) Error() string {
+  return fmt.Sprintf("Invalid HTTP response code: %s", e.Status)
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Make a GET request to the Cloudera Manager API with the authentication of
+// the user and returns the JSON response
+func Get(ctx context.Context, client *Http_client, uri string, auth Auth) (body string, err error) {
+  log.Debug_msg("Making API Query: %s ", uri)
+
+  // Build the request Object
+  req, err := http.NewRequest(http.MethodGet, uri, nil)
+  if err != nil {
+    log.Err_msg("Building Request for URL:%s, Failed. Error: %s", uri, err)
+    return "", err
+  }
+
+  // Overwrite request with timeout context.
+  if ctx != nil {
+    req = req.WithContext(ctx)
+  }
+
+  // Request response header
+  req.Header.Add("Content-Type", "application/json")
+
+  // Set Authentication credentials
+  auth.apply(req)
+
+  // Add the configured headers and trace the reuse of the connections
+  req = client.prepare_request(req)
+
+  // Wait for the rate limiter of the requests to Cloudera Manager
+  if err := client.wait(req.Context()); err != nil {
+    log.Err_msg("Request for URL:%s not made: %s", uri, err)
+    return "", err
+  }
+
+  // Make the API request with the HTTP client shared by all the queries
+  res, err := client.get_client().Do(req)
+  if Is_mutation_error(err) {
+    log.Err_msg("%s", err)
+    return "", err
+  }
+  if err != nil {
+    log.Err_msg("%s", err)
+    client.count_error("error")
+    return "", err
+  }
+  if res == nil {
+    log.Err_msg("HTTP response is NULL")
+    return "", errors.New("HTTP response is NULL")
+  }
+  defer res.Body.Close()
+  if res.StatusCode < 200 || res.StatusCode >= 400 {
+    log.Err_msg("Invalid HTTP response code: %s for the request: %s", res.Status, uri)
+    client.count_error(strconv.Itoa(res.StatusCode))
+    body, _ := ioutil.ReadAll(io.LimitReader(res.Body, ERROR_BODY_SIZE))
+    client.drain_body(res)
+    return "", &Http_status_error{res.StatusCode, res.Status, string(body)}
+  }
+
+  // Get Body Response, rejecting the ones too large, with an unexpected
+  // content type (e.g. the HTML login page of a proxy) or invalid JSON
+  content, err := read_response(res, uri, client.max_response_size())
+  if response_err, ok := err.(*Response_error); ok {
+    log.Err_msg("%s", response_err)
+    client.count_response_error(response_err.Reason)
+    return "", err
+  }
+  if err != nil {
+    log.Err_msg("Failed to parse response with error: %s", err)
+    return "", err
+  }
+  return string(content), nil
+}
+
+
+// Returns true if Cloudera Manager answered with the given HTTP status
+func Is_http_status(err error, status_code int) bool {
+  status_err, ok := err.(*Http_status_error)
+  return ok && status_err.Status_code == status_code
+}
+
+
+// Returns true if Cloudera Manager rejected the request because it does not
+// support the API version of its path
+func Is_unsupported_api_version(err error) bool {
+  status_err, ok := err.(*Http_status_error)
+  if !ok || (status_err.Status_code != http.StatusNotFound && status_err.Status_code != http.StatusBadRequest) {
+    return false
+  }
+  return strings.Contains(strings.ToLower(status_err.Body), "api version")
+}
@@ -0,0 +1,210 @@
+/*
+ *
+ * title           :cm_client/credentials.go
+ * description     :Credentials of the Cloudera Manager user read from files,
+ *                  environment variables or HashiCorp Vault, and refreshed
+ *                  when they are rotated
+ * author          :Enes Erdoğan
+ * date            :2025/05/26
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "os"
+  "strings"
+  "sync"
+  "time"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+
+  // Go external libraries
+  "github.com/tidwall/gjson"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Source of a secret value
+type Secret interface {
+  Read() (string, error)
+}
+
+// Secret written in the config file
+type Literal_secret string
+
+// Secret read from a file. The trailing new line is removed
+type File_secret struct {
+  Path string
+}
+
+// Secret read from an environment variable
+type Env_secret struct {
+  Name string
+}
+
+// Secret read from a KV (version 1 or 2) secrets engine of HashiCorp Vault
+type Vault_secret struct {
+  // Vault address (e.g. https://vault.example.com:8200)
+  Address string
+  // Path of the secret, with the data/ segment for KV version 2 (e.g.
+  // secret/data/cloudera)
+  Path string
+  Key string
+  Token Secret
+  Client *http.Client
+}
+
+// Credentials of the Cloudera Manager user, shared by all the scrapes
+type Credentials struct {
+  sync.RWMutex
+  user_secret Secret
+  passwd_secret Secret
+  user string
+  passwd string
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+func (s Literal_secret) Read() (string, error) {
+  return string(s), nil
+}
+
+
+func (s File_secret) Read() (string, error) {
+  content, err := ioutil.ReadFile(s.Path)
+  if err != nil {
+    return "", err
+  }
+  return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+
+func (s Env_secret) Read() (string, error) {
+  value, ok := os.LookupEnv(s.Name)
+  if !ok {
+    return "", fmt.Errorf("Environment variable %s not set", s.Name)
+  }
+  return value, nil
+}
+
+
+func (s Vault_secret) Read() (string, error) {
+  token, err := s.Token.Read()
+  if err != nil {
+    return "", err
+  }
+  req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(s.Address, "/") + "/v1/" + strings.TrimPrefix(s.Path, "/"), nil)
+  if err != nil {
+    return "", err
+  }
+  req.Header.Set("X-Vault-Token", token)
+
+  client := s.Client
+  if client == nil {
+    client = http.DefaultClient
+  }
+  res, err := client.Do(req)
+  if err != nil {
+    return "", err
+  }
+  defer res.Body.Close()
+  if res.StatusCode != http.StatusOK {
+    return "", fmt.Errorf("Invalid HTTP response code from Vault: %s", res.Status)
+  }
+  content, err := ioutil.ReadAll(res.Body)
+  if err != nil {
+    return "", err
+  }
+
+  // KV version 2 nests the secret in data.data, version 1 in data
+  json := gjson.ParseBytes(content)
+  for _, path := range []string{"data.data." + s.Key, "data." + s.Key} {
+    if value := json.Get(path); value.Exists() {
+      return value.String(), nil
+    }
+  }
+  return "", fmt.Errorf("Key %s not found in the Vault secret %s", s.Key, s.Path)
+}
+
+
+// Create the credentials and read their secrets
+func New_credentials(user_secret Secret, passwd_secret Secret) (*Credentials, error) {
+  credentials := &Credentials{user_secret: user_secret, passwd_secret: passwd_secret}
+  if _, err := credentials.Refresh(); err != nil {
+    return nil, err
+  }
+  return credentials, nil
+}
+
+
+// Read the secrets again. Returns true if the credentials changed. The
+// credentials are kept if a secret cannot be read
+func (c *Credentials) Refresh() (bool, error) {
+  user, err := c.user_secret.Read()
+  if err != nil {
+    return false, err
+  }
+  passwd, err := c.passwd_secret.Read()
+  if err != nil {
+    return false, err
+  }
+  if user == "" || passwd == "" {
+    return false, errors.New("Empty Cloudera Manager username or password")
+  }
+
+  c.Lock()
+  defer c.Unlock()
+  changed := user != c.user || passwd != c.passwd
+  c.user, c.passwd = user, passwd
+  return changed, nil
+}
+
+
+// Returns the username and password
+func (c *Credentials) Get() (string, string) {
+  c.RLock()
+  defer c.RUnlock()
+  return c.user, c.passwd
+}
+
+
+// Refresh the credentials on every interval, so rotated secrets are used
+// without restarting the exporter, until stop is closed
+func (c *Credentials) Watch(interval time.Duration, stop <-chan struct{}) {
+  ticker := time.NewTicker(interval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-stop:
+      return
+    case <-ticker.C:
+    }
+    changed, err := c.Refresh()
+    if err != nil {
+      log.Err_msg("Cannot refresh the Cloudera Manager credentials: %s", err)
+    } else if changed {
+      log.Info_msg("Cloudera Manager credentials changed. Using the new ones")
+    }
+  }
+}
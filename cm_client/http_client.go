@@ -0,0 +1,272 @@
+/*
+ *
+ * title           :cm_client/http_client.go
+ * description     :HTTP client shared by all the queries to Cloudera Manager
+ * author          :Enes Erdoğan
+ * date            :2025/03/03
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "crypto/tls"
+  "io"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptrace"
+  "net/url"
+  "strconv"
+  "time"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Settings of the connection pool to Cloudera Manager
+type Http_client_options struct {
+  Max_idle_conns_per_host int
+  Idle_conn_timeout time.Duration
+  Timeout time.Duration
+  Http2 bool
+  Proxy_url *url.URL
+  Headers map[string]string
+  Tls_config *tls.Config
+  // Requests per second to Cloudera Manager (0 for no limit) and burst
+  Rate_limit float64
+  Rate_limit_burst int
+  // Allow requests other than GET to Cloudera Manager
+  Allow_mutations bool
+  // Max size of the responses in bytes (0 for no limit)
+  Max_response_size int64
+}
+
+// HTTP client with keep-alive connections shared by all the scrapes, and
+// counters of the reused connections, the failed requests, the invalid
+// responses and the requests delayed by the rate limiter
+type Http_client struct {
+  client *http.Client
+  options Http_client_options
+  headers map[string]string
+  limiter *Rate_limiter
+  connections *prometheus.CounterVec
+  request_errors *prometheus.CounterVec
+  response_errors *prometheus.CounterVec
+  throttled_requests prometheus.Counter
+  throttled_seconds prometheus.Counter
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Create the transport with the pool of connections
+func new_transport(options Http_client_options) *http.Transport {
+  // Explicit proxy, or the one of the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+  // environment variables
+  proxy := http.ProxyFromEnvironment
+  if options.Proxy_url != nil {
+    proxy = http.ProxyURL(options.Proxy_url)
+  }
+
+  transport := &http.Transport {
+    Proxy: proxy,
+    TLSClientConfig: options.Tls_config,
+    MaxIdleConns: options.Max_idle_conns_per_host,
+    MaxIdleConnsPerHost: options.Max_idle_conns_per_host,
+    IdleConnTimeout: options.Idle_conn_timeout,
+    TLSHandshakeTimeout: 10 * time.Second,
+    ExpectContinueTimeout: 1 * time.Second,
+  }
+  // A non-nil TLSNextProto map disables HTTP/2
+  if !options.Http2 {
+    transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+  }
+  return transport
+}
+
+
+// Create the HTTP client of the pool of connections, which only makes GET
+// requests unless the mutations are allowed
+func new_client(options Http_client_options) *http.Client {
+  return &http.Client {
+    Transport: read_only_transport{next: new_transport(options), allow_mutations: options.Allow_mutations},
+    Timeout: options.Timeout,
+  }
+}
+
+
+// Create the HTTP client with a pool of connections to Cloudera Manager
+func New_http_client(options Http_client_options) *Http_client {
+  return &Http_client {
+    client: new_client(options),
+    options: options,
+    headers: options.Headers,
+    limiter: New_rate_limiter(options.Rate_limit, options.Rate_limit_burst),
+    connections: prometheus.NewCounterVec(prometheus.CounterOpts {
+      Namespace: "kbdi",
+      Subsystem: "exporter",
+      Name:      "cm_connections_total",
+      Help:      "Total number of connections used for the requests to Cloudera Manager, by whether they were reused from the pool.",
+    }, []string{"reused"}),
+    request_errors: prometheus.NewCounterVec(prometheus.CounterOpts {
+      Namespace: "kbdi",
+      Subsystem: "exporter",
+      Name:      "cm_request_errors_total",
+      Help:      "Total number of failed requests to Cloudera Manager, by HTTP status code (error for the connection errors).",
+    }, []string{"code"}),
+    response_errors: prometheus.NewCounterVec(prometheus.CounterOpts {
+      Namespace: "kbdi",
+      Subsystem: "exporter",
+      Name:      "cm_response_errors_total",
+      Help:      "Total number of responses of Cloudera Manager rejected by reason (too_large, content_type, decode).",
+    }, []string{"reason"}),
+    throttled_requests: prometheus.NewCounter(prometheus.CounterOpts {
+      Namespace: "kbdi",
+      Subsystem: "exporter",
+      Name:      "cm_requests_throttled_total",
+      Help:      "Total number of requests to Cloudera Manager delayed by the rate limiter.",
+    }),
+    throttled_seconds: prometheus.NewCounter(prometheus.CounterOpts {
+      Namespace: "kbdi",
+      Subsystem: "exporter",
+      Name:      "cm_requests_throttled_seconds_total",
+      Help:      "Total time the requests to Cloudera Manager waited for the rate limiter.",
+    }),
+  }
+}
+
+
+// Returns a client with the same settings, counters and rate limiter, but its
+// own pool of connections with the given TLS config
+func (c *Http_client) With_tls(tls_config *tls.Config) *Http_client {
+  if c == nil {
+    return New_http_client(Http_client_options{Http2: true, Tls_config: tls_config, Max_response_size: DEFAULT_MAX_RESPONSE_SIZE})
+  }
+  options := c.options
+  options.Tls_config = tls_config
+  client := *c
+  client.client = new_client(options)
+  client.options = options
+  return &client
+}
+
+
+// Returns the HTTP client, or the default read-only one if it is not
+// configured
+func (c *Http_client) get_client() *http.Client {
+  if c == nil {
+    return default_read_only_client
+  }
+  return c.client
+}
+
+
+// Returns the request with the static headers and a trace that counts the
+// reused connections
+func (c *Http_client) prepare_request(req *http.Request) *http.Request {
+  if c == nil {
+    return req
+  }
+  for name, value := range c.headers {
+    req.Header.Set(name, value)
+  }
+  trace := &httptrace.ClientTrace {
+    GotConn: func(info httptrace.GotConnInfo) {
+      c.connections.WithLabelValues(strconv.FormatBool(info.Reused)).Inc()
+    },
+  }
+  return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+
+// Wait until the rate limiter allows a request, counting the delayed ones
+func (c *Http_client) wait(ctx context.Context) error {
+  if c == nil {
+    return nil
+  }
+  waited, err := c.limiter.Wait(ctx)
+  if waited > 0 {
+    c.throttled_requests.Inc()
+    c.throttled_seconds.Add(waited.Seconds())
+  }
+  return err
+}
+
+
+// Count a failed request by its HTTP status code
+func (c *Http_client) count_error(code string) {
+  if c != nil {
+    c.request_errors.WithLabelValues(code).Inc()
+  }
+}
+
+
+// Returns the max size of the responses, the default one if the client is
+// not configured
+func (c *Http_client) max_response_size() int64 {
+  if c == nil {
+    return DEFAULT_MAX_RESPONSE_SIZE
+  }
+  return c.options.Max_response_size
+}
+
+
+// Read what is left of the body of a response, up to the max size, so the
+// connection goes back to the pool when the body is closed instead of being
+// closed too
+func (c *Http_client) drain_body(res *http.Response) {
+  var body io.Reader = res.Body
+  if max_size := c.max_response_size(); max_size > 0 {
+    body = io.LimitReader(res.Body, max_size)
+  }
+  io.Copy(ioutil.Discard, body)
+}
+
+
+// Count an invalid response by its reason
+func (c *Http_client) count_response_error(reason string) {
+  if c != nil {
+    c.response_errors.WithLabelValues(reason).Inc()
+  }
+}
+
+
+// Describe implements prometheus.Collector.
+func (c *Http_client) Describe(ch chan<- *prometheus.Desc) {
+  if c != nil {
+    c.connections.Describe(ch)
+    c.request_errors.Describe(ch)
+    c.response_errors.Describe(ch)
+    ch <- c.throttled_requests.Desc()
+    ch <- c.throttled_seconds.Desc()
+  }
+}
+
+
+// Collect implements prometheus.Collector.
+func (c *Http_client) Collect(ch chan<- prometheus.Metric) {
+  if c != nil {
+    c.connections.Collect(ch)
+    c.request_errors.Collect(ch)
+    c.response_errors.Collect(ch)
+    ch <- c.throttled_requests
+    ch <- c.throttled_seconds
+  }
+}
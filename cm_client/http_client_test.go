@@ -0,0 +1,71 @@
+/*
+ *
+ * title           :cm_client/http_client_test.go
+ * description     :Tests of the pool of connections to Cloudera Manager
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// The connections of the responses with an invalid status go back to the
+// pool, even if their body is larger than the part kept in the error and
+// than what the transport reads by itself when the body is closed
+func TestGet_invalid_status_reuses_connection(t *testing.T) {
+  log.Init(ioutil.Discard, ioutil.Discard, ioutil.Discard, ioutil.Discard, ioutil.Discard, 0)
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/plain")
+    w.WriteHeader(http.StatusServiceUnavailable)
+    w.Write([]byte("Service unavailable " + strings.Repeat(".", 1 << 20)))
+  }))
+  defer server.Close()
+
+  client := New_http_client(Http_client_options{Max_idle_conns_per_host: 1, Timeout: 5 * time.Second, Max_response_size: DEFAULT_MAX_RESPONSE_SIZE})
+  for i := 0; i < 3; i++ {
+    _, err := Get(context.Background(), client, server.URL + "/api/v19/timeseries", Auth{Module: AUTH_NONE})
+    if !Is_http_status(err, http.StatusServiceUnavailable) {
+      t.Fatalf("Got the error %v, want the 503 status", err)
+    }
+    if body := err.(*Http_status_error).Body; len(body) != ERROR_BODY_SIZE || !strings.HasPrefix(body, "Service unavailable") {
+      t.Fatalf("Got the body %.40q (%d bytes) in the error, want its first %d bytes", body, len(body), ERROR_BODY_SIZE)
+    }
+  }
+
+  var reused dto.Metric
+  if err := client.connections.WithLabelValues("true").Write(&reused); err != nil {
+    t.Fatal(err)
+  }
+  if got := reused.GetCounter().GetValue(); got != 2 {
+    t.Errorf("%v connections reused, want 2", got)
+  }
+}
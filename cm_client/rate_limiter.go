@@ -0,0 +1,113 @@
+/*
+ *
+ * title           :cm_client/rate_limiter.go
+ * description     :Token bucket that limits the rate of the requests to
+ *                  Cloudera Manager
+ * author          :Enes Erdoğan
+ * date            :2025/07/28
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "sync"
+  "time"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Token bucket refilled at rate tokens per second, up to burst tokens. Each
+// request takes a token, waiting for it if the bucket is empty
+type Rate_limiter struct {
+  mutex sync.Mutex
+  rate float64
+  burst float64
+  tokens float64
+  last time.Time
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Create a rate limiter of the given requests per second and burst. Nil (no
+// limit) if the rate is not positive
+func New_rate_limiter(rate float64, burst int) *Rate_limiter {
+  if rate <= 0 {
+    return nil
+  }
+  if burst < 1 {
+    burst = 1
+  }
+  return &Rate_limiter {
+    rate: rate,
+    burst: float64(burst),
+    tokens: float64(burst),
+    last: time.Now(),
+  }
+}
+
+
+// Take a token and returns the time to wait for it. The bucket can go below
+// zero, so the waiting requests are served in order
+func (l *Rate_limiter) reserve() time.Duration {
+  l.mutex.Lock()
+  defer l.mutex.Unlock()
+  now := time.Now()
+  l.tokens += now.Sub(l.last).Seconds() * l.rate
+  if l.tokens > l.burst {
+    l.tokens = l.burst
+  }
+  l.last = now
+  l.tokens--
+  if l.tokens >= 0 {
+    return 0
+  }
+  return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+
+// Give back a token not used
+func (l *Rate_limiter) cancel() {
+  l.mutex.Lock()
+  defer l.mutex.Unlock()
+  l.tokens++
+}
+
+
+// Wait until a request is allowed and returns the time waited. If the
+// context is done first, the token is given back and the error of the
+// context returned
+func (l *Rate_limiter) Wait(ctx context.Context) (time.Duration, error) {
+  if l == nil {
+    return 0, nil
+  }
+  delay := l.reserve()
+  if delay == 0 {
+    return 0, nil
+  }
+  timer := time.NewTimer(delay)
+  defer timer.Stop()
+  select {
+  case <-timer.C:
+    return delay, nil
+  case <-ctx.Done():
+    l.cancel()
+    return 0, ctx.Err()
+  }
+}
@@ -0,0 +1,85 @@
+/*
+ *
+ * title           :cm_client/read_only.go
+ * description     :Interlock that keeps the requests to Cloudera Manager
+ *                  read-only (GET)
+ * author          :Enes Erdoğan
+ * date            :2025/09/08
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "fmt"
+  "net/http"
+  "net/url"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Error returned for a request that could modify Cloudera Manager
+type Mutation_error struct {
+  Method string
+  Url string
+}
+
+func (e *Mutation_error) Error() string {
+  return fmt.Sprintf("%s request to %s rejected: the exporter only makes GET requests to Cloudera Manager (allow_mutations is not set)", e.Method, e.Url)
+}
+
+// Transport that rejects every request but GET, unless the mutations are
+// allowed. It is the last step before the connection, so no request of the
+// exporter skips it
+type read_only_transport struct {
+  next http.RoundTripper
+  allow_mutations bool
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Client used when the HTTP client is not configured, read-only too
+var default_read_only_client = &http.Client{Transport: read_only_transport{next: http.DefaultTransport}}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// RoundTrip implements http.RoundTripper.
+func (t read_only_transport) RoundTrip(req *http.Request) (*http.Response, error) {
+  if req.Method != http.MethodGet && req.Method != "" && !t.allow_mutations {
+    if req.Body != nil {
+      req.Body.Close()
+    }
+    return nil, &Mutation_error{req.Method, req.URL.Scheme + "://" + req.URL.Host + req.URL.Path}
+  }
+  return t.next.RoundTrip(req)
+}
+
+
+// Returns true if the request was rejected because it could modify Cloudera
+// Manager
+func Is_mutation_error(err error) bool {
+  if url_err, ok := err.(*url.Error); ok {
+    err = url_err.Err
+  }
+  _, ok := err.(*Mutation_error)
+  return ok
+}
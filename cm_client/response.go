@@ -0,0 +1,117 @@
+/*
+ *
+ * title           :cm_client/response.go
+ * description     :Defensive reading of the Cloudera Manager responses: size
+ *                  limit, content type and JSON validation
+ * author          :Enes Erdoğan
+ * date            :2025/10/20
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "encoding/json"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "mime"
+  "net/http"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Max size of the responses when the HTTP client is not configured (64 MiB)
+const DEFAULT_MAX_RESPONSE_SIZE = 64 << 20
+
+// Reasons of the invalid responses
+const (
+  RESPONSE_TOO_LARGE = "too_large"
+  RESPONSE_CONTENT_TYPE = "content_type"
+  RESPONSE_DECODE = "decode"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Error returned when the response of Cloudera Manager can't be used: too
+// large, with an unexpected content type or with invalid JSON
+type Response_error struct {
+  Reason string
+  Url string
+  Detail string
+}
+
+func (e *Response_error) Error() string {
+  return fmt.Sprintf("Invalid response (%s) for the request %s: %s", e.Reason, e.Url, e.Detail)
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Content types of the Cloudera Manager API: JSON, and plain text for the
+// API version. A response without content type is accepted too
+var allowed_content_types = map[string]bool {
+  "application/json": true,
+  "text/plain": true,
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Read the body of the response, up to the max size (0 for no limit), and
+// check that it is valid for its content type. The URL is the one of the
+// request, reported in the errors
+func read_response(res *http.Response, uri string, max_size int64) ([]byte, error) {
+  content_type := res.Header.Get("Content-Type")
+  media_type := ""
+  if content_type != "" {
+    var err error
+    if media_type, _, err = mime.ParseMediaType(content_type); err != nil || !allowed_content_types[media_type] {
+      return nil, &Response_error{RESPONSE_CONTENT_TYPE, uri, fmt.Sprintf("unexpected content type %q", content_type)}
+    }
+  }
+
+  // The size is checked before reading when it is known, and one byte over
+  // the limit is read otherwise, so larger bodies are never kept in memory
+  var body io.Reader = res.Body
+  if max_size > 0 {
+    if res.ContentLength > max_size {
+      return nil, &Response_error{RESPONSE_TOO_LARGE, uri, fmt.Sprintf("%d bytes, over the limit of %d bytes", res.ContentLength, max_size)}
+    }
+    body = io.LimitReader(res.Body, max_size + 1)
+  }
+  content, err := ioutil.ReadAll(body)
+  if err != nil {
+    return nil, err
+  }
+  if max_size > 0 && int64(len(content)) > max_size {
+    return nil, &Response_error{RESPONSE_TOO_LARGE, uri, fmt.Sprintf("over the limit of %d bytes", max_size)}
+  }
+
+  if media_type == "application/json" && !json.Valid(content) {
+    return nil, &Response_error{RESPONSE_DECODE, uri, "invalid JSON"}
+  }
+  return content, nil
+}
+
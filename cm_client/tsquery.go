@@ -0,0 +1,191 @@
+/*
+ *
+ * title           :cm_client/tsquery.go
+ * description     :Builder of the Cloudera Manager TimeSeries queries (tsquery)
+ * author          :Enes Erdoğan
+ * date            :2025/03/24
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "fmt"
+  "regexp"
+  "strings"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// TimeSeries scopes, from the narrowest to the broadest
+const (
+  SCOPE_ROLE =    "ROLE"
+  SCOPE_SERVICE = "SERVICE"
+  SCOPE_CLUSTER = "CLUSTER"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+var scope_order = []string{SCOPE_ROLE, SCOPE_SERVICE, SCOPE_CLUSTER}
+
+// Category predicate of a tsquery (category=ROLE, category="SERVICE"...)
+var tsquery_category_regex = regexp.MustCompile(`(?i)category\s*=\s*"?([a-z_]+)"?`)
+
+// Selection and predicates of a tsquery
+var tsquery_regex = regexp.MustCompile(`(?is)^\s*select\s+(.+?)(?:\s+where\s+(.+?))?\s*$`)
+
+// Separator of the predicates of a conjunction, and the operators that make
+// the predicates more than a conjunction
+var tsquery_and_regex = regexp.MustCompile(`(?i)\s+and\s+`)
+var tsquery_or_regex = regexp.MustCompile(`(?i)\s+or\s+|[()]`)
+
+// Predicate: attribute, operator and value
+var tsquery_predicate_regex = regexp.MustCompile(`^\s*([A-Za-z_]+)\s*(=?)\s*(.*?)\s*$`)
+
+// Identifier of a tsquery selection, followed by a parenthesis if it is a
+// function (LAST, INTEGRAL...) and not a metric
+var tsquery_identifier_regex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(\s*\()?`)
+
+// Attributes of the role and host entities, which the service entities do
+// not have
+var role_attributes = map[string]bool{"rolename": true, "roletype": true, "entityname": true, "hostname": true, "hostid": true, "rackid": true}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Build a tsquery: SELECT <selection> WHERE <predicate> AND <predicate>...
+func Build_tsquery(selection string, predicates ...string) string {
+  if len(predicates) == 0 {
+    return fmt.Sprintf("SELECT %s", selection)
+  }
+  return fmt.Sprintf("SELECT %s WHERE %s", selection, strings.Join(predicates, " AND "))
+}
+
+
+// Returns the predicate attribute="value"
+func Predicate(attribute string, value string) string {
+  return fmt.Sprintf("%s=%q", attribute, value)
+}
+
+
+// Returns the selection and the predicates of the tsquery, or false if its
+// predicates are not a conjunction (OR, parentheses)
+func split_tsquery(query string) (string, []string, bool) {
+  match := tsquery_regex.FindStringSubmatch(query)
+  if match == nil {
+    return "", nil, false
+  }
+  if match[2] == "" {
+    return match[1], nil, true
+  }
+  if tsquery_or_regex.MatchString(match[2]) {
+    return "", nil, false
+  }
+  return match[1], tsquery_and_regex.Split(match[2], -1), true
+}
+
+
+// Returns the attribute and the value of a predicate, and false if its
+// operator is not = (rlike, !=...)
+func split_predicate(predicate string) (string, string, bool) {
+  match := tsquery_predicate_regex.FindStringSubmatch(predicate)
+  if match == nil {
+    return "", "", false
+  }
+  return match[1], strings.Trim(match[3], `"`), match[2] == "="
+}
+
+
+// Returns the scope of the tsquery: the one of its category predicate, ROLE
+// if it selects roles (roleType or roleName predicates), or "" otherwise
+func Get_tsquery_scope(query string) string {
+  if match := tsquery_category_regex.FindStringSubmatch(query); match != nil {
+    return strings.ToUpper(match[1])
+  }
+  _, predicates, _ := split_tsquery(query)
+  for _, predicate := range predicates {
+    attribute, _, _ := split_predicate(predicate)
+    if strings.EqualFold(attribute, "roleType") || strings.EqualFold(attribute, "roleName") {
+      return SCOPE_ROLE
+    }
+  }
+  return ""
+}
+
+
+// Returns the tsquery with the given scope, or false if its metrics have no
+// counterpart in the scope. The metrics of a role type are aggregated by
+// service in the <metric>_across_<role type>s metrics, so a ROLE query with
+// a roleType predicate is rewritten to select them from the services, without
+// the role and host predicates. No metric of a service is aggregated by
+// cluster under a name the query can be rewritten to
+func Rescope_tsquery(query string, scope string) (string, bool) {
+  from := Get_tsquery_scope(query)
+  if scope == from {
+    return query, true
+  }
+  if from != SCOPE_ROLE || scope != SCOPE_SERVICE {
+    return "", false
+  }
+  selection, predicates, ok := split_tsquery(query)
+  if !ok {
+    return "", false
+  }
+
+  role_type := ""
+  service_predicates := []string{}
+  for _, predicate := range predicates {
+    attribute, value, equal := split_predicate(predicate)
+    switch {
+    case !equal && role_attributes[strings.ToLower(attribute)]:
+      // e.g. roleName rlike "...": the roles it selects are unknown
+      return "", false
+    case strings.EqualFold(attribute, "roleType"):
+      role_type = value
+    case strings.EqualFold(attribute, "category"), role_attributes[strings.ToLower(attribute)]:
+    default:
+      service_predicates = append(service_predicates, predicate)
+    }
+  }
+  if role_type == "" {
+    return "", false
+  }
+
+  aggregate_suffix := fmt.Sprintf("_across_%ss", strings.ToLower(role_type))
+  selection = tsquery_identifier_regex.ReplaceAllStringFunc(selection, func(identifier string) string {
+    if strings.HasSuffix(identifier, "(") {
+      return identifier
+    }
+    return identifier + aggregate_suffix
+  })
+  return Build_tsquery(selection, append(service_predicates, fmt.Sprintf("category=%s", SCOPE_SERVICE))...), true
+}
+
+
+// Broader scopes than the given one
+func Get_broader_scopes(scope string) []string {
+  for i, s := range scope_order {
+    if s == scope {
+      return scope_order[i+1:]
+    }
+  }
+  return nil
+}
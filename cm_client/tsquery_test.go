@@ -0,0 +1,72 @@
+/*
+ *
+ * title           :cm_client/tsquery_test.go
+ * description     :Tests of the scopes of the TimeSeries queries
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package cm_client
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "testing"
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+func TestGet_tsquery_scope(t *testing.T) {
+  for query, want := range map[string]string {
+    `SELECT LAST(alerts_rate) WHERE category="SERVICE" AND serviceName="ZOOKEEPER"`: SCOPE_SERVICE,
+    `SELECT LAST(total_snapshots_across_namenodes) WHERE category=CLUSTER and entityName=1`: SCOPE_CLUSTER,
+    `SELECT LAST(avg_latency) WHERE roleType="SERVER" AND serviceType="ZOOKEEPER"`: SCOPE_ROLE,
+    `SELECT LAST(fd_open) WHERE roleName="zookeeper-SERVER-1"`: SCOPE_ROLE,
+    `SELECT LAST(alerts_rate_across_clusters)`: "",
+  } {
+    if got := Get_tsquery_scope(query); got != want {
+      t.Errorf("Get_tsquery_scope(%s) = %q, want %q", query, got, want)
+    }
+  }
+}
+
+
+func TestRescope_tsquery(t *testing.T) {
+  for _, c := range []struct {
+    query string
+    scope string
+    want string
+    ok bool
+  } {
+    // The requested scope is not rewritten
+    {`SELECT LAST(alerts_rate) WHERE category="SERVICE" AND serviceName="ZOOKEEPER"`, SCOPE_SERVICE,
+      `SELECT LAST(alerts_rate) WHERE category="SERVICE" AND serviceName="ZOOKEEPER"`, true},
+    // The role metrics are selected from their aggregates by service
+    {`SELECT LAST(avg_latency) WHERE roleType="SERVER" AND serviceType="ZOOKEEPER"`, SCOPE_SERVICE,
+      `SELECT LAST(avg_latency_across_servers) WHERE serviceType="ZOOKEEPER" AND category=SERVICE`, true},
+    {`SELECT LAST((100 * dfs_used) / dfs_capacity) WHERE category=ROLE AND roleType=DATANODE and hostname="node1" AND clusterName="c1"`, SCOPE_SERVICE,
+      `SELECT LAST((100 * dfs_used_across_datanodes) / dfs_capacity_across_datanodes) WHERE clusterName="c1" AND category=SERVICE`, true},
+    // Roles without a role type, or selected by a pattern, have no aggregate
+    {`SELECT LAST(fd_open) WHERE roleName="zookeeper-SERVER-1"`, SCOPE_SERVICE, "", false},
+    {`SELECT LAST(fd_open) WHERE roleType="SERVER" AND roleName rlike "zookeeper-.*"`, SCOPE_SERVICE, "", false},
+    {`SELECT LAST(fd_open) WHERE roleType="SERVER" OR roleType="GATEWAY"`, SCOPE_SERVICE, "", false},
+    // The service metrics have no aggregate by cluster to rewrite them to
+    {`SELECT LAST(alerts_rate) WHERE category="SERVICE" AND serviceName="ZOOKEEPER"`, SCOPE_CLUSTER, "", false},
+    {`SELECT LAST(avg_latency) WHERE roleType="SERVER"`, SCOPE_CLUSTER, "", false},
+  } {
+    got, ok := Rescope_tsquery(c.query, c.scope)
+    if got != c.want || ok != c.ok {
+      t.Errorf("Rescope_tsquery(%s, %s) = %q, %v, want %q, %v", c.query, c.scope, got, ok, c.want, c.ok)
+    }
+  }
+}
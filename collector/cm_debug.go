@@ -0,0 +1,112 @@
+/*
+ *
+ * title           :collector/cm_debug.go
+ * description     :Record of the last TimeSeries queries made to Cloudera
+ *                  Manager, with their URL, raw response and timing, for
+ *                  the /debug/cm endpoint
+ * author          :Enes Erdoğan
+ * date            :2025/10/20
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "sort"
+  "sync"
+  "time"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Max size of the raw response kept for each query (256 KiB)
+const CM_DEBUG_MAX_RESPONSE = 256 << 10
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Last execution of a TimeSeries query: the URL built, the raw response and
+// the time it took to fetch it
+type Cm_debug_query struct {
+  Query string `json:"query"`
+  Collector string `json:"collector"`
+  Url string `json:"url"`
+  Fetched_at time.Time `json:"fetched_at"`
+  Fetch_seconds float64 `json:"fetch_seconds"`
+  Error string `json:"error,omitempty"`
+  Response string `json:"response"`
+  Response_truncated bool `json:"response_truncated,omitempty"`
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Last execution of each TimeSeries query, by tsquery
+var cm_debug = struct {
+  sync.Mutex
+  queries map[string]Cm_debug_query
+}{queries: map[string]Cm_debug_query{}}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Record the execution of the TimeSeries query if the debug endpoint is
+// enabled. Nothing is kept otherwise
+func record_cm_debug(ctx context.Context, config Collector_connection_data, query string, uri string, response string, started time.Time, err error) {
+  if !config.Cm_debug {
+    return
+  }
+  entry := Cm_debug_query {
+    Query: query,
+    Collector: get_collector_name(ctx),
+    Url: uri,
+    Fetched_at: started,
+    Fetch_seconds: time.Since(started).Seconds(),
+    Response: response,
+  }
+  if err != nil {
+    entry.Error = err.Error()
+  }
+  if len(entry.Response) > CM_DEBUG_MAX_RESPONSE {
+    entry.Response, entry.Response_truncated = entry.Response[:CM_DEBUG_MAX_RESPONSE], true
+  }
+
+  cm_debug.Lock()
+  cm_debug.queries[query] = entry
+  cm_debug.Unlock()
+}
+
+
+// Returns the last execution of the TimeSeries queries, sorted by query
+func Get_cm_debug_queries() []Cm_debug_query {
+  cm_debug.Lock()
+  queries := make([]Cm_debug_query, 0, len(cm_debug.queries))
+  for _, entry := range cm_debug.queries {
+    queries = append(queries, entry)
+  }
+  cm_debug.Unlock()
+
+  sort.Slice(queries, func(i, j int) bool { return queries[i].Query < queries[j].Query })
+  return queries
+}
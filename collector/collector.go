@@ -17,6 +17,12 @@ package collector
 import (
   // Go Default libraries
   "context"
+  "fmt"
+  "sync"
+  "time"
+
+  // Own libraries
+  cm "keedio/cloudera_exporter/cm_client"
 
   // Go Prometheus libraries
   "github.com/prometheus/client_golang/prometheus"
@@ -41,8 +47,39 @@ type Collector_connection_data struct {
   Host string
   Port string
   Api_version string
+  Api_version_pinned bool
   User string
   Passwd string
+  Credentials *cm.Credentials
+  Max_role_series int
+  Latency_mode string
+  Latency_buckets []float64
+  Config_snapshot_interval time.Duration
+  Maintenance_label bool
+  Health_rates bool
+  Events_lookback time.Duration
+  Znode_prefixes []string
+  Znode_walk_interval time.Duration
+  Znode_client_port uint
+  Derived_metrics []Derived_metric
+  Custom_metrics []Custom_metric
+  Entity_labels []Entity_label
+  Timeseries_window time.Duration
+  Desired_rollup string
+  Must_use_desired_rollup bool
+  Rollup_statistic string
+  Honor_timestamps bool
+  Max_sample_age time.Duration
+  Timeout_retries int
+  Validate_metrics bool
+  No_data string
+  Legacy_metric_names bool
+  // Keep the last raw responses of the TimeSeries queries for /debug/cm
+  Cm_debug bool
+  // Share of the collection of this replica
+  Shard Shard
+  Http_client *cm.Http_client
+  Cluster_endpoints map[string]*cm.Cluster_endpoint
 }
 
 type Collector struct {
@@ -50,6 +87,8 @@ type Collector struct {
 	config   Collector_connection_data
 	scrapers []Scraper
 	metrics  Metrics
+	// Guards the scrapers, which can be registered while collecting
+	lock     sync.RWMutex
 }
 
 
@@ -63,26 +102,71 @@ func New(ctx context.Context, config Collector_connection_data, metrics Metrics,
 	return &Collector{
 		ctx:      ctx,
 		config:   config,
-		scrapers: scrapers,
+		scrapers: append([]Scraper(nil), scrapers...),
 		metrics:  metrics,
 	}
 }
 
 
+// RegisterScraper adds a scraper to the collector, so programs embedding it
+// can collect their own metrics with the Cloudera Manager client, the
+// discovery cache and the self-metrics of the exporter. Names must be unique
+func (c *Collector) RegisterScraper(scraper Scraper) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, registered := range c.scrapers {
+		if registered.Name() == scraper.Name() {
+			return fmt.Errorf("Scraper %s already registered", scraper.Name())
+		}
+	}
+	c.scrapers = append(c.scrapers, scraper)
+	return nil
+}
+
+
+// Scrapers returns the scrapers of the collector
+func (c *Collector) Scrapers() []Scraper {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return append([]Scraper(nil), c.scrapers...)
+}
+
+
 // Describe implements prometheus.Collector.
 func (c *Collector) Describe (ch chan<- *prometheus.Desc) {
 	ch <- c.metrics.TotalScrapes.Desc()
 	ch <- c.metrics.Error.Desc()
 	c.metrics.ScrapeErrors.Describe(ch)
 	ch <- c.metrics.CMUp.Desc()
+	ch <- standbyDesc
+	ch <- featureFlagDesc
+	timeseries_queries_total.Describe(ch)
+	timeseries_datapoints_total.Describe(ch)
+	timeseries_timeout_retries_total.Describe(ch)
+	metric_validation_violations_total.Describe(ch)
+	c.config.Http_client.Describe(ch)
 }
 
 
 // Collect implements prometheus.Collector.
 func (c *Collector) Collect (ch chan<- prometheus.Metric) {
-	c.scrape(c.ctx, ch)
+	ch, hooks_done := withEmitHooks(ch)
+	defer hooks_done()
+
+	// Cloudera Manager is not queried in standby
+	in_standby := Is_standby()
+	collect_standby(ch, in_standby)
+	collect_feature_flags(ch)
+	if !in_standby {
+		c.scrape(c.ctx, ch)
+	}
 	ch <- c.metrics.TotalScrapes
 	ch <- c.metrics.Error
 	c.metrics.ScrapeErrors.Collect(ch)
 	ch <- c.metrics.CMUp
+	timeseries_queries_total.Collect(ch)
+	timeseries_datapoints_total.Collect(ch)
+	timeseries_timeout_retries_total.Collect(ch)
+	metric_validation_violations_total.Collect(ch)
+	c.config.Http_client.Collect(ch)
 }
@@ -20,12 +20,16 @@ import (
 	"context"
 	"net/http"
   "errors"
-	"io/ioutil"
   "fmt"
+  "regexp"
+  "sort"
   "strconv"
   "strings"
+  "sync"
+  "time"
 
   // Own libraries
+  cm "keedio/cloudera_exporter/cm_client"
   jp "keedio/cloudera_exporter/json_parser"
   log "keedio/cloudera_exporter/logger"
 
@@ -44,6 +48,18 @@ const MASTER_POS = 0
 const BORDER_POS = 1
 const WORKER_POS = 2
 
+// Min time between two renegotiations of the API version after 400 or 404
+// responses that do not say that the version is unsupported, as most of them
+// are queries of resources that do not exist
+const API_VERSION_RENEGOTIATION_INTERVAL = 10 * time.Minute
+
+// Min time between two renegotiations of the API version after responses
+// that say that the version is unsupported
+const UNSUPPORTED_API_VERSION_RENEGOTIATION_INTERVAL = 30 * time.Second
+
+// Format of the Cloudera Manager API versions (v19, v41...)
+var api_version_regex = regexp.MustCompile(`^v[0-9]+$`)
+
 
 
 
@@ -53,67 +69,127 @@ const WORKER_POS = 2
 // Structure to relate the sentence of TSquery with its metric of Prometheus
 type relation struct {
   Query string
-  Metric_struct prometheus.Desc
+  Metric_struct *prometheus.Desc
 }
 
+// Fully-qualified name and help of a Prometheus descriptor, as
+// prometheus.Desc does not expose them
+type desc_info struct {
+  fq_name string
+  help string
+}
+
+
+
 
 /* ======================================================================
- * Functions
+ * Global variables
  * ====================================================================== */
- // Make the query specified to the Cloudera Manager API and returns the JSON response
-func make_query(ctx context.Context, uri string, user string, passwd string) (body string, err error) {
-  log.Debug_msg("Making API Query: %s ", uri)
+// API version negotiated with Cloudera Manager. Shared by all the scrapes
+var negotiated_api_version struct {
+  sync.RWMutex
+  version string
+  renegotiated_at time.Time
+}
 
-  // Get HTTP Protocol Client
-  httpClient := http.DefaultClient
+// Descriptors created with new_desc, by their name, help and labels, and
+// their fully-qualified names and help
+var descs struct {
+  sync.RWMutex
+  by_key map[string]*prometheus.Desc
+  infos map[*prometheus.Desc]desc_info
+}
 
-  // Build the request Object
-  req, err := http.NewRequest(http.MethodGet, uri, nil)
 
-  if err != nil {
-    log.Err_msg("Building Request for URL:%s, Failed. Error: %s", uri, err)
-    return "", err
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Make the query specified to the Cloudera Manager API and returns the JSON response.
+// The resources of a cluster with an endpoint override are requested with its
+// base URL, authentication and TLS settings
+func make_query(ctx context.Context, config Collector_connection_data, uri string) (body string, err error) {
+  defer record_phase(ctx, PHASE_HTTP, time.Now())
+
+  // Current credentials, as they are refreshed when the secrets are rotated
+  user, passwd := config.User, config.Passwd
+  if config.Credentials != nil {
+    user, passwd = config.Credentials.Get()
   }
 
-  // Overwrite request with timeout context.
-  if ctx != nil {
-    req = req.WithContext(ctx)
+  client, auth := config.Http_client, cm.Auth{Module: cm.AUTH_BASIC, User: user, Passwd: passwd}
+  if endpoint, ok := config.Cluster_endpoints[cm.Get_uri_cluster(uri)]; ok {
+    if uri, err = cm.Rebase_url(uri, endpoint.Base_url); err != nil {
+      return "", err
+    }
+    // The credentials not set for the cluster are the ones of the user
+    client, auth = endpoint.Http_client, endpoint.Auth
+    if auth.User == "" {
+      auth.User = user
+    }
+    if auth.Passwd == "" {
+      auth.Passwd = passwd
+    }
   }
+  return cm.Get(ctx, client, uri, auth)
+}
 
-  // Request response header
-  req.Header.Add("Content-Type", "application/json")
 
-  // Set Authentication credentials
-  req.SetBasicAuth(user, passwd)
+// Returns a Prometheus descriptor, and keeps its fully-qualified name and
+// help. The same descriptor is returned for the
+// same name, help and labels, so the ones created on each scrape are kept
+// once
+func new_desc(fq_name string, help string, variable_labels []string, const_labels prometheus.Labels) *prometheus.Desc {
+  const_label_names := make([]string, 0, len(const_labels))
+  for name := range const_labels {
+    const_label_names = append(const_label_names, name)
+  }
+  sort.Strings(const_label_names)
+  key := []string{fq_name, help, strings.Join(variable_labels, ",")}
+  for _, name := range const_label_names {
+    key = append(key, name + "=" + const_labels[name])
+  }
+  desc_key := strings.Join(key, "\xff")
 
-  // Make the API request
-  res, err := httpClient.Do(req)
-  if err != nil {
-    log.Err_msg("%s", err)
-    return "" ,err
+  descs.RLock()
+  desc, ok := descs.by_key[desc_key]
+  descs.RUnlock()
+  if ok {
+    return desc
   }
-  if res == nil {
-    log.Err_msg("HTTP response is NULL")
-    return "", errors.New("HTTP response is NULL")
+
+  descs.Lock()
+  defer descs.Unlock()
+  if desc, ok := descs.by_key[desc_key]; ok {
+    return desc
   }
-  if res.StatusCode < 200 || res.StatusCode >= 400 {
-    log.Err_msg("Invalid HTTP response code: %s for the request: %s", res.Status, uri)
-    res.Body.Close()
-    return "" , errors.New("Invalid HTTP response code")
+  if descs.by_key == nil {
+    descs.by_key = make(map[string]*prometheus.Desc)
+    descs.infos = make(map[*prometheus.Desc]desc_info)
   }
+  desc = prometheus.NewDesc(fq_name, help, variable_labels, const_labels)
+  descs.by_key[desc_key] = desc
+  descs.infos[desc] = desc_info{fq_name, help}
+  return desc
+}
 
-  // Get Body Response
-  content, err := ioutil.ReadAll(res.Body)
 
-  if err != nil {
-    log.Err_msg("Failed to parse response with error: %s", err)
-    res.Body.Close()
-    return "" ,err
-  }
+// Returns the fully-qualified name of a Prometheus descriptor, or "" if it
+// was not created with new_desc
+func get_desc_fq_name(desc *prometheus.Desc) string {
+  descs.RLock()
+  defer descs.RUnlock()
+  return descs.infos[desc].fq_name
+}
 
-  res.Body.Close()
 
-  return string(content), err
+// Returns the help of a Prometheus descriptor, or "" if it was not created
+// with new_desc
+func get_desc_help(desc *prometheus.Desc) string {
+  descs.RLock()
+  defer descs.RUnlock()
+  return descs.infos[desc].help
 }
 
 
@@ -124,13 +200,12 @@ func init_host_types_map(ctx context.Context, config Collector_connection_data)
   // Get Hosts list
   json_hosts_data, _ := make_query(
     ctx,
+    config,
     jp.Build_api_query_url(
       config.Host,
       config.Port,
-      config.Api_version,
+      get_api_version(config),
       fmt.Sprintf("hosts")),
-    config.User,
-    config.Passwd,
   )
   json_hosts_results := jp.Parse_json_response(json_hosts_data)
   num_hosts, _ := strconv.Atoi(jp.Get_json_field(json_hosts_results, "items.#"))
@@ -146,13 +221,12 @@ func init_host_types_map(ctx context.Context, config Collector_connection_data)
 func look_for_border_nodes(ctx context.Context, config Collector_connection_data, cluster_name string, node_map map[string] []string) map[string] []string {
   json_type_data, _ := make_query(
     ctx,
+    config,
     jp.Build_api_query_url(
       config.Host,
       config.Port,
-      config.Api_version,
+      get_api_version(config),
       fmt.Sprintf("clusters/%s/services/hdfs/roles", cluster_name)),
-    config.User,
-    config.Passwd,
   )
 
   // Parse JSON Response
@@ -176,13 +250,12 @@ func look_for_border_nodes(ctx context.Context, config Collector_connection_data
 func look_for_worker_nodes(ctx context.Context, config Collector_connection_data, cluster_name string, node_map map[string] []string) map[string] []string {
   json_type_data, _ := make_query(
     ctx,
+    config,
     jp.Build_api_query_url(
       config.Host,
       config.Port,
-      config.Api_version,
+      get_api_version(config),
       fmt.Sprintf("clusters/%s/services/hdfs/roles", cluster_name)),
-    config.User,
-    config.Passwd,
   )
 
   // Parse JSON Response
@@ -205,13 +278,12 @@ func look_for_worker_nodes(ctx context.Context, config Collector_connection_data
 func look_for_master_nodes(ctx context.Context, config Collector_connection_data, cluster_name string, node_map map[string] []string) map[string] []string {
   json_master_data, _ := make_query(
     ctx,
+    config,
     jp.Build_api_query_url(
       config.Host,
       config.Port,
-      config.Api_version,
+      get_api_version(config),
       fmt.Sprintf("cm/service/roles")),
-    config.User,
-      config.Passwd,
   )
 
   // Parse JSON Response
@@ -232,18 +304,20 @@ func look_for_master_nodes(ctx context.Context, config Collector_connection_data
 
 // fill and return the role map of hosts
 func get_type_node_list (ctx context.Context, config Collector_connection_data) map[string] []string {
+  ctx, stop := start_outer_phase(ctx, PHASE_DISCOVERY)
+  defer stop()
+
   node_map := init_host_types_map(ctx, config)
 
   // Get Cluster list
   json_clusters_data, _ := make_query(
     ctx,
+    config,
     jp.Build_api_query_url(
       config.Host,
       config.Port,
-      config.Api_version,
+      get_api_version(config),
       fmt.Sprintf("clusters")),
-    config.User,
-    config.Passwd,
   )
 
   // Parse JSON Response
@@ -278,43 +352,137 @@ func get_if_is_worker (host_id string) string {
 }
 
 
-// Make the query and parse the json response.
-func make_and_parse_timeseries_query(ctx context.Context, config Collector_connection_data, query string) (result gjson.Result, err error) {
+// Make the query and parse the json response. If the query times out, it is
+// retried with a shorter window and a coarser rollup. The queries of other
+// shards are not made
+func make_and_parse_timeseries_query(ctx context.Context, config Collector_connection_data, query string) (result jp.Timeseries_response, err error) {
+  if !get_shard(ctx).owns(query) {
+    return result, error_not_in_shard
+  }
+  result, err = make_and_parse_timeseries_window_query(ctx, config, query, encode_timeseries_window(config))
+  if is_timeout_error(ctx, err) {
+    return retry_timed_out_timeseries_query(ctx, config, query, err)
+  }
+  return result, err
+}
+
+
+// Make the query with the given time window and rollup parameters and parse
+// the json response.
+func make_and_parse_timeseries_window_query(ctx context.Context, config Collector_connection_data, query string, window string) (result jp.Timeseries_response, err error) {
+  // Build the query URL with the last API version negotiated
+  build_start := time.Now()
+  config.Api_version = get_api_version(config)
+  uri := jp.Build_timeseries_api_query_url(
+    config.Host,
+    config.Port,
+    config.Api_version,
+    jp.Encode_tsquery_to_http(query) + window)
+  record_phase(ctx, PHASE_QUERY_BUILD, build_start)
+
   // Make query
-  json_timeseries, err := make_query(
-    ctx,
-    jp.Build_timeseries_api_query_url(
-      config.Host,
-      config.Port,
-      config.Api_version,
-      jp.Encode_tsquery_to_http(query)),
-    config.User,
-    config.Passwd,
-  )
+  fetch_start := time.Now()
+  json_timeseries, err := make_query(ctx, config, uri)
+  count_timeseries_query(ctx)
+  record_cm_debug(ctx, config, query, uri, json_timeseries, fetch_start, err)
+
+  // Retry with the new API version if Cloudera Manager has been upgraded
+  if renegotiate_api_version(ctx, &config, err) {
+    return make_and_parse_timeseries_window_query(ctx, config, query, window)
+  }
 
   // parse and return the result
   if err != nil {
     log.Err_msg("Error making query: %s", err)
   }
-  return jp.Parse_json_response(json_timeseries), err
+  decode_start := time.Now()
+  json_parsed := jp.Parse_timeseries_response(json_timeseries)
+  record_phase(ctx, PHASE_DECODE, decode_start)
+  count_timeseries_datapoints(ctx, json_parsed)
+  for _, warning := range jp.Get_timeseries_warnings(json_parsed) {
+    log.Warn_msg("Cloudera Manager warning for the query %s: %s", query, warning)
+  }
+  return json_parsed, err
+}
+
+
+// Compose the time window and rollup parameters of the TimeSeries Queries.
+// The window ends at the time of the query
+func encode_timeseries_window(config Collector_connection_data) string {
+  var from, to time.Time
+  if config.Timeseries_window > 0 {
+    to = time.Now()
+    from = to.Add(-config.Timeseries_window)
+  }
+  return jp.Encode_timeseries_window(from, to, config.Desired_rollup, config.Must_use_desired_rollup)
+}
+
+
+// Returns the value of the most recent datapoint of a TimeSerie, or the
+// configured statistic if it is a rollup
+func get_timeseries_value(config Collector_connection_data, json_parsed jp.Timeseries_response, serie_index int) (float64, error) {
+  statistic := config.Rollup_statistic
+  if statistic == "" {
+    statistic = jp.ROLLUP_STATISTIC_VALUE
+  }
+  return jp.Get_timeseries_query_rollup_value(json_parsed, serie_index, statistic)
+}
+
+
+// Returns the value and the timestamp of the most recent datapoint of a
+// TimeSerie. The TimeSeries without datapoints or with the datapoint older
+// than the max age follow the no data behavior
+func get_timeseries_sample(config Collector_connection_data, json_parsed jp.Timeseries_response, serie_index int) (float64, time.Time, error) {
+  if jp.Get_timeseries_query_datapoints_num(json_parsed, serie_index) == 0 {
+    log.Debug_msg("No datapoints for %s", jp.Get_timeseries_query_entity_name(json_parsed, serie_index))
+    return no_data_sample(config, errors.New("No timeseries datapoints"))
+  }
+  value, err := get_timeseries_value(config, json_parsed, serie_index)
+  if err != nil {
+    return value, time.Time{}, err
+  }
+  timestamp, err := jp.Get_timeseries_query_timestamp(json_parsed, serie_index)
+  if err != nil {
+    // Datapoints without timestamp are neither dropped nor timestamped
+    return value, time.Time{}, nil
+  }
+  if config.Max_sample_age > 0 && time.Since(timestamp) > config.Max_sample_age {
+    log.Debug_msg("Dropping the datapoint of %s: %s older than %s", jp.Get_timeseries_query_entity_name(json_parsed, serie_index), timestamp, config.Max_sample_age)
+    return no_data_sample(config, errors.New("Stale timeseries datapoint"))
+  }
+  return value, timestamp, nil
+}
+
+
+// Returns a gauge with the timestamp reported by Cloudera Manager if the
+// timestamps are honored, else the scrape time is used by Prometheus
+func new_timeseries_metric(config Collector_connection_data, desc *prometheus.Desc, value float64, timestamp time.Time, label_values ...string) prometheus.Metric {
+  metric := prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, label_values...)
+  if config.Honor_timestamps && !timestamp.IsZero() {
+    return prometheus.NewMetricWithTimestamp(timestamp, metric)
+  }
+  return metric
 }
 
 
 // Make and parse a Cloudera API Query
 func make_and_parse_api_query(ctx context.Context, config Collector_connection_data, query string) (result gjson.Result, err error) {
+  // Build the query URL with the last API version negotiated
+  build_start := time.Now()
+  config.Api_version = get_api_version(config)
+  uri := jp.Build_api_query_url(config.Host, config.Port, config.Api_version, query)
+  record_phase(ctx, PHASE_QUERY_BUILD, build_start)
+
   // Make query
-  json_timeseries, err := make_query(
-    ctx,
-    jp.Build_api_query_url(
-      config.Host,
-      config.Port,
-      config.Api_version,
-      query),
-    config.User,
-    config.Passwd,
-  )
+  json_timeseries, err := make_query(ctx, config, uri)
+
+  // Retry with the new API version if Cloudera Manager has been upgraded
+  if renegotiate_api_version(ctx, &config, err) {
+    return make_and_parse_api_query(ctx, config, query)
+  }
 
   // parse and return the result
+  defer record_phase(ctx, PHASE_DECODE, time.Now())
   return jp.Parse_json_response(json_timeseries), err
 }
 
@@ -335,12 +503,79 @@ func Get_api_cloudera_version(ctx context.Context, config Collector_connection_d
   // Make query
   json_parsed, err := make_query(
     ctx,
+    config,
     fmt.Sprintf("http://%s:%s/api/version", config.Host, config.Port),
-    config.User,
-    config.Passwd,
   )
   if err != nil {
     return "", errors.New("The exporter can not determine the API version by consulting the cloudera Manager API")
   }
-  return json_parsed, nil
+
+  // Cloudera Manager answers with the highest version it supports (vXX)
+  api_version := strings.TrimSpace(json_parsed)
+  if !api_version_regex.MatchString(api_version) {
+    return "", fmt.Errorf("Unexpected API version returned by Cloudera Manager: %q", api_version)
+  }
+
+  // Cache the negotiated version
+  negotiated_api_version.Lock()
+  negotiated_api_version.version = api_version
+  negotiated_api_version.Unlock()
+  return api_version, nil
+}
+
+
+// Returns the cached API version negotiated with Cloudera Manager or the
+// configured one if the version is pinned or has not been negotiated yet
+func get_api_version(config Collector_connection_data) string {
+  if config.Api_version_pinned {
+    return config.Api_version
+  }
+  negotiated_api_version.RLock()
+  defer negotiated_api_version.RUnlock()
+  if negotiated_api_version.version == "" {
+    return config.Api_version
+  }
+  return negotiated_api_version.version
+}
+
+
+// Negotiate again the API version if Cloudera Manager does not support the
+// one of the query, what happens when it is upgraded or downgraded. If
+// another query already negotiated a new version, the query is retried with
+// it without asking Cloudera Manager. Otherwise the version is renegotiated
+// at most once every UNSUPPORTED_API_VERSION_RENEGOTIATION_INTERVAL, or once
+// every API_VERSION_RENEGOTIATION_INTERVAL for the other 400 and 404
+// responses, in case the message is not the expected one. Returns true if
+// the version changed and the query has to be retried
+func renegotiate_api_version(ctx context.Context, config *Collector_connection_data, query_err error) bool {
+  if config.Api_version_pinned {
+    return false
+  }
+  if !cm.Is_http_status(query_err, http.StatusNotFound) && !cm.Is_http_status(query_err, http.StatusBadRequest) {
+    return false
+  }
+  negotiated_api_version.Lock()
+  if negotiated_api_version.version != "" && negotiated_api_version.version != config.Api_version {
+    config.Api_version = negotiated_api_version.version
+    negotiated_api_version.Unlock()
+    return true
+  }
+  interval := API_VERSION_RENEGOTIATION_INTERVAL
+  if cm.Is_unsupported_api_version(query_err) {
+    interval = UNSUPPORTED_API_VERSION_RENEGOTIATION_INTERVAL
+  }
+  if time.Since(negotiated_api_version.renegotiated_at) < interval {
+    negotiated_api_version.Unlock()
+    return false
+  }
+  negotiated_api_version.renegotiated_at = time.Now()
+  negotiated_api_version.Unlock()
+
+  api_version, err := Get_api_cloudera_version(ctx, *config)
+  if err != nil || api_version == config.Api_version {
+    return false
+  }
+  log.Warn_msg("Cloudera Manager API version changed from %s to %s", config.Api_version, api_version)
+  config.Api_version = api_version
+  return true
 }
@@ -0,0 +1,126 @@
+/*
+ *
+ * title           :collector/config_validation.go
+ * description     :Validation at config load of the metrics and labels
+ *                  defined in the config file, so colliding series are
+ *                  rejected instead of failing the scrapes
+ * author          :Enes Erdoğan
+ * date            :2025/10/06
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "errors"
+  "fmt"
+  "strings"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/common/model"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Owners of the metric names and labels found in the configuration, and the
+// collisions between them
+type series_validation struct {
+  metric_owners map[string]string
+  const_labels map[string]string
+  collisions []string
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Record the metric name for its owner, or the collision with the previous
+// owner of the name
+func (v *series_validation) claim_metric(name string, owner string) {
+  if !model.IsValidMetricName(model.LabelValue(name)) {
+    v.collisions = append(v.collisions, fmt.Sprintf("%s has the invalid metric name %q", owner, name))
+    return
+  }
+  if previous, ok := v.metric_owners[name]; ok {
+    v.collisions = append(v.collisions, fmt.Sprintf("%s and %s both export the metric %s", previous, owner, name))
+    return
+  }
+  v.metric_owners[name] = owner
+}
+
+
+// Record the collision of the label with the reserved names (__*) or with
+// the constant labels added to every metric
+func (v *series_validation) check_label(label string, owner string) {
+  switch {
+  case strings.HasPrefix(label, model.ReservedLabelPrefix):
+    v.collisions = append(v.collisions, fmt.Sprintf("%s has the label %q, reserved for Prometheus (%s prefix)", owner, label, model.ReservedLabelPrefix))
+  case v.const_labels[label] != "":
+    v.collisions = append(v.collisions, fmt.Sprintf("%s has the label %q, which is also an identity label of the exporter", owner, label))
+  }
+}
+
+
+// Check that the metrics and labels defined in the config file (custom and
+// derived metrics, entity labels) don't collide with each other, with the
+// built-in metrics or with the constant labels added to every metric. Such
+// series would fail every scrape, so the configuration is rejected with all
+// the collisions found
+func Validate_series(config Collector_connection_data, const_labels map[string]string) error {
+  v := &series_validation{metric_owners: map[string]string{}, const_labels: const_labels}
+
+  // Built-in metrics of the TimeSeries modules, with their exported names
+  for name := range get_metric_queries() {
+    if rule, ok := get_unit_rule(config, name); ok {
+      name = rule.Name
+    }
+    v.metric_owners[name] = "the built-in metric"
+  }
+
+  for _, custom := range config.Custom_metrics {
+    owner := fmt.Sprintf("the custom metric %q", custom.Name)
+    fq_name := get_desc_fq_name(custom.Desc)
+    v.claim_metric(fq_name, owner)
+    if config.No_data == NO_DATA_PRESENT {
+      v.claim_metric(fq_name + present_suffix, owner)
+    }
+    for _, label := range custom.Labels {
+      v.check_label(label, owner)
+    }
+  }
+
+  for _, derived := range config.Derived_metrics {
+    v.claim_metric(prometheus.BuildFQName(namespace, DERIVED_SUBSYSTEM, derived.Name), fmt.Sprintf("the derived metric %q", derived.Name))
+  }
+
+  // Two attributes can't be added as the same label
+  entity_label_owners := map[string]string{}
+  for _, entity_label := range config.Entity_labels {
+    owner := fmt.Sprintf("the entity attribute %q", entity_label.Attribute)
+    if previous, ok := entity_label_owners[entity_label.Label]; ok {
+      v.collisions = append(v.collisions, fmt.Sprintf("%s and %s are both added as the label %q", previous, owner, entity_label.Label))
+      continue
+    }
+    entity_label_owners[entity_label.Label] = owner
+    v.check_label(entity_label.Label, owner)
+  }
+
+  if len(v.collisions) > 0 {
+    return errors.New("Colliding series in the config file: " + strings.Join(v.collisions, "; "))
+  }
+  return nil
+}
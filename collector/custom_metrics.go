@@ -0,0 +1,186 @@
+/*
+ *
+ * title           :collector/custom_metrics.go
+ * description     :Metrics defined in the config file with a raw TimeSeries
+ *                  query (tsquery)
+ * author          :Enes Erdoğan
+ * date            :2025/04/14
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "fmt"
+  "strings"
+
+  // Own libraries
+  jp "keedio/cloudera_exporter/json_parser"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/common/model"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const CUSTOM_SCRAPER_NAME = "custom"
+
+// Labels of the custom metrics without labels in the config file, as the
+// built-in metrics
+const CUSTOM_DEFAULT_LABELS = "cluster=clusterName, entityName"
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Metric collected with a tsquery defined in the config file
+type Custom_metric struct {
+  Name string
+  Query string
+  // Label names and the TimeSeries metadata attribute of each one
+  Labels []string
+  Attributes []string
+  Desc *prometheus.Desc
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Compile a custom metric. The labels are a comma separated list of
+// <label>=<attribute> pairs, or attribute names used as label names too. The
+// attributes are the metadata attributes of the TimeSeries (clusterName,
+// serviceName, roleName, hostname...)
+func New_custom_metric(name string, help string, labels string, query string) (Custom_metric, error) {
+  fq_name := prometheus.BuildFQName(namespace, CUSTOM_SCRAPER_NAME, name)
+  if !model.IsValidMetricName(model.LabelValue(fq_name)) {
+    return Custom_metric{}, fmt.Errorf("Invalid metric name %q", fq_name)
+  }
+  if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT ") {
+    return Custom_metric{}, fmt.Errorf("Invalid tsquery %q: it must be a SELECT statement", query)
+  }
+  if help == "" {
+    help = "Custom metric: " + query
+  }
+  if strings.TrimSpace(labels) == "" {
+    labels = CUSTOM_DEFAULT_LABELS
+  }
+
+  label_names := []string{}
+  attributes := []string{}
+  for _, label := range strings.Split(labels, ",") {
+    label_name, attribute := strings.TrimSpace(label), strings.TrimSpace(label)
+    if pair := strings.SplitN(label, "=", 2); len(pair) == 2 {
+      label_name, attribute = strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+    }
+    if !model.LabelName(label_name).IsValid() || attribute == "" {
+      return Custom_metric{}, fmt.Errorf("Invalid label %q", label)
+    }
+    for _, l := range label_names {
+      if l == label_name {
+        return Custom_metric{}, fmt.Errorf("Duplicated label %q", label_name)
+      }
+    }
+    label_names = append(label_names, label_name)
+    attributes = append(attributes, attribute)
+  }
+
+  return Custom_metric{
+    Name: name,
+    Query: query,
+    Labels: label_names,
+    Attributes: attributes,
+    Desc: new_desc(fq_name, help, label_names, nil),
+  }, nil
+}
+
+
+// Make the query of the custom metric and send a metric for each TimeSerie
+func create_custom_metric(ctx context.Context, config Collector_connection_data, custom Custom_metric, ch chan<- prometheus.Metric) bool {
+  json_parsed, err := make_and_parse_timeseries_query(ctx, config, custom.Query)
+  if err != nil {
+    return false
+  }
+
+  num_ts_series, err := jp.Get_timeseries_num(json_parsed)
+  if err != nil {
+    return false
+  }
+
+  for ts_index := 0; ts_index < num_ts_series; ts_index ++ {
+    label_values := make([]string, len(custom.Attributes))
+    for i, attribute := range custom.Attributes {
+      label_values[i] = jp.Get_timeseries_query_attribute(json_parsed, ts_index, attribute)
+    }
+    value, timestamp, err := get_timeseries_sample(config, json_parsed, ts_index)
+    emit_present_metric(ch, config, custom.Desc, err == nil, label_values...)
+    if err != nil {
+      continue
+    }
+    ch <- with_entity_labels(config, json_parsed, ts_index, new_timeseries_metric(config, custom.Desc, value, timestamp, label_values...))
+  }
+  return true
+}
+
+
+
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+// ScrapeCustomMetrics struct
+type ScrapeCustomMetrics struct{}
+
+// Name of the Scraper. Should be unique.
+func (ScrapeCustomMetrics) Name() string {
+  return CUSTOM_SCRAPER_NAME
+}
+
+// Help describes the role of the Scraper.
+func (ScrapeCustomMetrics) Help() string {
+  return "Custom Metrics defined in the config file"
+}
+
+// Version.
+func (ScrapeCustomMetrics) Version() float64 {
+  return 1.0
+}
+
+// Scrape collects the custom metrics
+func (ScrapeCustomMetrics) Scrape (ctx context.Context, config *Collector_connection_data, ch chan<- prometheus.Metric) error {
+  log.Debug_msg("Ejecutando Custom Metrics Scraper")
+
+  // Queries counters
+  success_queries := 0
+  error_queries := 0
+
+  for _, custom := range config.Custom_metrics {
+    if create_custom_metric(ctx, *config, custom, ch) {
+      success_queries += 1
+    } else {
+      error_queries += 1
+    }
+  }
+  log.Debug_msg("In the Custom Module has been executed %d queries. %d success and %d with errors", success_queries + error_queries, success_queries, error_queries)
+  return nil
+}
+
+var _ Scraper = ScrapeCustomMetrics{}
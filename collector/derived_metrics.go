@@ -0,0 +1,239 @@
+/*
+ *
+ * title           :collector/derived_metrics.go
+ * description     :Metrics defined in the config file and derived from the collected ones
+ * author          :Enes Erdoğan
+ * date            :2025/02/10
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "sort"
+  "strings"
+  "sync"
+
+  // Own libraries
+  ep "keedio/cloudera_exporter/expression_parser"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const DERIVED_SUBSYSTEM = "derived"
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Metric computed with an expression over the collected metrics
+type Derived_metric struct {
+  Name string
+  Expression *ep.Expression
+}
+
+// Value and labels of a collected metric
+type sample struct {
+  labels map[string]string
+  value float64
+}
+
+// Samples collected in a scrape, by metric name
+type sample_store struct {
+  sync.Mutex
+  samples map[string] []sample
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Compile a derived metric. The expression variables are the names of the
+// collected metrics (e.g. kbdi_zookeeper_current_xid)
+func New_derived_metric(name string, expression string) (Derived_metric, error) {
+  compiled, err := ep.Parse_expression(expression)
+  if err != nil {
+    return Derived_metric{}, err
+  }
+  return Derived_metric{name, compiled}, nil
+}
+
+
+func new_sample_store() *sample_store {
+  return &sample_store{samples: make(map[string] []sample)}
+}
+
+
+// Extract the labels and value of a metric and store them
+func (s *sample_store) add(metric prometheus.Metric) {
+  var m dto.Metric
+  if err := metric.Write(&m); err != nil {
+    return
+  }
+
+  var value float64
+  switch {
+  case m.Gauge != nil:
+    value = m.Gauge.GetValue()
+  case m.Counter != nil:
+    value = m.Counter.GetValue()
+  case m.Untyped != nil:
+    value = m.Untyped.GetValue()
+  default:
+    return
+  }
+
+  labels := make(map[string]string, len(m.Label))
+  for _, label := range m.Label {
+    labels[label.GetName()] = label.GetValue()
+  }
+
+  name := get_desc_fq_name(metric.Desc())
+  s.Lock()
+  s.samples[name] = append(s.samples[name], sample{labels, value})
+  s.Unlock()
+}
+
+
+// Key to match samples with the same label set
+func label_set_key(labels map[string]string) string {
+  pairs := make([]string, 0, len(labels))
+  for name, value := range labels {
+    pairs = append(pairs, name + "=" + value)
+  }
+  sort.Strings(pairs)
+  return strings.Join(pairs, ",")
+}
+
+
+// Labels with the same value in all the samples
+func common_labels(samples []sample) map[string]string {
+  labels := make(map[string]string)
+  for name, value := range samples[0].labels {
+    labels[name] = value
+  }
+  for _, s := range samples[1:] {
+    for name, value := range labels {
+      if s.labels[name] != value {
+        delete(labels, name)
+      }
+    }
+  }
+  return labels
+}
+
+
+// Evaluate the derived metric and send the results to the channel.
+// Variables are matched by identical label set. Variables with a single
+// sample act as scalars and match every label set
+func emit_derived_metric(derived Derived_metric, store *sample_store, ch chan<- prometheus.Metric) {
+  fq_name := prometheus.BuildFQName(namespace, DERIVED_SUBSYSTEM, derived.Name)
+  help := "Derived metric: " + derived.Expression.String()
+
+  scalars := make(map[string]float64)
+  vectors := make(map[string]map[string]sample)
+  var driver []sample
+  var singles []sample
+  for _, variable := range derived.Expression.Variables() {
+    samples := store.samples[variable]
+    switch len(samples) {
+    case 0:
+      log.Debug_msg("No samples of %s for the derived metric %s", variable, derived.Name)
+      return
+    case 1:
+      scalars[variable] = samples[0].value
+      singles = append(singles, samples[0])
+    default:
+      vectors[variable] = make(map[string]sample, len(samples))
+      for _, s := range samples {
+        vectors[variable][label_set_key(s.labels)] = s
+      }
+      if driver == nil {
+        driver = samples
+      }
+    }
+  }
+
+  // Only scalars (or constant expression): one sample with the common labels
+  if driver == nil {
+    labels := map[string]string{}
+    if len(singles) > 0 {
+      labels = common_labels(singles)
+    }
+    driver = []sample{{labels: labels}}
+  }
+
+  for _, d := range driver {
+    key := label_set_key(d.labels)
+    values := make(map[string]float64, len(scalars) + len(vectors))
+    for variable, value := range scalars {
+      values[variable] = value
+    }
+    matched := true
+    for variable, samples := range vectors {
+      s, ok := samples[key]
+      if !ok {
+        matched = false
+        break
+      }
+      values[variable] = s.value
+    }
+    if !matched {
+      continue
+    }
+
+    value, err := derived.Expression.Eval(values)
+    if err != nil {
+      log.Debug_msg("Cannot evaluate the derived metric %s: %s", derived.Name, err)
+      continue
+    }
+    // The labels are variable, so the descriptors are one per label names
+    // of the derived metric, not one per label set
+    label_names, label_values := sorted_labels(d.labels)
+    desc := new_desc(fq_name, help, label_names, nil)
+    ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, label_values...)
+  }
+}
+
+
+// Names of the labels sorted, and their values in the same order
+func sorted_labels(labels map[string]string) ([]string, []string) {
+  names := make([]string, 0, len(labels))
+  for name := range labels {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  values := make([]string, len(names))
+  for i, name := range names {
+    values[i] = labels[name]
+  }
+  return names, values
+}
+
+
+// Evaluate all the derived metrics
+func emit_derived_metrics(derived_metrics []Derived_metric, store *sample_store, ch chan<- prometheus.Metric) {
+  for _, derived := range derived_metrics {
+    emit_derived_metric(derived, store, ch)
+  }
+}
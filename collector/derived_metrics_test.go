@@ -0,0 +1,84 @@
+/*
+ *
+ * title           :collector/derived_metrics_test.go
+ * description     :Tests of the metrics derived from the collected ones
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "fmt"
+  "testing"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// The derived metrics of every label set share a descriptor, so the
+// descriptors don't grow with the label sets seen across the scrapes
+func TestEmit_derived_metric_descriptors(t *testing.T) {
+  derived, err := New_derived_metric("test_xid_per_epoch", "kbdi_test_xid / kbdi_test_epoch")
+  if err != nil {
+    t.Fatal(err)
+  }
+  xid_desc := new_desc("kbdi_test_xid", "Test XID", []string{"cluster", "entityName"}, nil)
+  epoch_desc := new_desc("kbdi_test_epoch", "Test epoch", []string{"cluster", "entityName"}, nil)
+
+  emit := func(scrape int) []prometheus.Metric {
+    store := new_sample_store()
+    for i := 0; i < 10; i++ {
+      entity := fmt.Sprintf("zookeeper-%d-%d", scrape, i)
+      store.add(prometheus.MustNewConstMetric(xid_desc, prometheus.GaugeValue, float64(4 * (i + 1)), "c1", entity))
+      store.add(prometheus.MustNewConstMetric(epoch_desc, prometheus.GaugeValue, 2, "c1", entity))
+    }
+    ch := make(chan prometheus.Metric, 10)
+    emit_derived_metric(derived, store, ch)
+    close(ch)
+    var metrics []prometheus.Metric
+    for metric := range ch {
+      metrics = append(metrics, metric)
+    }
+    return metrics
+  }
+
+  first := emit(0)
+  descs.RLock()
+  cached := len(descs.by_key)
+  descs.RUnlock()
+  for scrape := 1; scrape < 5; scrape++ {
+    emit(scrape)
+  }
+  descs.RLock()
+  defer descs.RUnlock()
+  if len(descs.by_key) != cached {
+    t.Errorf("%d descriptors after 5 scrapes of new label sets, want the %d of the first scrape", len(descs.by_key), cached)
+  }
+
+  if len(first) != 10 {
+    t.Fatalf("%d derived metrics, want 10", len(first))
+  }
+  var m dto.Metric
+  if err := first[1].Write(&m); err != nil {
+    t.Fatal(err)
+  }
+  if len(m.Label) != 2 || m.Label[0].GetValue() != "c1" || m.Label[1].GetValue() != "zookeeper-0-1" || m.Gauge.GetValue() != 4 {
+    t.Errorf("Got %s, want the labels of the samples and the value 4", m.String())
+  }
+}
@@ -0,0 +1,218 @@
+/*
+ *
+ * title           :collector/emit_hooks.go
+ * description     :Hooks invoked for every emitted sample, before exposition,
+ *                  to enrich, rename or veto it
+ * author          :Enes Erdoğan
+ * date            :2025/03/31
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "sort"
+    "sync"
+    "time"
+
+    // Own libraries
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Sample is a metric about to be exposed. Hooks may change any of its fields
+type Sample struct {
+    Name      string
+    Help      string
+    Labels    map[string]string
+    // Value of a gauge, counter or untyped metric, or sum of the
+    // observations of a histogram or summary
+    Value     float64
+    // Zero for histograms and summaries
+    Type      prometheus.ValueType
+    // Number of observations of a histogram or summary
+    Count     uint64
+    // Cumulative count of observations by upper bound of a histogram. Nil
+    // for the other metrics
+    Buckets   map[float64]uint64
+    // Value by quantile of a summary. Nil for the other metrics
+    Quantiles map[float64]float64
+    // Zero if the sample has no explicit timestamp
+    Timestamp time.Time
+}
+
+// EmitHook is invoked for every sample collected by the exporter, before it
+// is exposed. It may modify the sample, and returns false to drop it
+type EmitHook interface {
+    Process(sample *Sample) bool
+}
+
+// EmitHookFunc adapts a function to the EmitHook interface
+type EmitHookFunc func(sample *Sample) bool
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+var emitHooks struct {
+    sync.RWMutex
+    hooks []EmitHook
+}
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Process calls f(sample)
+func (f EmitHookFunc) Process(sample *Sample) bool {
+    return f(sample)
+}
+
+// RegisterEmitHook adds a hook. Hooks are invoked in registration order
+func RegisterEmitHook(hook EmitHook) {
+    emitHooks.Lock()
+    defer emitHooks.Unlock()
+    emitHooks.hooks = append(emitHooks.hooks, hook)
+}
+
+// getEmitHooks returns the registered hooks
+func getEmitHooks() []EmitHook {
+    emitHooks.RLock()
+    defer emitHooks.RUnlock()
+    return emitHooks.hooks
+}
+
+// withEmitHooks returns a channel that applies the registered hooks to the
+// metrics and forwards them to ch. The returned function must be called
+// once every metric has been sent
+func withEmitHooks(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+    hooks := getEmitHooks()
+    if len(hooks) == 0 {
+        return ch, func() {}
+    }
+
+    hooked := make(chan prometheus.Metric)
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for metric := range hooked {
+            if metric, ok := applyEmitHooks(hooks, metric); ok {
+                ch <- metric
+            }
+        }
+    }()
+    return hooked, func() {
+        close(hooked)
+        <-done
+    }
+}
+
+// newSample extracts the sample of a metric. The metrics whose descriptor was not created with new_desc have no known name
+// and are not sampled
+func newSample(metric prometheus.Metric) (*Sample, bool) {
+    name := get_desc_fq_name(metric.Desc())
+    if name == "" {
+        return nil, false
+    }
+    var m dto.Metric
+    if err := metric.Write(&m); err != nil {
+        return nil, false
+    }
+
+    sample := &Sample{
+        Name:   name,
+        Help:   get_desc_help(metric.Desc()),
+        Labels: make(map[string]string, len(m.Label)),
+    }
+    switch {
+    case m.Gauge != nil:
+        sample.Type, sample.Value = prometheus.GaugeValue, m.Gauge.GetValue()
+    case m.Counter != nil:
+        sample.Type, sample.Value = prometheus.CounterValue, m.Counter.GetValue()
+    case m.Untyped != nil:
+        sample.Type, sample.Value = prometheus.UntypedValue, m.Untyped.GetValue()
+    case m.Histogram != nil:
+        sample.Count, sample.Value = m.Histogram.GetSampleCount(), m.Histogram.GetSampleSum()
+        sample.Buckets = make(map[float64]uint64, len(m.Histogram.Bucket))
+        for _, bucket := range m.Histogram.Bucket {
+            sample.Buckets[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+        }
+    case m.Summary != nil:
+        sample.Count, sample.Value = m.Summary.GetSampleCount(), m.Summary.GetSampleSum()
+        sample.Quantiles = make(map[float64]float64, len(m.Summary.Quantile))
+        for _, quantile := range m.Summary.Quantile {
+            sample.Quantiles[quantile.GetQuantile()] = quantile.GetValue()
+        }
+    default:
+        return nil, false
+    }
+    for _, label := range m.Label {
+        sample.Labels[label.GetName()] = label.GetValue()
+    }
+    if m.TimestampMs != nil {
+        sample.Timestamp = time.Unix(0, m.GetTimestampMs()*int64(time.Millisecond))
+    }
+    return sample, true
+}
+
+// applyEmitHooks runs the hooks over the metric. Metrics not created by the
+// collector are not passed to the hooks
+func applyEmitHooks(hooks []EmitHook, metric prometheus.Metric) (prometheus.Metric, bool) {
+    sample, ok := newSample(metric)
+    if !ok {
+        return metric, true
+    }
+    for _, hook := range hooks {
+        if !hook.Process(sample) {
+            return nil, false
+        }
+    }
+
+    hookedMetric, err := newSampleMetric(sample)
+    if err != nil {
+        log.Err_msg("Dropping sample %s modified by an emit hook: %s", sample.Name, err)
+        return nil, false
+    }
+    return hookedMetric, true
+}
+
+// newSampleMetric builds the metric of a sample, with its labels sorted by
+// name
+func newSampleMetric(sample *Sample) (prometheus.Metric, error) {
+    labelNames := make([]string, 0, len(sample.Labels))
+    for name := range sample.Labels {
+        labelNames = append(labelNames, name)
+    }
+    sort.Strings(labelNames)
+    labelValues := make([]string, len(labelNames))
+    for i, name := range labelNames {
+        labelValues[i] = sample.Labels[name]
+    }
+
+    desc := new_desc(sample.Name, sample.Help, labelNames, nil)
+    var metric prometheus.Metric
+    var err error
+    switch {
+    case sample.Buckets != nil:
+        metric, err = prometheus.NewConstHistogram(desc, sample.Count, sample.Value, sample.Buckets, labelValues...)
+    case sample.Quantiles != nil:
+        metric, err = prometheus.NewConstSummary(desc, sample.Count, sample.Value, sample.Quantiles, labelValues...)
+    default:
+        metric, err = prometheus.NewConstMetric(desc, sample.Type, sample.Value, labelValues...)
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !sample.Timestamp.IsZero() {
+        metric = prometheus.NewMetricWithTimestamp(sample.Timestamp, metric)
+    }
+    return metric, nil
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+    "testing"
+
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+func TestEmitHooks(t *testing.T) {
+    desc := new_desc("kbdi_test_latency_seconds", "Test latency (seconds)", []string{"cluster"}, nil)
+    metrics := map[string]prometheus.Metric{
+        "gauge":     prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 0.5, "c1"),
+        "histogram": prometheus.MustNewConstHistogram(desc, 3, 1.5, map[float64]uint64{0.1: 1, 1: 3}, "c1"),
+        "summary":   prometheus.MustNewConstSummary(desc, 3, 1.5, map[float64]float64{0.5: 0.4, 1: 0.9}, "c1"),
+    }
+    rename := []EmitHook{EmitHookFunc(func(sample *Sample) bool {
+        sample.Name = "kbdi_test_renamed_seconds"
+        sample.Labels["team"] = "data-platform"
+        return true
+    })}
+    veto := []EmitHook{EmitHookFunc(func(sample *Sample) bool {
+        return false
+    })}
+
+    for name, metric := range metrics {
+        t.Run(name, func(t *testing.T) {
+            var want dto.Metric
+            if err := metric.Write(&want); err != nil {
+                t.Fatal(err)
+            }
+
+            hooked, ok := applyEmitHooks(rename, metric)
+            if !ok {
+                t.Fatal("Dropped by a hook that does not veto it")
+            }
+            if got := get_desc_fq_name(hooked.Desc()); got != "kbdi_test_renamed_seconds" {
+                t.Errorf("Name %q, want kbdi_test_renamed_seconds", got)
+            }
+            if got := get_desc_help(hooked.Desc()); got != "Test latency (seconds)" {
+                t.Errorf("Help %q, want the help of the metric", got)
+            }
+            var got dto.Metric
+            if err := hooked.Write(&got); err != nil {
+                t.Fatal(err)
+            }
+            if len(got.Label) != 2 || got.Label[1].GetName() != "team" {
+                t.Errorf("Labels %v, want cluster and team", got.Label)
+            }
+            got.Label, want.Label = nil, nil
+            if got.String() != want.String() {
+                t.Errorf("Got %s, want %s", got.String(), want.String())
+            }
+
+            if _, ok := applyEmitHooks(veto, metric); ok {
+                t.Error("Not vetoed")
+            }
+        })
+    }
+}
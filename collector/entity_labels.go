@@ -0,0 +1,85 @@
+/*
+ *
+ * title           :collector/entity_labels.go
+ * description     :Labels taken from the entity attributes of the TimeSeries
+ *                  metadata (hostname, rackId, roleType...)
+ * author          :Enes Erdoğan
+ * date            :2025/06/09
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "fmt"
+
+  // Own libraries
+  jp "keedio/cloudera_exporter/json_parser"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/common/model"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Entity attribute of the TimeSeries metadata added as a label
+type Entity_label struct {
+  Attribute string
+  Label string
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the entity label of the attribute. The label name defaults to the
+// attribute name
+func New_entity_label(attribute string, label string) (Entity_label, error) {
+  if label == "" {
+    label = attribute
+  }
+  if attribute == "" || !model.LabelName(label).IsValid() {
+    return Entity_label{}, fmt.Errorf("Invalid label name %q of the entity attribute %q", label, attribute)
+  }
+  return Entity_label{attribute, label}, nil
+}
+
+
+// Add the entity labels with the attributes of the series to the metric. The
+// labels the metric already has are kept, and the attributes the series does
+// not have are empty labels
+func with_entity_labels(config Collector_connection_data, json_timeseries jp.Timeseries_response, serie_index int, metric prometheus.Metric) prometheus.Metric {
+  if len(config.Entity_labels) == 0 {
+    return metric
+  }
+  sample, ok := newSample(metric)
+  if !ok {
+    return metric
+  }
+  for _, entity_label := range config.Entity_labels {
+    if _, exists := sample.Labels[entity_label.Label]; !exists {
+      sample.Labels[entity_label.Label] = jp.Get_timeseries_query_attribute(json_timeseries, serie_index, entity_label.Attribute)
+    }
+  }
+  enriched, err := newSampleMetric(sample)
+  if err != nil {
+    log.Err_msg("Cannot add the entity labels to %s: %s", sample.Name, err)
+    return metric
+  }
+  return enriched
+}
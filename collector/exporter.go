@@ -86,7 +86,7 @@ func NewMetrics() Metrics {
 	}
 }
 
-var scrapeDurationDesc = prometheus.NewDesc(
+var scrapeDurationDesc = new_desc(
 		prometheus.BuildFQName(namespace, subsystem, "collector_duration_seconds"),
 		"Collector time duration.",
 		[]string{"collector"},
@@ -97,17 +97,55 @@ var scrapeDurationDesc = prometheus.NewDesc(
 
 func (c *Collector) scrape (ctx context.Context, ch chan<- prometheus.Metric) {
 	c.metrics.TotalScrapes.Inc()
+	ctx, stopwatch := with_stopwatch(ctx)
+	ctx = withDiscoveryCache(ctx)
+	ctx = with_shard(ctx, c.config.Shard)
+
+	// The API version is not negotiated at startup if the exporter starts in
+	// standby, so it is negotiated on the first scrape after the activation.
+	// The queries read it from the negotiated version, as the configuration
+	// is shared by the concurrent scrapes
+	if get_api_version(c.config) == "" {
+		if _, err := Get_api_cloudera_version(ctx, c.config); err != nil {
+			log.Err_msg(err.Error())
+		}
+	}
+
+	// Every metric sent by the scrapers goes through the samples pipeline
+	samples := make(chan prometheus.Metric)
+	pipeline_done := make(chan struct{})
+	collected := new_sample_store()
+	scope := get_scrape_scope(ctx)
+	go func() {
+		defer close(pipeline_done)
+		for metric := range samples {
+			// Metrics of the clusters and services out of the scope of the
+			// scrape are dropped
+			if !scope.includes_metric(metric) {
+				continue
+			}
+			emit_start := time.Now()
+			metric = convert_metric_unit(c.config, metric)
+			if c.config.Validate_metrics {
+				validate_metric(metric)
+			}
+			if len(c.config.Derived_metrics) > 0 {
+				collected.add(metric)
+			}
+			ch <- metric
+			record_phase(ctx, PHASE_EMIT, emit_start)
+		}
+	}()
 
 	var wg sync.WaitGroup
-	defer wg.Wait()
-	for _, scraper := range c.scrapers {
+	for _, scraper := range c.Scrapers() {
 
 		wg.Add(1)
 		go func(scraper Scraper) {
 			defer wg.Done()
 			label := scraper.Name()
 			scrapeTime := time.Now()
-			if err := scraper.Scrape(ctx, &c.config, ch); err != nil {
+			if err := scraper.Scrape(with_collector_name(ctx, label), &c.config, samples); err != nil {
 				log.Err_msg("Error scraping for " + label + ":", err)
 				c.metrics.ScrapeErrors.WithLabelValues(label).Inc()
 				c.metrics.CMUp.Set(0)
@@ -117,5 +155,15 @@ func (c *Collector) scrape (ctx context.Context, ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, time.Since(scrapeTime).Seconds(), label)
 		} (scraper)
 	}
+	wg.Wait()
+	close(samples)
+	<-pipeline_done
+
+	// Metrics computed from the collected ones
+	emit_start := time.Now()
+	emit_derived_metrics(c.config.Derived_metrics, collected, ch)
+	record_phase(ctx, PHASE_EMIT, emit_start)
 
+	// Time spent in each phase of this scrape
+	stopwatch.collect(ch)
 }
@@ -0,0 +1,170 @@
+/*
+ *
+ * title           :collector/feature_flags.go
+ * description     :Feature flags to gate the experimental behaviors of the
+ *                  exporter, set in the config file and toggled at runtime
+ * author          :Enes Erdoğan
+ * date            :2025/06/02
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "fmt"
+  "sort"
+  "sync"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Experimental behaviors gated by a feature flag
+const (
+  FEATURE_DIRECT_ZOOKEEPER = "direct_zookeeper"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Feature flag of an experimental behavior
+type Feature_flag struct {
+  Name string `json:"name"`
+  Description string `json:"description"`
+  Enabled bool `json:"enabled"`
+  // State when the config file does not set it
+  Default bool `json:"default"`
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Registered feature flags by name. Shared by all the scrapes
+var feature_flags = struct {
+  sync.RWMutex
+  flags map[string]*Feature_flag
+}{flags: map[string]*Feature_flag{}}
+
+var featureFlagDesc = new_desc(
+  prometheus.BuildFQName(namespace, subsystem, "feature_flag"),
+  "Whether the experimental behavior is enabled (1 for enabled).",
+  []string{"name"},
+  nil,
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+func init() {
+  Register_feature_flag(FEATURE_DIRECT_ZOOKEEPER, "Read the stats of the ZooKeeper servers from them with the srvr command, besides Cloudera Manager", false)
+}
+
+
+// Register the feature flag of an experimental behavior, disabled or enabled
+// by default. Registering an existing flag replaces it
+func Register_feature_flag(name string, description string, enabled bool) {
+  feature_flags.Lock()
+  defer feature_flags.Unlock()
+  feature_flags.flags[name] = &Feature_flag{name, description, enabled, enabled}
+}
+
+
+// Set every feature flag to its state in the config file, or to its default
+// if the file does not set it, so the flags removed from the file and the
+// ones toggled at runtime are reset. Returns an error, without changing any
+// flag, if one of them is not registered
+func Set_feature_flags(enabled map[string]bool) error {
+  feature_flags.Lock()
+  defer feature_flags.Unlock()
+  for name := range enabled {
+    if _, ok := feature_flags.flags[name]; !ok {
+      return fmt.Errorf("Unknown feature flag %s", name)
+    }
+  }
+  for name, flag := range feature_flags.flags {
+    flag_enabled, ok := enabled[name]
+    if !ok {
+      flag_enabled = flag.Default
+    }
+    if flag.Enabled != flag_enabled {
+      log.Info_msg("Feature flag %s set to %t", name, flag_enabled)
+    }
+    flag.Enabled = flag_enabled
+  }
+  return nil
+}
+
+
+// Enable or disable a feature flag. Returns an error if it is not registered
+func Set_feature_flag(name string, enabled bool) error {
+  feature_flags.Lock()
+  defer feature_flags.Unlock()
+  flag, ok := feature_flags.flags[name]
+  if !ok {
+    return fmt.Errorf("Unknown feature flag %s", name)
+  }
+  if flag.Enabled != enabled {
+    log.Info_msg("Feature flag %s set to %t", name, enabled)
+  }
+  flag.Enabled = enabled
+  return nil
+}
+
+
+// Returns true if the feature flag is enabled. Unknown flags are disabled
+func Is_feature_enabled(name string) bool {
+  feature_flags.RLock()
+  defer feature_flags.RUnlock()
+  flag, ok := feature_flags.flags[name]
+  return ok && flag.Enabled
+}
+
+
+// Returns a copy of the registered feature flags sorted by name
+func Get_feature_flags() []Feature_flag {
+  feature_flags.RLock()
+  defer feature_flags.RUnlock()
+  flags := make([]Feature_flag, 0, len(feature_flags.flags))
+  for _, flag := range feature_flags.flags {
+    flags = append(flags, *flag)
+  }
+  sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+  return flags
+}
+
+
+// Send the state of the feature flags
+func collect_feature_flags(ch chan<- prometheus.Metric) {
+  for _, flag := range Get_feature_flags() {
+    value := 0.0
+    if flag.Enabled {
+      value = 1
+    }
+    ch <- prometheus.MustNewConstMetric(featureFlagDesc, prometheus.GaugeValue, value, flag.Name)
+  }
+}
@@ -97,24 +97,24 @@ var (
 
 // Creation of the structure that relates the queries with the descriptors of the Prometheus metrics
 var hdfs_query_variable_relationship = []relation {
-  {HDFS_DFS_CAPACITY,                *hdfs_dfs_capacity},
-  {HDFS_DFS_CAPACITY_USED,           *hdfs_dfs_capacity_used},
-  {HDFS_DFS_CAPACITY_USED_PERCENT,   *hdfs_dfs_capacity_used_percent},
-  {HDFS_DFS_CAPACITY_NON_HDFS_USED,  *hdfs_dfs_capacity_non_hdfs_used},
-  {HDFS_BLOCK_CAPACITY,              *hdfs_block_capacity},
-  {HDFS_BLOCK_TOTAL,                 *hdfs_block_total},
-  {HDFS_BLOCK_CORRUPT_REPLICAS,      *hdfs_block_corrupt_replicas},
-  {HDFS_BLOCK_EXCESS,                *hdfs_block_excess},
-  {HDFS_BLOCK_MISSING,               *hdfs_block_missing},
-  {HDFS_BLOCK_UNDER_REPLICATED,      *hdfs_block_under_replicated},
-  {HDFS_BLOCK_WRITE,                 *hdfs_block_write},
-  {HDFS_BLOCK_READ,                  *hdfs_block_read},
-  {HDFS_FILES_TOTAL,                 *hdfs_files_total},
-  {HDFS_FILES_SIZE_AVG,              *hdfs_files_size_avg},
-  {HDFS_HEARTBEATS_EXPIRED,          *hdfs_heartbeats_expired},
-  {HDFS_NAMENODE_FD_MAX_DESCRIPTORS, *hdfs_namenode_fd_max_descriptors},
-  {HDFS_SNAPSHOT_NUM,                *hdfs_snapshot_num},
-  {HDFS_SNAPSHOT_DIRS,               *hdfs_snapshot_dirs},
+  {HDFS_DFS_CAPACITY,                hdfs_dfs_capacity},
+  {HDFS_DFS_CAPACITY_USED,           hdfs_dfs_capacity_used},
+  {HDFS_DFS_CAPACITY_USED_PERCENT,   hdfs_dfs_capacity_used_percent},
+  {HDFS_DFS_CAPACITY_NON_HDFS_USED,  hdfs_dfs_capacity_non_hdfs_used},
+  {HDFS_BLOCK_CAPACITY,              hdfs_block_capacity},
+  {HDFS_BLOCK_TOTAL,                 hdfs_block_total},
+  {HDFS_BLOCK_CORRUPT_REPLICAS,      hdfs_block_corrupt_replicas},
+  {HDFS_BLOCK_EXCESS,                hdfs_block_excess},
+  {HDFS_BLOCK_MISSING,               hdfs_block_missing},
+  {HDFS_BLOCK_UNDER_REPLICATED,      hdfs_block_under_replicated},
+  {HDFS_BLOCK_WRITE,                 hdfs_block_write},
+  {HDFS_BLOCK_READ,                  hdfs_block_read},
+  {HDFS_FILES_TOTAL,                 hdfs_files_total},
+  {HDFS_FILES_SIZE_AVG,              hdfs_files_size_avg},
+  {HDFS_HEARTBEATS_EXPIRED,          hdfs_heartbeats_expired},
+  {HDFS_NAMENODE_FD_MAX_DESCRIPTORS, hdfs_namenode_fd_max_descriptors},
+  {HDFS_SNAPSHOT_NUM,                hdfs_snapshot_num},
+  {HDFS_SNAPSHOT_DIRS,               hdfs_snapshot_dirs},
 }
 
 
@@ -134,7 +134,7 @@ func create_hdfs_metric_struct(metric_name string, description string) *promethe
   }
 
   // return prometheus descriptor
-  return prometheus.NewDesc(
+  return new_desc(
     prometheus.BuildFQName(namespace, HDFS_SCRAPER_NAME, metric_name),
     description,
     []string{"cluster", "entityName"},
@@ -145,7 +145,7 @@ func create_hdfs_metric_struct(metric_name string, description string) *promethe
 
 // Generic function to extract de metadata associated with the query value
 // Only for HDFS metric type
-func create_hdfs_metric (ctx context.Context, config Collector_connection_data, query string, metric_struct prometheus.Desc, ch chan<- prometheus.Metric) bool {
+func create_hdfs_metric (ctx context.Context, config Collector_connection_data, query string, metric_struct *prometheus.Desc, ch chan<- prometheus.Metric) bool {
   // Make the query
   json_parsed, err := make_and_parse_timeseries_query(ctx, config, query)
   if err != nil {
@@ -165,12 +165,13 @@ func create_hdfs_metric (ctx context.Context, config Collector_connection_data,
     // Get the entity Name
     entity_name := jp.Get_timeseries_query_entity_name(json_parsed, ts_index)
     // Get Query LAST value
-    value, err := jp.Get_timeseries_query_value(json_parsed, ts_index)
+    value, timestamp, err := get_timeseries_sample(config, json_parsed, ts_index)
+    emit_present_metric(ch, config, metric_struct, err == nil, cluster_name, entity_name)
     if err != nil {
       continue
     }
     // Assing the data to the Prometheus descriptor
-    ch <- prometheus.MustNewConstMetric(&metric_struct, prometheus.GaugeValue, value, cluster_name, entity_name)
+    ch <- with_entity_labels(config, json_parsed, ts_index, new_timeseries_metric(config, metric_struct, value, timestamp, cluster_name, entity_name))
   }
   return true
 }
@@ -127,31 +127,31 @@ var (
 
 // Creation of the structure that relates the queries with the descriptors of the Prometheus metrics
 var host_query_variable_relationship = []relation {
-  {HOST_AGENT_CPU_SYSTEM_PERCENT_QUERY, *global_host_agent_cpu_system_percent},
-  {HOST_AGENT_CPU_USER_PERCENT_QUERY,   *global_host_agent_cpu_user_percent},
-  {HOST_AGENT_PHYS_MEM_USE_QUERY,       *global_host_agent_phys_mem_use},
-  {HOST_AGENT_VIRT_MEM_USE_QUERY,       *global_host_agent_virt_mem_use},
-  {HOST_CPU_CORES_QUERY,                *global_host_cpu_cores},
-  {HOST_CPU_IDLE_PERCENT_QUERY,         *global_host_cpu_iddle_percent},
-  {HOST_CPU_IOWAIT_PERCENT_QUERY,       *global_host_cpu_iowait_percent},
-  {HOST_CPU_LOAD15_QUERY,               *global_host_cpu_load15},
-  {HOST_CPU_LOAD1_QUERY,                *global_host_cpu_load1},
-  {HOST_CPU_LOAD5_QUERY,                *global_host_cpu_load5},
-  {HOST_CPU_PERCENT_QUERY,              *global_host_cpu_percent},
-  {HOST_CPU_SYSTEM_PERCENT_QUERY,       *global_host_cpu_system_percent},
-  {HOST_CPU_USER_PERCENT_QUERY,         *global_host_cpu_user_percent},
-  {HOST_MEM_FREE_QUERY,                 *global_host_mem_free},
-  {HOST_MEM_TOTAL_QUERY,                *global_host_mem_total},
-  {HOST_MEM_USED_QUERY,                 *global_host_mem_used},
-  {HOST_MEM_WRITE_BACK_QUERY,           *global_host_mem_write_back},
-  {HOST_OTHER_ALERTS_QUERY,             *global_host_other_alerts},
-  {HOST_OTHER_CLOCK_OFFSET_QUERY,       *global_host_other_clock_offset},
-  {HOST_OTHER_DNS_RESOLUTION_TIME,      *global_host_other_dns_resolution_time},
-  {HOST_OTHER_UPTIME,                   *global_host_other_uptime},
-  {HOST_SWAP_FREE_QUERY,                *global_host_swap_free},
-  {HOST_SWAP_OUT_QUERY,                 *global_host_swap_out},
-  {HOST_SWAP_TOTAL_QUERY,               *global_host_swap_total},
-  {HOST_SWAP_USED_QUERY,                *global_host_swap_used},
+  {HOST_AGENT_CPU_SYSTEM_PERCENT_QUERY, global_host_agent_cpu_system_percent},
+  {HOST_AGENT_CPU_USER_PERCENT_QUERY,   global_host_agent_cpu_user_percent},
+  {HOST_AGENT_PHYS_MEM_USE_QUERY,       global_host_agent_phys_mem_use},
+  {HOST_AGENT_VIRT_MEM_USE_QUERY,       global_host_agent_virt_mem_use},
+  {HOST_CPU_CORES_QUERY,                global_host_cpu_cores},
+  {HOST_CPU_IDLE_PERCENT_QUERY,         global_host_cpu_iddle_percent},
+  {HOST_CPU_IOWAIT_PERCENT_QUERY,       global_host_cpu_iowait_percent},
+  {HOST_CPU_LOAD15_QUERY,               global_host_cpu_load15},
+  {HOST_CPU_LOAD1_QUERY,                global_host_cpu_load1},
+  {HOST_CPU_LOAD5_QUERY,                global_host_cpu_load5},
+  {HOST_CPU_PERCENT_QUERY,              global_host_cpu_percent},
+  {HOST_CPU_SYSTEM_PERCENT_QUERY,       global_host_cpu_system_percent},
+  {HOST_CPU_USER_PERCENT_QUERY,         global_host_cpu_user_percent},
+  {HOST_MEM_FREE_QUERY,                 global_host_mem_free},
+  {HOST_MEM_TOTAL_QUERY,                global_host_mem_total},
+  {HOST_MEM_USED_QUERY,                 global_host_mem_used},
+  {HOST_MEM_WRITE_BACK_QUERY,           global_host_mem_write_back},
+  {HOST_OTHER_ALERTS_QUERY,             global_host_other_alerts},
+  {HOST_OTHER_CLOCK_OFFSET_QUERY,       global_host_other_clock_offset},
+  {HOST_OTHER_DNS_RESOLUTION_TIME,      global_host_other_dns_resolution_time},
+  {HOST_OTHER_UPTIME,                   global_host_other_uptime},
+  {HOST_SWAP_FREE_QUERY,                global_host_swap_free},
+  {HOST_SWAP_OUT_QUERY,                 global_host_swap_out},
+  {HOST_SWAP_TOTAL_QUERY,               global_host_swap_total},
+  {HOST_SWAP_USED_QUERY,                global_host_swap_used},
 }
 
 
@@ -171,7 +171,7 @@ func create_host_metric_struct(metric_name string, description string) *promethe
   }
 
   // return prometheus descriptor
-  return prometheus.NewDesc(
+  return new_desc(
     prometheus.BuildFQName(namespace, HOST_SCRAPER_NAME, metric_name),
     description,
     []string{"cluster", "hostname", "hostid", "is_master_node", "is_border_node", "is_worker_node"},
@@ -185,7 +185,7 @@ func create_host_metric_struct(metric_name string, description string) *promethe
 // For this module, the cluster to which the host belongs is indifferent.  The
 // name of the cluster to which the host belongs is associated as metadata to
 // its corresponding metric
-func create_host_metric (ctx context.Context, config Collector_connection_data, query string, metric_struct prometheus.Desc, ch chan<- prometheus.Metric) bool {
+func create_host_metric (ctx context.Context, config Collector_connection_data, query string, metric_struct *prometheus.Desc, ch chan<- prometheus.Metric) bool {
   // Make the query
   json_parsed, err := make_and_parse_timeseries_query(ctx, config, query)
   if err != nil {
@@ -213,12 +213,13 @@ func create_host_metric (ctx context.Context, config Collector_connection_data,
     // Get the flag to determine if the host is a Worker Node
     is_worker_node := get_if_is_worker(host_id)
     // Get Query LAST value
-    value, err := jp.Get_timeseries_query_value(json_parsed, host_index)
+    value, timestamp, err := get_timeseries_sample(config, json_parsed, host_index)
+    emit_present_metric(ch, config, metric_struct, err == nil, cluster_name, host_name, host_id, is_master_node, is_border_node, is_worker_node)
     if err != nil {
 	continue
     }
     // Assing the data to the Prometheus descriptor
-    ch <- prometheus.MustNewConstMetric(&metric_struct, prometheus.GaugeValue, value, cluster_name, host_name, host_id, is_master_node, is_border_node, is_worker_node)
+    ch <- with_entity_labels(config, json_parsed, host_index, new_timeseries_metric(config, metric_struct, value, timestamp, cluster_name, host_name, host_id, is_master_node, is_border_node, is_worker_node))
   }
   return true
 }
@@ -40,7 +40,7 @@ import (
 
 type relationa struct {
   Query *string
-  Metric_struct prometheus.Desc
+  Metric_struct *prometheus.Desc
 }
 
 
@@ -163,56 +163,56 @@ var (
 
 )
 var impala_query_variable_relationship = []relationa {
-  {&IMPALA_CATALOG_JVM_COMITTED_BYTES,          *impala_catalog_jvm_comitted_bytes},
-  {&IMPALA_CATALOG_JVM_CURRENT_BYTES,           *impala_catalog_jvm_current_bytes},
-  {&IMPALA_CATALOG_JVM_INIT_BYTES,              *impala_catalog_jvm_init_bytes},
-  {&IMPALA_CATALOG_JVM_MAX_BYTES,               *impala_catalog_jvm_max_bytes},
-  {&IMPALA_CGROUP_MEM_PAGE_CACHE,               *impala_cgroup_mem_page_cache},
-  {&IMPALA_CGROUP_MEM_RSS,                      *impala_cgroup_mem_rss},
-  {&IMPALA_CGROUP_MEM_SWAP,                     *impala_cgroup_mem_swap},
-  {&IMPALA_CGROUP_READ_IOSRATE,                 *impala_cgroup_read_iosrate},
-  {&IMPALA_CGROUP_READ_RATE,                    *impala_cgroup_read_rate},
-  {&IMPALA_CGROUP_SYSTEM_RATE,                  *impala_cgroup_system_rate},
-  {&IMPALA_CGROUP_USER_RATE,                    *impala_cgroup_user_rate},
-  {&IMPALA_CGROUP_WRITE_IOSRATE,                *impala_cgroup_write_iosrate},
-  {&IMPALA_CGROUP_WRITE_RATE,                   *impala_cgroup_write_rate},
-  {&IMPALA_MEM_RSS,                             *impala_mem_rss},
-  {&IMPALA_MEM_SWAP,                            *impala_mem_swap},
-  {&IMPALA_MEM_VIRT,                            *impala_mem_virt},
-  {&IMPALA_OOMEXIT,                             *impala_oomexit},
-  {&IMPALA_QUERY_ADMISSION_WAIT_RATE,           *impala_query_admission_wait_rate},
-  {&IMPALA_QUERY_BYTES_HDFS_READ_RATE,          *impala_query_bytes_hdfs_read_rate},
-  {&IMPALA_QUERY_BYTES_HDFS_WRITTE_RATE,        *impala_query_bytes_hdfs_writte_rate},
-  {&IMPALA_QUERY_BYTES_STREAMED_RATE,           *impala_query_bytes_streamed_rate},
-  {&IMPALA_QUERY_CM_CPU,                        *impala_query_cm_cpu},
-  {&IMPALA_QUERY_DURATION_RATE,                 *impala_query_duration_rate},
-  {&IMPALA_QUERY_INGESTED_RATE,                 *impala_query_ingested_rate},
-  {&IMPALA_QUERY_MEM_ACCRUAL_RATE,              *impala_query_mem_accrual_rate},
-  {&IMPALA_QUERY_MEM_SPILLED_RATE,              *impala_query_mem_spilled_rate},
-  {&IMPALA_QUERY_OOMRATE,                       *impala_query_oomrate},
-  {&IMPALA_QUERY_REJECTED_RATE,                 *impala_query_rejected_rate},
-  {&IMPALA_QUERY_SPILLED_RATE,                  *impala_query_spilled_rate},
-  {&IMPALA_QUERY_SUCCESSFUL_RATE,               *impala_query_successful_rate},
-  {&IMPALA_QUERY_THREAD_CPU_RATE,               *impala_query_thread_cpu_rate},
-  {&IMPALA_QUERY_TIME_OUT_RATE,                 *impala_query_time_out_rate},
-  {&IMPALA_READ_RATE,                           *impala_read_rate},
-  {&IMPALA_STATE_STORE_CACHE_TOTAL_CLIENTS,     *impala_state_store_cache_total_clients},
-  {&IMPALA_STATE_STORE_CLIENTS_IN_USE,          *impala_state_store_clients_in_use},
-  {&IMPALA_STATE_STORE_HEART_BEAT_LAST,         *impala_state_store_heart_beat_last},
-  {&IMPALA_STATE_STORE_HEART_BEAT_MAX,          *impala_state_store_heart_beat_max},
-  {&IMPALA_STATE_STORE_HEART_BEAT_MEAN,         *impala_state_store_heart_beat_mean},
-  {&IMPALA_STATE_STORE_HEART_BEAT_MIN,          *impala_state_store_heart_beat_min},
-  {&IMPALA_STATE_STORE_HEART_BEAT_RATE,         *impala_state_store_heart_beat_rate},
-  {&IMPALA_STATE_STORE_HEART_BEAT_STDDEV,       *impala_state_store_heart_beat_stddev},
-  {&IMPALA_STATE_STORE_LAST_RECOVERY_DURATION,  *impala_state_store_last_recovery_duration},
-  {&IMPALA_TCMALLOC_FREE_BYTES,                 *impala_tcmalloc_free_bytes},
-  {&IMPALA_TCMALLOC_PHYSICAL_RESERVED_BYTES,    *impala_tcmalloc_physical_reserved_bytes},
-  {&IMPALA_TCMALLOC_TOTAL_RESERVED_BYTES,       *impala_tcmalloc_total_reserved_bytes},
-  {&IMPALA_TCMALLOC_UNMAPPED_BYTES,             *impala_tcmalloc_unmapped_bytes},
-  {&IMPALA_TCMALLOC_USED_BYTES,                 *impala_tcmalloc_used_bytes},
-  {&IMPALA_THRIFT_CONNECTIONS_RATE,             *impala_thrift_connections_rate},
-  {&IMPALA_THRIFT_CONNECTIONS_USED,             *impala_thrift_connections_used},
-  {&IMPALA_WRITE_RATE,                          *impala_write_rate},
+  {&IMPALA_CATALOG_JVM_COMITTED_BYTES,          impala_catalog_jvm_comitted_bytes},
+  {&IMPALA_CATALOG_JVM_CURRENT_BYTES,           impala_catalog_jvm_current_bytes},
+  {&IMPALA_CATALOG_JVM_INIT_BYTES,              impala_catalog_jvm_init_bytes},
+  {&IMPALA_CATALOG_JVM_MAX_BYTES,               impala_catalog_jvm_max_bytes},
+  {&IMPALA_CGROUP_MEM_PAGE_CACHE,               impala_cgroup_mem_page_cache},
+  {&IMPALA_CGROUP_MEM_RSS,                      impala_cgroup_mem_rss},
+  {&IMPALA_CGROUP_MEM_SWAP,                     impala_cgroup_mem_swap},
+  {&IMPALA_CGROUP_READ_IOSRATE,                 impala_cgroup_read_iosrate},
+  {&IMPALA_CGROUP_READ_RATE,                    impala_cgroup_read_rate},
+  {&IMPALA_CGROUP_SYSTEM_RATE,                  impala_cgroup_system_rate},
+  {&IMPALA_CGROUP_USER_RATE,                    impala_cgroup_user_rate},
+  {&IMPALA_CGROUP_WRITE_IOSRATE,                impala_cgroup_write_iosrate},
+  {&IMPALA_CGROUP_WRITE_RATE,                   impala_cgroup_write_rate},
+  {&IMPALA_MEM_RSS,                             impala_mem_rss},
+  {&IMPALA_MEM_SWAP,                            impala_mem_swap},
+  {&IMPALA_MEM_VIRT,                            impala_mem_virt},
+  {&IMPALA_OOMEXIT,                             impala_oomexit},
+  {&IMPALA_QUERY_ADMISSION_WAIT_RATE,           impala_query_admission_wait_rate},
+  {&IMPALA_QUERY_BYTES_HDFS_READ_RATE,          impala_query_bytes_hdfs_read_rate},
+  {&IMPALA_QUERY_BYTES_HDFS_WRITTE_RATE,        impala_query_bytes_hdfs_writte_rate},
+  {&IMPALA_QUERY_BYTES_STREAMED_RATE,           impala_query_bytes_streamed_rate},
+  {&IMPALA_QUERY_CM_CPU,                        impala_query_cm_cpu},
+  {&IMPALA_QUERY_DURATION_RATE,                 impala_query_duration_rate},
+  {&IMPALA_QUERY_INGESTED_RATE,                 impala_query_ingested_rate},
+  {&IMPALA_QUERY_MEM_ACCRUAL_RATE,              impala_query_mem_accrual_rate},
+  {&IMPALA_QUERY_MEM_SPILLED_RATE,              impala_query_mem_spilled_rate},
+  {&IMPALA_QUERY_OOMRATE,                       impala_query_oomrate},
+  {&IMPALA_QUERY_REJECTED_RATE,                 impala_query_rejected_rate},
+  {&IMPALA_QUERY_SPILLED_RATE,                  impala_query_spilled_rate},
+  {&IMPALA_QUERY_SUCCESSFUL_RATE,               impala_query_successful_rate},
+  {&IMPALA_QUERY_THREAD_CPU_RATE,               impala_query_thread_cpu_rate},
+  {&IMPALA_QUERY_TIME_OUT_RATE,                 impala_query_time_out_rate},
+  {&IMPALA_READ_RATE,                           impala_read_rate},
+  {&IMPALA_STATE_STORE_CACHE_TOTAL_CLIENTS,     impala_state_store_cache_total_clients},
+  {&IMPALA_STATE_STORE_CLIENTS_IN_USE,          impala_state_store_clients_in_use},
+  {&IMPALA_STATE_STORE_HEART_BEAT_LAST,         impala_state_store_heart_beat_last},
+  {&IMPALA_STATE_STORE_HEART_BEAT_MAX,          impala_state_store_heart_beat_max},
+  {&IMPALA_STATE_STORE_HEART_BEAT_MEAN,         impala_state_store_heart_beat_mean},
+  {&IMPALA_STATE_STORE_HEART_BEAT_MIN,          impala_state_store_heart_beat_min},
+  {&IMPALA_STATE_STORE_HEART_BEAT_RATE,         impala_state_store_heart_beat_rate},
+  {&IMPALA_STATE_STORE_HEART_BEAT_STDDEV,       impala_state_store_heart_beat_stddev},
+  {&IMPALA_STATE_STORE_LAST_RECOVERY_DURATION,  impala_state_store_last_recovery_duration},
+  {&IMPALA_TCMALLOC_FREE_BYTES,                 impala_tcmalloc_free_bytes},
+  {&IMPALA_TCMALLOC_PHYSICAL_RESERVED_BYTES,    impala_tcmalloc_physical_reserved_bytes},
+  {&IMPALA_TCMALLOC_TOTAL_RESERVED_BYTES,       impala_tcmalloc_total_reserved_bytes},
+  {&IMPALA_TCMALLOC_UNMAPPED_BYTES,             impala_tcmalloc_unmapped_bytes},
+  {&IMPALA_TCMALLOC_USED_BYTES,                 impala_tcmalloc_used_bytes},
+  {&IMPALA_THRIFT_CONNECTIONS_RATE,             impala_thrift_connections_rate},
+  {&IMPALA_THRIFT_CONNECTIONS_USED,             impala_thrift_connections_used},
+  {&IMPALA_WRITE_RATE,                          impala_write_rate},
 }
 
 
@@ -232,7 +232,7 @@ func create_impala_metric_struct(metric_name string, description string) *promet
   }
 
   // return prometheus descriptor
-  return prometheus.NewDesc(
+  return new_desc(
     prometheus.BuildFQName(namespace, IMPALA_SCRAPER_NAME, metric_name),
     description,
     []string{"cluster", "entityName"},
@@ -243,7 +243,7 @@ func create_impala_metric_struct(metric_name string, description string) *promet
 
 // Generic function to extract de metadata associated with the query value
 // Only for Impala metric type
-func create_impala_metric (ctx context.Context, config Collector_connection_data, query string, metric_struct prometheus.Desc, ch chan<- prometheus.Metric) bool {
+func create_impala_metric (ctx context.Context, config Collector_connection_data, query string, metric_struct *prometheus.Desc, ch chan<- prometheus.Metric) bool {
   if query == "" { return true }
   // Make the query
   json_parsed, err := make_and_parse_timeseries_query(ctx, config, query)
@@ -263,13 +263,14 @@ func create_impala_metric (ctx context.Context, config Collector_connection_data
     cluster_name := jp.Get_timeseries_query_cluster(json_parsed, ts_index)
     entity_name := jp.Get_timeseries_query_entity_name(json_parsed, ts_index)
     // Get Query LAST value
-    value, err := jp.Get_timeseries_query_value(json_parsed, ts_index)
+    value, timestamp, err := get_timeseries_sample(config, json_parsed, ts_index)
+    emit_present_metric(ch, config, metric_struct, err == nil, cluster_name, entity_name)
     if err != nil {
       log.Debug_msg("No data for query: %s", query)
       continue
     }
     // Assing the data to the Prometheus descriptor
-    ch <- prometheus.MustNewConstMetric(&metric_struct, prometheus.GaugeValue, value, cluster_name, entity_name)
+    ch <- with_entity_labels(config, json_parsed, ts_index, new_timeseries_metric(config, metric_struct, value, timestamp, cluster_name, entity_name))
   }
   return true
 }
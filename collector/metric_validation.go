@@ -0,0 +1,159 @@
+/*
+ *
+ * title           :collector/metric_validation.go
+ * description     :Validation of the values returned by Cloudera Manager
+ *                  against the declared semantics of the metrics
+ * author          :Enes Erdoğan
+ * date            :2025/06/30
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "math"
+  "sort"
+  "strings"
+  "sync"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Semantics of the metric values
+const (
+  // Rates, durations and sizes: never negative
+  SEMANTICS_NON_NEGATIVE = "non_negative"
+  // Fractions of time or of a total: between 0 and 1
+  SEMANTICS_RATIO = "ratio"
+  // Counters: never negative nor decreasing
+  SEMANTICS_MONOTONIC = "monotonic"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Semantics declared for the metrics by name. The metrics not declared get
+// the semantics of their suffix (see get_metric_semantics)
+var declared_semantics = map[string]string {
+  "kbdi_zookeeper_health_bad_rate": SEMANTICS_RATIO,
+  "kbdi_zookeeper_health_concerning_rate": SEMANTICS_RATIO,
+  "kbdi_zookeeper_health_disabled_rate": SEMANTICS_RATIO,
+  "kbdi_zookeeper_health_good_rate": SEMANTICS_RATIO,
+  "kbdi_zookeeper_health_unknown_rate": SEMANTICS_RATIO,
+  "kbdi_zookeeper_current_xid": SEMANTICS_NON_NEGATIVE,
+}
+
+// Last value of each monotonic series, to detect the decreases
+var monotonic_values = struct {
+  sync.Mutex
+  values map[string]float64
+}{values: map[string]float64{}}
+
+var metric_validation_violations_total = prometheus.NewCounterVec(prometheus.CounterOpts {
+  Namespace: namespace,
+  Subsystem: subsystem,
+  Name:      "metric_validation_violations_total",
+  Help:      "Total number of values returned by Cloudera Manager that do not match the semantics of the metric, by metric and violated rule.",
+}, []string{"metric", "rule"})
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the semantics of the metric: the declared one or the one of its
+// suffix (_total, _rate, _ms, _seconds, _bytes). Empty if it has none
+func get_metric_semantics(name string) string {
+  if semantics, ok := declared_semantics[name]; ok {
+    return semantics
+  }
+  switch {
+  case strings.HasSuffix(name, "_total"):
+    return SEMANTICS_MONOTONIC
+  case strings.HasSuffix(name, "_rate"), strings.HasSuffix(name, "_ms"), strings.HasSuffix(name, "_seconds"), strings.HasSuffix(name, "_bytes"):
+    return SEMANTICS_NON_NEGATIVE
+  }
+  return ""
+}
+
+
+// Returns the key of a series: its name and sorted labels
+func get_series_key(sample *Sample) string {
+  pairs := make([]string, 0, len(sample.Labels))
+  for name, value := range sample.Labels {
+    pairs = append(pairs, name + "=" + value)
+  }
+  sort.Strings(pairs)
+  return sample.Name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+
+// Returns the rule of the semantics violated by the value, or empty if it
+// is valid. NaN values are not validated
+func check_metric_semantics(sample *Sample, semantics string) string {
+  if math.IsNaN(sample.Value) {
+    return ""
+  }
+  switch semantics {
+  case SEMANTICS_NON_NEGATIVE:
+    if sample.Value < 0 {
+      return "negative"
+    }
+  case SEMANTICS_RATIO:
+    if sample.Value < 0 || sample.Value > 1 {
+      return "out_of_range"
+    }
+  case SEMANTICS_MONOTONIC:
+    if sample.Value < 0 {
+      return "negative"
+    }
+    key := get_series_key(sample)
+    monotonic_values.Lock()
+    defer monotonic_values.Unlock()
+    previous, seen := monotonic_values.values[key]
+    monotonic_values.values[key] = sample.Value
+    if seen && sample.Value < previous {
+      return "decreased"
+    }
+  }
+  return ""
+}
+
+
+// Validate the value of a collected metric against its semantics and count
+// the violations. The metric is exported anyway
+func validate_metric(metric prometheus.Metric) {
+  sample, ok := newSample(metric)
+  if !ok {
+    return
+  }
+  semantics := get_metric_semantics(sample.Name)
+  if semantics == "" {
+    return
+  }
+  if rule := check_metric_semantics(sample, semantics); rule != "" {
+    log.Debug_msg("Value %g of %s violates the %s semantics (%s)", sample.Value, get_series_key(sample), semantics, rule)
+    metric_validation_violations_total.WithLabelValues(sample.Name, rule).Inc()
+  }
+}
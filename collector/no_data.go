@@ -0,0 +1,93 @@
+/*
+ *
+ * title           :collector/no_data.go
+ * description     :Behavior of the TimeSeries without current data: no
+ *                  datapoints in the window or a stale datapoint
+ * author          :Enes Erdoğan
+ * date            :2025/07/07
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "math"
+  "time"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Behaviors of the series without current data
+const (
+  // The sample is not exported, so it is not taken as a zero
+  NO_DATA_OMIT = "omit"
+  // The sample is exported as NaN
+  NO_DATA_NAN = "nan"
+  // The sample is not exported and the <metric>_present gauge of the series
+  // is 0 (1 if it has data)
+  NO_DATA_PRESENT = "present"
+)
+
+// Suffix of the gauges of the present behavior
+const present_suffix = "_present"
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns true if the behavior for the series without data is valid
+func Is_valid_no_data(no_data string) bool {
+  return no_data == NO_DATA_OMIT || no_data == NO_DATA_NAN || no_data == NO_DATA_PRESENT
+}
+
+
+// Returns the sample of a series without current data: NaN if the behavior
+// is nan, else the error so the sample is not exported
+func no_data_sample(config Collector_connection_data, err error) (float64, time.Time, error) {
+  if config.No_data == NO_DATA_NAN {
+    return math.NaN(), time.Time{}, nil
+  }
+  return 0, time.Time{}, err
+}
+
+
+// Send the <metric>_present gauge of the series if the behavior is present.
+// It has the labels of the metric
+func emit_present_metric(ch chan<- prometheus.Metric, config Collector_connection_data, desc *prometheus.Desc, present bool, label_values ...string) {
+  if config.No_data != NO_DATA_PRESENT {
+    return
+  }
+  metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 0, label_values...)
+  if err != nil {
+    return
+  }
+  sample, ok := newSample(metric)
+  if !ok {
+    return
+  }
+  sample.Name += present_suffix
+  sample.Help = "Whether the series of " + get_desc_fq_name(desc) + " has current data in Cloudera Manager (1 for data)."
+  sample.Value = 0
+  if present {
+    sample.Value = 1
+  }
+  if present_metric, err := newSampleMetric(sample); err == nil {
+    ch <- present_metric
+  }
+}
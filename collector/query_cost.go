@@ -0,0 +1,103 @@
+/*
+ *
+ * title           :collector/query_cost.go
+ * description     :Accounting of the TimeSeries queries made to Cloudera
+ *                  Manager and the datapoints returned
+ * author          :Enes Erdoğan
+ * date            :2025/05/05
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+
+  // Own libraries
+  jp "keedio/cloudera_exporter/json_parser"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Collector label of the queries not made by a scraper (query command...)
+const COST_NO_COLLECTOR = "none"
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+type collector_name_key struct{}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Cumulative counters shared by all the scrapes, so the load added to
+// Cloudera Manager can be shown with rate() or increase()
+var (
+  timeseries_queries_total = prometheus.NewCounterVec(prometheus.CounterOpts {
+    Namespace: namespace,
+    Subsystem: subsystem,
+    Name:      "cm_timeseries_queries_total",
+    Help:      "Total number of TimeSeries queries made to Cloudera Manager, by collector.",
+  }, []string{"collector"})
+
+  timeseries_datapoints_total = prometheus.NewCounterVec(prometheus.CounterOpts {
+    Namespace: namespace,
+    Subsystem: subsystem,
+    Name:      "cm_datapoints_total",
+    Help:      "Total number of datapoints returned by the TimeSeries queries to Cloudera Manager, by collector.",
+  }, []string{"collector"})
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns a context with the name of the collector making the queries
+func with_collector_name(ctx context.Context, name string) context.Context {
+  return context.WithValue(ctx, collector_name_key{}, name)
+}
+
+
+// Returns the name of the collector making the queries
+func get_collector_name(ctx context.Context) string {
+  if ctx != nil {
+    if name, ok := ctx.Value(collector_name_key{}).(string); ok {
+      return name
+    }
+  }
+  return COST_NO_COLLECTOR
+}
+
+
+// Count a TimeSeries query
+func count_timeseries_query(ctx context.Context) {
+  timeseries_queries_total.WithLabelValues(get_collector_name(ctx)).Inc()
+}
+
+
+// Count the datapoints of a TimeSeries query response
+func count_timeseries_datapoints(ctx context.Context, json_parsed jp.Timeseries_response) {
+  timeseries_datapoints_total.WithLabelValues(get_collector_name(ctx)).Add(float64(jp.Get_timeseries_datapoints_num(json_parsed)))
+}
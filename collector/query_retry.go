@@ -0,0 +1,88 @@
+/*
+ *
+ * title           :collector/query_retry.go
+ * description     :Retry of the TimeSeries queries that time out with a
+ *                  shorter window and a coarser rollup
+ * author          :Enes Erdoğan
+ * date            :2025/05/19
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "net"
+  "net/http"
+  "time"
+
+  // Own libraries
+  cm "keedio/cloudera_exporter/cm_client"
+  jp "keedio/cloudera_exporter/json_parser"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Retries of the timed out queries, by the window and rollup of the retry
+var timeseries_timeout_retries_total = prometheus.NewCounterVec(prometheus.CounterOpts {
+  Namespace: namespace,
+  Subsystem: subsystem,
+  Name:      "timeseries_timeout_retries_total",
+  Help:      "Total number of TimeSeries queries retried with a shorter window and a coarser rollup after a timeout, by collector and the window and rollup of the retry.",
+}, []string{"collector", "window", "rollup"})
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns true if the request or Cloudera Manager (504 status) timed out.
+// The timeouts of the scrape are not, as a retry has no time to finish
+func is_timeout_error(ctx context.Context, err error) bool {
+  if err == nil || (ctx != nil && ctx.Err() != nil) {
+    return false
+  }
+  if cm.Is_http_status(err, http.StatusGatewayTimeout) {
+    return true
+  }
+  net_err, ok := err.(net.Error)
+  return ok && net_err.Timeout()
+}
+
+
+// Make the timed out query again with half the window and the next coarser
+// rollup, up to the configured number of retries
+func retry_timed_out_timeseries_query(ctx context.Context, config Collector_connection_data, query string, err error) (jp.Timeseries_response, error) {
+  var json_parsed jp.Timeseries_response
+  window, rollup := config.Timeseries_window, config.Desired_rollup
+  if window == 0 {
+    window = jp.TIMESERIES_DEFAULT_WINDOW
+  }
+
+  for retry := 0; retry < config.Timeout_retries && is_timeout_error(ctx, err); retry++ {
+    window /= 2
+    rollup = jp.Get_coarser_rollup(rollup)
+    log.Warn_msg("Query %s timed out. Retrying with a %s window and %s rollup", query, window, rollup)
+    timeseries_timeout_retries_total.WithLabelValues(get_collector_name(ctx), window.String(), rollup).Inc()
+
+    to := time.Now()
+    json_parsed, err = make_and_parse_timeseries_window_query(ctx, config, query, jp.Encode_timeseries_window(to.Add(-window), to, rollup, false))
+  }
+  return json_parsed, err
+}
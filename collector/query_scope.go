@@ -0,0 +1,153 @@
+/*
+ *
+ * title           :collector/query_scope.go
+ * description     :Fallback to broader TimeSeries scopes when the user is not
+ *                  allowed to read the role-level metrics
+ * author          :Enes Erdoğan
+ * date            :2025/02/24
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "net/http"
+  "sync"
+  "time"
+
+  // Own libraries
+  cm "keedio/cloudera_exporter/cm_client"
+  jp "keedio/cloudera_exporter/json_parser"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Time a query is collected with a broader scope before the requested one is
+// tried again, as the permissions of the user can be granted later
+const DEGRADED_SCOPE_RETRY_INTERVAL = time.Hour
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Broader scope a query is collected with, and when the requested one is
+// tried again
+type degraded_scope struct {
+  scope string
+  retry_at time.Time
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Scope used by each query after a permission error. Shared by all the
+// scrapes, so the forbidden scopes are not queried again until the retry
+var degraded_scopes struct {
+  sync.RWMutex
+  scopes map[string]degraded_scope
+}
+
+// Set to 1 when the metric is collected with a broader scope than the one of
+// its query because the user is not allowed to read it
+var degradedScopeDesc = new_desc(
+  prometheus.BuildFQName(namespace, subsystem, "degraded_scope"),
+  "Whether the metric is collected with a broader scope than requested due to a permission error (1 for degraded)",
+  []string{"metric", "scope"},
+  nil,
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns true if Cloudera Manager rejected the query for lack of permissions
+func is_permission_error(err error) bool {
+  return cm.Is_http_status(err, http.StatusForbidden)
+}
+
+
+// Make the TimeSeries query with the scope that the user is allowed to read.
+// If Cloudera Manager rejects the query for lack of permissions, it is made
+// again with broader scopes until one is permitted, as long as its metrics
+// have a counterpart in the broader scope. The requested scope is tried
+// again every DEGRADED_SCOPE_RETRY_INTERVAL.
+// Returns the result and the scope used
+func make_and_parse_scoped_timeseries_query(ctx context.Context, config Collector_connection_data, query string) (jp.Timeseries_response, string, error) {
+  requested_scope := cm.Get_tsquery_scope(query)
+  if requested_scope == "" {
+    json_parsed, err := make_and_parse_timeseries_query(ctx, config, query)
+    return json_parsed, requested_scope, err
+  }
+
+  // The query is collected with any scope by the replica of the requested one
+  if !get_shard(ctx).owns(query) {
+    return jp.Timeseries_response{}, requested_scope, error_not_in_shard
+  }
+  ctx = without_shard(ctx)
+
+  // Scope already degraded in a previous scrape, unless it is time to retry
+  // the requested one
+  scope := requested_scope
+  degraded_scopes.RLock()
+  degraded, ok := degraded_scopes.scopes[query]
+  degraded_scopes.RUnlock()
+  if ok && time.Now().Before(degraded.retry_at) {
+    scope = degraded.scope
+  }
+
+  first_scope := scope
+  scoped_query, _ := cm.Rescope_tsquery(query, scope)
+  json_parsed, err := make_and_parse_timeseries_query(ctx, config, scoped_query)
+  for _, broader_scope := range cm.Get_broader_scopes(scope) {
+    if !is_permission_error(err) {
+      break
+    }
+    broader_query, ok := cm.Rescope_tsquery(query, broader_scope)
+    if !ok {
+      log.Warn_msg("Not allowed to query %s with %s scope, and its metrics have no counterpart with %s scope", query, scope, broader_scope)
+      break
+    }
+    log.Warn_msg("Not allowed to query %s with %s scope. Falling back to %s scope", query, scope, broader_scope)
+    scope = broader_scope
+    json_parsed, err = make_and_parse_timeseries_query(ctx, config, broader_query)
+  }
+  if err != nil {
+    return json_parsed, scope, err
+  }
+
+  // The requested scope is permitted again, or has just been degraded
+  degraded_scopes.Lock()
+  defer degraded_scopes.Unlock()
+  if scope == requested_scope {
+    delete(degraded_scopes.scopes, query)
+  } else if scope != first_scope {
+    if degraded_scopes.scopes == nil {
+      degraded_scopes.scopes = make(map[string]degraded_scope)
+    }
+    degraded_scopes.scopes[query] = degraded_scope{scope, time.Now().Add(DEGRADED_SCOPE_RETRY_INTERVAL)}
+  }
+  return json_parsed, scope, nil
+}
@@ -0,0 +1,161 @@
+/*
+ *
+ * title           :collector/scrape_scope.go
+ * description     :Restriction of a scrape to some clusters and services, so
+ *                  large Cloudera Manager estates can be split in several
+ *                  scrape jobs
+ * author          :Enes Erdoğan
+ * date            :2025/09/01
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "strings"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Clusters and services a scrape is restricted to. Empty lists don't
+// restrict anything
+type Scrape_scope struct {
+  Clusters []string
+  Services []string
+}
+
+type scrape_scope_key struct{}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Labels with the name of the cluster of a metric
+var cluster_scope_labels = []string{"cluster", "cluster_name"}
+
+// Labels with the name of the service of a metric
+var service_scope_labels = []string{"service", "service_name", "serviceName"}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns true if the scope does not restrict the scrape
+func (s Scrape_scope) Is_empty() bool {
+  return len(s.Clusters) == 0 && len(s.Services) == 0
+}
+
+
+// Returns true if the name is in the list, or the list is empty
+func in_scope(names []string, name string) bool {
+  if len(names) == 0 {
+    return true
+  }
+  for _, scoped := range names {
+    if scoped == name {
+      return true
+    }
+  }
+  return false
+}
+
+
+// Returns true if the cluster is in the scope
+func (s Scrape_scope) includes_cluster(cluster string) bool {
+  return in_scope(s.Clusters, cluster)
+}
+
+
+// Returns true if the service is in the scope
+func (s Scrape_scope) includes_service(service string) bool {
+  return in_scope(s.Services, service)
+}
+
+
+// Returns true if the entity (a service or one of its roles, named
+// <service>-<ROLE TYPE>-<id> by Cloudera Manager) is in the scope
+func (s Scrape_scope) includes_entity(entity string) bool {
+  if len(s.Services) == 0 {
+    return true
+  }
+  for _, scoped := range s.Services {
+    if entity == scoped || strings.HasPrefix(entity, scoped + "-") {
+      return true
+    }
+  }
+  return false
+}
+
+
+// Returns true if the metric with these labels is in the scope. The metrics
+// without cluster or service labels (those of the exporter itself) are
+// always in the scope
+func (s Scrape_scope) Includes_labels(labels map[string]string) bool {
+  for _, name := range cluster_scope_labels {
+    if value, ok := labels[name]; ok && !s.includes_cluster(value) {
+      return false
+    }
+  }
+  for _, name := range service_scope_labels {
+    if value, ok := labels[name]; ok && !s.includes_service(value) {
+      return false
+    }
+  }
+  if value, ok := labels["entityName"]; ok && !s.includes_entity(value) {
+    return false
+  }
+  return true
+}
+
+
+// Returns true if the metric is in the scope
+func (s Scrape_scope) includes_metric(metric prometheus.Metric) bool {
+  if s.Is_empty() {
+    return true
+  }
+  var written dto.Metric
+  if err := metric.Write(&written); err != nil {
+    return true
+  }
+  labels := make(map[string]string, len(written.GetLabel()))
+  for _, label := range written.GetLabel() {
+    labels[label.GetName()] = label.GetValue()
+  }
+  return s.Includes_labels(labels)
+}
+
+
+// Returns a context that restricts the scrape to the scope
+func With_scrape_scope(ctx context.Context, scope Scrape_scope) context.Context {
+  if scope.Is_empty() {
+    return ctx
+  }
+  return context.WithValue(ctx, scrape_scope_key{}, scope)
+}
+
+
+// Returns the scope of the scrape, empty if it is not restricted
+func get_scrape_scope(ctx context.Context) Scrape_scope {
+  scope, _ := ctx.Value(scrape_scope_key{}).(Scrape_scope)
+  return scope
+}
@@ -0,0 +1,205 @@
+/*
+ *
+ * title           :collector/service_collector.go
+ * description     :Registry of the collectors of Cloudera services and the
+ *                  discovery of the services they share
+ * author          :Enes Erdoğan
+ * date            :2025/03/24
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "fmt"
+    "net/url"
+    "sort"
+    "sync"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+)
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// ClouderaServiceCollector is a Scraper of the services of a type (ZOOKEEPER,
+// HDFS, KAFKA, HBASE...). It shares the Cloudera Manager client, the
+// configuration and the discovery of the services with the other collectors.
+// The collectors are enabled with the <name>_module key of the [modules]
+// section of the config file
+type ClouderaServiceCollector interface {
+    Scraper
+
+    // ServiceType is the Cloudera Manager type of the collected services
+    ServiceType() string
+}
+
+// clouderaService identifies a service deployed in a Cloudera cluster
+type clouderaService struct {
+    Cluster string
+    Name    string
+    Type    string
+}
+
+// Services discovered in a scrape, by type. Shared by the collectors of the
+// scrape so the services are listed once
+type discoveryCache struct {
+    sync.Mutex
+    services map[string]*discoveredServices
+}
+
+type discoveredServices struct {
+    once     sync.Once
+    services []clouderaService
+    err      error
+}
+
+type discoveryCacheKey struct{}
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+var serviceCollectors = struct {
+    sync.RWMutex
+    collectors map[string]ClouderaServiceCollector
+}{collectors: make(map[string]ClouderaServiceCollector)}
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// RegisterServiceCollector adds a collector to the registry. Names must be
+// unique
+func RegisterServiceCollector(collector ClouderaServiceCollector) error {
+    serviceCollectors.Lock()
+    defer serviceCollectors.Unlock()
+    if _, ok := serviceCollectors.collectors[collector.Name()]; ok {
+        return fmt.Errorf("Service collector %s already registered", collector.Name())
+    }
+    serviceCollectors.collectors[collector.Name()] = collector
+    return nil
+}
+
+// MustRegisterServiceCollector registers the collector and panics on error
+func MustRegisterServiceCollector(collector ClouderaServiceCollector) {
+    if err := RegisterServiceCollector(collector); err != nil {
+        panic(err)
+    }
+}
+
+// ServiceCollectors returns the registered collectors sorted by name
+func ServiceCollectors() []ClouderaServiceCollector {
+    serviceCollectors.RLock()
+    defer serviceCollectors.RUnlock()
+    collectors := make([]ClouderaServiceCollector, 0, len(serviceCollectors.collectors))
+    for _, collector := range serviceCollectors.collectors {
+        collectors = append(collectors, collector)
+    }
+    sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name() < collectors[j].Name() })
+    return collectors
+}
+
+// withDiscoveryCache returns a context with a new cache of discovered services
+func withDiscoveryCache(ctx context.Context) context.Context {
+    return context.WithValue(ctx, discoveryCacheKey{}, &discoveryCache{services: make(map[string]*discoveredServices)})
+}
+
+// discoverServices lists every service of the given type of every cluster
+// managed by Cloudera Manager that is in the shard of the collection. The
+// services are split by cluster and name between the shards
+func discoverServices(ctx context.Context, config Collector_connection_data, serviceType string) ([]clouderaService, error) {
+    services, err := cachedServices(ctx, config, serviceType)
+    shard := get_shard(ctx)
+    if err != nil || !shard.Is_enabled() {
+        return services, err
+    }
+    sharded := []clouderaService{}
+    for _, service := range services {
+        if shard.owns(service.Cluster + "/" + service.Name) {
+            sharded = append(sharded, service)
+        }
+    }
+    return sharded, nil
+}
+
+// cachedServices lists every service of the given type. The result is cached
+// for the scrape, and shared by the shards
+func cachedServices(ctx context.Context, config Collector_connection_data, serviceType string) ([]clouderaService, error) {
+    cache, ok := ctx.Value(discoveryCacheKey{}).(*discoveryCache)
+    if !ok {
+        return listServices(ctx, config, serviceType)
+    }
+
+    cache.Lock()
+    entry, ok := cache.services[serviceType]
+    if !ok {
+        entry = &discoveredServices{}
+        cache.services[serviceType] = entry
+    }
+    cache.Unlock()
+
+    entry.once.Do(func() {
+        entry.services, entry.err = listServices(ctx, config, serviceType)
+    })
+    return entry.services, entry.err
+}
+
+// listServices queries Cloudera Manager for the services of the given type
+func listServices(ctx context.Context, config Collector_connection_data, serviceType string) ([]clouderaService, error) {
+    ctx, stop := start_outer_phase(ctx, PHASE_DISCOVERY)
+    defer stop()
+
+    jsonClusters, err := make_and_parse_api_query(ctx, config, "clusters")
+    if err != nil {
+        return nil, err
+    }
+
+    // Only the clusters (by name or display name) and services in the scope
+    // of the scrape are listed
+    scope := get_scrape_scope(ctx)
+    services := []clouderaService{}
+    displayNames := jp.Get_api_query_clusters_list(jsonClusters)
+    for clusterIndex, cluster := range jp.Get_api_query_cluster_names_list(jsonClusters) {
+        clusterName := cluster.String()
+        if !scope.includes_cluster(clusterName) && (clusterIndex >= len(displayNames) || !scope.includes_cluster(displayNames[clusterIndex].String())) {
+            continue
+        }
+        jsonServices, err := make_and_parse_api_query(ctx, config, fmt.Sprintf("clusters/%s/services", url.PathEscape(clusterName)))
+        if err != nil {
+            log.Err_msg("Cannot list services of cluster %s: %s", clusterName, err)
+            continue
+        }
+        numServices := jp.Get_api_query_items_num(jsonServices)
+        for serviceIndex := 0; serviceIndex < numServices; serviceIndex++ {
+            if jp.Get_api_query_service_type(jsonServices, serviceIndex) != serviceType {
+                continue
+            }
+            serviceName := jp.Get_api_query_service_name(jsonServices, serviceIndex)
+            if !scope.includes_service(serviceName) {
+                continue
+            }
+            services = append(services, clouderaService{
+                Cluster: clusterName,
+                Name:    serviceName,
+                Type:    serviceType,
+            })
+        }
+    }
+    return services, nil
+}
+
+// apiPath returns the Cloudera Manager API path of the service, followed by
+// the optional sub-resource
+func (s clouderaService) apiPath(resource string) string {
+    path := fmt.Sprintf("clusters/%s/services/%s", url.PathEscape(s.Cluster), url.PathEscape(s.Name))
+    if resource != "" {
+        path = fmt.Sprintf("%s/%s", path, resource)
+    }
+    return path
+}
@@ -0,0 +1,124 @@
+/*
+ *
+ * title           :collector/sharding.go
+ * description     :Split of the collection between the replicas of the
+ *                  exporter, so each one only queries its share of Cloudera
+ *                  Manager
+ * author          :Enes Erdoğan
+ * date            :2025/11/24
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "errors"
+  "hash/fnv"
+  "strconv"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Shard of the collection of this replica: its index, from 0, and the number
+// of replicas. One replica (or less) collects everything
+type Shard struct {
+  Index int
+  Total int
+}
+
+type shard_key struct{}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Returned instead of making the queries that another replica collects
+var error_not_in_shard = errors.New("Query collected by another shard")
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns true if the collection is split between several replicas
+func (s Shard) Is_enabled() bool {
+  return s.Total > 1
+}
+
+
+// Returns the weight of the key for the replica, a 64 bits hash of both
+func shard_weight(key string, index int) uint64 {
+  hash := fnv.New64a()
+  hash.Write([]byte(key))
+  hash.Write([]byte{0})
+  hash.Write([]byte(strconv.Itoa(index)))
+  // FNV hashes of close strings are close too, so they are mixed (the
+  // finalizer of MurmurHash3) before being compared
+  weight := hash.Sum64()
+  weight ^= weight >> 33
+  weight *= 0xff51afd7ed558ccd
+  weight ^= weight >> 33
+  weight *= 0xc4ceb9fe1a85ec53
+  weight ^= weight >> 33
+  return weight
+}
+
+
+// Returns true if the key (a query, a cluster or a service) is collected by
+// this replica: the one with the highest weight for the key (rendezvous
+// hashing). Changing the number of replicas only moves the keys of the added
+// or removed ones
+func (s Shard) owns(key string) bool {
+  if !s.Is_enabled() {
+    return true
+  }
+  owner, highest := 0, uint64(0)
+  for index := 0; index < s.Total; index++ {
+    if weight := shard_weight(key, index); index == 0 || weight > highest {
+      owner, highest = index, weight
+    }
+  }
+  return owner == s.Index
+}
+
+
+// Returns a context that restricts the collection to the shard
+func with_shard(ctx context.Context, shard Shard) context.Context {
+  if !shard.Is_enabled() {
+    return ctx
+  }
+  return context.WithValue(ctx, shard_key{}, shard)
+}
+
+
+// Returns a context that is not restricted to any shard, for the work done
+// on behalf of a key the replica already owns
+func without_shard(ctx context.Context) context.Context {
+  if !get_shard(ctx).Is_enabled() {
+    return ctx
+  }
+  return context.WithValue(ctx, shard_key{}, Shard{})
+}
+
+
+// Returns the shard of the collection, which owns everything if it is not
+// restricted
+func get_shard(ctx context.Context) Shard {
+  shard, _ := ctx.Value(shard_key{}).(Shard)
+  return shard
+}
@@ -0,0 +1,85 @@
+/*
+ *
+ * title           :collector/standby.go
+ * description     :Cold-standby mode: the exporter is configured but does not
+ *                  query Cloudera Manager until it is activated
+ * author          :Enes Erdoğan
+ * date            :2025/04/07
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "sync"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Whether the exporter is in standby. Shared by all the scrapes
+var standby struct {
+  sync.RWMutex
+  enabled bool
+}
+
+var standbyDesc = new_desc(
+  prometheus.BuildFQName(namespace, subsystem, "standby"),
+  "Whether the exporter is in standby and does not query Cloudera Manager (1 for standby).",
+  nil,
+  nil,
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Put the exporter in standby (true) or activate it (false)
+func Set_standby(enabled bool) {
+  standby.Lock()
+  defer standby.Unlock()
+  if standby.enabled != enabled {
+    if enabled {
+      log.Warn_msg("Exporter in standby: Cloudera Manager will not be queried until it is activated")
+    } else {
+      log.Info_msg("Exporter activated: querying Cloudera Manager")
+    }
+  }
+  standby.enabled = enabled
+}
+
+
+// Returns true if the exporter is in standby
+func Is_standby() bool {
+  standby.RLock()
+  defer standby.RUnlock()
+  return standby.enabled
+}
+
+
+// Send the standby state of the exporter
+func collect_standby(ch chan<- prometheus.Metric, enabled bool) {
+  value := 0.0
+  if enabled {
+    value = 1
+  }
+  ch <- prometheus.MustNewConstMetric(standbyDesc, prometheus.GaugeValue, value)
+}
@@ -51,7 +51,7 @@ const STATUS_SCRAPER_NAME = "status"
  * ====================================================================== */
 var  (
   // Cluster Status Metric Definition
-	globalClusterDesc = prometheus.NewDesc(
+	globalClusterDesc = new_desc(
       prometheus.BuildFQName(namespace, "status", "cluster_up"),
       "Cluster Up",
       []string{"cluster_name", "full_version", "cluster_state", "maintenance_mode"},
@@ -59,7 +59,7 @@ var  (
   )
 
   // Host Status Metric Definition
-	globalHostsDesc = prometheus.NewDesc(
+	globalHostsDesc = new_desc(
       prometheus.BuildFQName(namespace, "status", "host_up"),
       "Host Up",
       []string{"host_id", "hostname", "ip", "commission_state", "maintenance_mode", "health_summary"},
@@ -67,7 +67,7 @@ var  (
   )
 
   // Service Status Metric Definition
-	globalServiceDesc = prometheus.NewDesc(
+	globalServiceDesc = new_desc(
       prometheus.BuildFQName(namespace, "status", "service_up"),
       "Service Name up",
       []string{"service_name", "service_type", "service_state", "health_summary"},
@@ -75,7 +75,7 @@ var  (
   )
 
   // Role Status Metric Definition
-	globalRoleDesc = prometheus.NewDesc(
+	globalRoleDesc = new_desc(
       prometheus.BuildFQName(namespace, "status", "role_up"),
       "Role Name up",
       []string{"role_name", "host_id", "host_name", "role_type", "role_state", "health_summary", "service"},
@@ -224,6 +224,9 @@ func scrape_cluster_cm_services_status(ctx context.Context, config Collector_con
 
 // Function that returns to a map with the hostName and HostId
 func scrape_hostName(ctx context.Context, config Collector_connection_data, query string) map[string]string {
+  ctx, stop := start_outer_phase(ctx, PHASE_DISCOVERY)
+  defer stop()
+
   json_parsed,err:= make_and_parse_api_query(ctx, config, query)
   if err != nil {
 
@@ -323,12 +326,24 @@ func (ScrapeStatus) Scrape (ctx context.Context, config *Collector_connection_da
     return nil
   }
 
-  eval_scrape(scrape_cluster_hosts_status(ctx, *config, "hosts", ch), &success_queries, &error_queries)
-  eval_scrape(scrape_cluster_cm_services_status(ctx, *config, "cm/service", ch), &success_queries, &error_queries)
+  // The hosts, the Cloudera Management services and each cluster are split
+  // between the shards
+  shard := get_shard(ctx)
+  if shard.owns("hosts") {
+    eval_scrape(scrape_cluster_hosts_status(ctx, *config, "hosts", ch), &success_queries, &error_queries)
+  }
+  if shard.owns("cm/service") {
+    eval_scrape(scrape_cluster_cm_services_status(ctx, *config, "cm/service", ch), &success_queries, &error_queries)
+  }
 
+  // Only the clusters in the scope of the scrape
+  scope := get_scrape_scope(ctx)
   clustersName := jp.Get_api_query_clusters_list(json_clusters)
   for c_clusters := 0; c_clusters < len(clustersName); c_clusters++ {
     cluster := clustersName[c_clusters].String()
+    if !scope.includes_cluster(cluster) || !shard.owns(cluster) {
+      continue
+    }
 
     eval_scrape(scrape_cluster_status(ctx, *config, fmt.Sprintf("clusters/%s", cluster), ch), &success_queries, &error_queries)
     eval_scrape(scrape_cluster_services_status(ctx, *config, fmt.Sprintf("clusters/%s/services", cluster), ch), &success_queries, &error_queries)
@@ -0,0 +1,131 @@
+/*
+ *
+ * title           :collector/stopwatch.go
+ * description     :Time spent in each phase of a scrape
+ * author          :Enes Erdoğan
+ * date            :2025/02/17
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "sync"
+  "time"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Phases of a scrape
+const (
+  PHASE_DISCOVERY =   "discovery"
+  PHASE_QUERY_BUILD = "query_build"
+  PHASE_HTTP =        "http"
+  PHASE_DECODE =      "decode"
+  PHASE_EMIT =        "emit"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Accumulated time of each phase of a scrape. The scrapers run concurrently,
+// so the sum of the phases can be greater than the scrape duration
+type scrape_stopwatch struct {
+  sync.Mutex
+  phases map[string]time.Duration
+}
+
+type stopwatch_key struct{}
+
+// Phase that contains the nested ones, which are not accounted separately
+type stopwatch_outer_phase_key struct{}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+var scrapePhaseDurationDesc = new_desc(
+  prometheus.BuildFQName(namespace, subsystem, "scrape_phase_seconds"),
+  "Time spent in each phase of the last scrape, summed over all the scrapers. Discovery includes its own API requests.",
+  []string{"phase"},
+  nil,
+)
+
+var scrape_phases = []string{PHASE_DISCOVERY, PHASE_QUERY_BUILD, PHASE_HTTP, PHASE_DECODE, PHASE_EMIT}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns a context with a new stopwatch
+func with_stopwatch(ctx context.Context) (context.Context, *scrape_stopwatch) {
+  stopwatch := &scrape_stopwatch{phases: make(map[string]time.Duration)}
+  return context.WithValue(ctx, stopwatch_key{}, stopwatch), stopwatch
+}
+
+
+// Add the time elapsed since start to the phase of the stopwatch in the
+// context. Nested phases of an outer one (e.g. the requests made during the
+// discovery) are not accounted
+func record_phase(ctx context.Context, phase string, start time.Time) {
+  if ctx == nil || ctx.Value(stopwatch_outer_phase_key{}) != nil {
+    return
+  }
+  stopwatch, ok := ctx.Value(stopwatch_key{}).(*scrape_stopwatch)
+  if !ok {
+    return
+  }
+  stopwatch.add(phase, time.Since(start))
+}
+
+
+// Start an outer phase. Returns the context for the nested calls and the
+// function that stops the phase
+func start_outer_phase(ctx context.Context, phase string) (context.Context, func()) {
+  if ctx == nil {
+    return ctx, func() {}
+  }
+  start := time.Now()
+  return context.WithValue(ctx, stopwatch_outer_phase_key{}, phase), func() {
+    record_phase(ctx, phase, start)
+  }
+}
+
+
+func (s *scrape_stopwatch) add(phase string, elapsed time.Duration) {
+  s.Lock()
+  s.phases[phase] += elapsed
+  s.Unlock()
+}
+
+
+// Send the time of each phase to the channel
+func (s *scrape_stopwatch) collect(ch chan<- prometheus.Metric) {
+  s.Lock()
+  defer s.Unlock()
+  for _, phase := range scrape_phases {
+    ch <- prometheus.MustNewConstMetric(scrapePhaseDurationDesc, prometheus.GaugeValue, s.phases[phase].Seconds(), phase)
+  }
+}
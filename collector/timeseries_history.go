@@ -0,0 +1,122 @@
+/*
+ *
+ * title           :collector/timeseries_history.go
+ * description     :Historical windows of the metrics, for the query command
+ * author          :Enes Erdoğan
+ * date            :2025/03/10
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "sort"
+  "time"
+
+  // Own libraries
+  jp "keedio/cloudera_exporter/json_parser"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Datapoint of a TimeSerie
+type Datapoint struct {
+  Timestamp time.Time `json:"timestamp"`
+  Value float64 `json:"value"`
+}
+
+// Datapoints of a TimeSerie in a time window
+type Timeseries_history struct {
+  Cluster string `json:"cluster"`
+  Entity_name string `json:"entityName"`
+  Datapoints []Datapoint `json:"datapoints"`
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the TSquery of each exported metric of the TimeSeries modules
+func get_metric_queries() map[string]string {
+  queries := make(map[string]string)
+  for _, relationships := range [][]relation{hdfs_query_variable_relationship, host_query_variable_relationship, zkQueryVariableRelationship, zkHealthRateRelationship} {
+    for _, r := range relationships {
+      queries[get_desc_fq_name(r.Metric_struct)] = r.Query
+    }
+  }
+  for _, r := range impala_query_variable_relationship {
+    if *r.Query != "" {
+      queries[get_desc_fq_name(r.Metric_struct)] = *r.Query
+    }
+  }
+  return queries
+}
+
+
+// Returns the TSquery of a metric (e.g. kbdi_zookeeper_alerts_rate)
+func Get_metric_query(metric_name string) (string, bool) {
+  query, ok := get_metric_queries()[metric_name]
+  return query, ok
+}
+
+
+// Returns the sorted names of the metrics that can be queried
+func Get_metric_names() []string {
+  names := []string{}
+  for name := range get_metric_queries() {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+
+// Fetch all the datapoints of the TSquery between from and to
+func Query_timeseries_history(ctx context.Context, config Collector_connection_data, query string, from time.Time, to time.Time) ([]Timeseries_history, error) {
+  window := jp.Encode_timeseries_window(from, to, config.Desired_rollup, config.Must_use_desired_rollup)
+  json_parsed, err := make_and_parse_timeseries_window_query(ctx, config, query, window)
+  if err != nil {
+    return nil, err
+  }
+  num_ts_series, err := jp.Get_timeseries_num(json_parsed)
+  if err != nil {
+    return nil, err
+  }
+
+  statistic := config.Rollup_statistic
+  if statistic == "" {
+    statistic = jp.ROLLUP_STATISTIC_VALUE
+  }
+  history := make([]Timeseries_history, 0, num_ts_series)
+  for ts_index := 0; ts_index < num_ts_series; ts_index ++ {
+    serie := Timeseries_history {
+      Cluster: jp.Get_timeseries_query_cluster(json_parsed, ts_index),
+      Entity_name: jp.Get_timeseries_query_entity_name(json_parsed, ts_index),
+      Datapoints: []Datapoint{},
+    }
+    num_datapoints := jp.Get_timeseries_query_datapoints_num(json_parsed, ts_index)
+    for datapoint_index := 0; datapoint_index < num_datapoints; datapoint_index ++ {
+      timestamp, value, err := jp.Get_timeseries_query_datapoint(json_parsed, ts_index, datapoint_index, statistic)
+      if err != nil {
+        continue
+      }
+      serie.Datapoints = append(serie.Datapoints, Datapoint{timestamp, value})
+    }
+    history = append(history, serie)
+  }
+  return history, nil
+}
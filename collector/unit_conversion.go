@@ -0,0 +1,149 @@
+/*
+ *
+ * title           :collector/unit_conversion.go
+ * description     :Conversion of the metrics Cloudera Manager reports in
+ *                  milliseconds to the Prometheus base units
+ * author          :Enes Erdoğan
+ * date            :2025/08/11
+ * version         :1.0
+ *
+ */
+package collector
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "strings"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Conversion of a metric to a base unit: its new name, the units (as written
+// in the help) and the factor of the values
+type Unit_rule struct {
+  Name string
+  Unit string
+  Base_unit string
+  Factor float64
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Metrics of the catalog (METRICS_CATALOG.md) not reported in base units,
+// by their legacy name
+var unit_rules = map[string]Unit_rule {
+  "kbdi_host_clock_offset": {"kbdi_host_clock_offset_seconds", "ms", "seconds", 1e-3},
+  "kbdi_host_dns_resolution_time": {"kbdi_host_dns_resolution_time_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_canary_duration_ms": {"kbdi_zookeeper_canary_duration_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_direct_request_latency_min_ms": {"kbdi_zookeeper_direct_request_latency_min_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_direct_request_latency_avg_ms": {"kbdi_zookeeper_direct_request_latency_avg_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_direct_request_latency_max_ms": {"kbdi_zookeeper_direct_request_latency_max_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_request_latency_min_ms": {"kbdi_zookeeper_request_latency_min_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_request_latency_avg_ms": {"kbdi_zookeeper_request_latency_avg_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_request_latency_max_ms": {"kbdi_zookeeper_request_latency_max_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_request_latency_ms": {"kbdi_zookeeper_request_latency_seconds", "ms", "seconds", 1e-3},
+  "kbdi_zookeeper_request_latency_window_ms": {"kbdi_zookeeper_request_latency_window_seconds", "ms", "seconds", 1e-3},
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the conversion of the metric to a base unit, if it has one and the
+// legacy names are not kept
+func get_unit_rule(config Collector_connection_data, name string) (Unit_rule, bool) {
+  if config.Legacy_metric_names {
+    return Unit_rule{}, false
+  }
+  rule, ok := unit_rules[name]
+  return rule, ok
+}
+
+
+// Returns the unit of the values of a metric as Cloudera Manager reports
+// them (ms, seconds or bytes), by its legacy name. Empty if it has no unit
+func Get_metric_unit(name string) string {
+  if rule, ok := unit_rules[name]; ok {
+    return rule.Unit
+  }
+  switch {
+  case strings.HasSuffix(name, "_ms"):
+    return "ms"
+  case strings.HasSuffix(name, "_seconds"):
+    return "seconds"
+  case strings.HasSuffix(name, "_bytes"):
+    return "bytes"
+  }
+  return ""
+}
+
+
+// Returns the help of the metric with the base unit
+func convert_help(help string, rule Unit_rule) string {
+  if converted := strings.Replace(help, "(" + rule.Unit + ")", "(" + rule.Base_unit + ")", -1); converted != help {
+    return converted
+  }
+  return help + " (" + rule.Base_unit + ")"
+}
+
+
+// Returns the metric in its base unit. The present gauges of the converted
+// metrics are renamed too. Other metrics are returned as they are
+func convert_metric_unit(config Collector_connection_data, metric prometheus.Metric) prometheus.Metric {
+  legacy_name := strings.TrimSuffix(get_desc_fq_name(metric.Desc()), present_suffix)
+  rule, ok := get_unit_rule(config, legacy_name)
+  if !ok {
+    return metric
+  }
+  sample, ok := newSample(metric)
+  if !ok {
+    return metric
+  }
+  if legacy_name == sample.Name {
+    sample.Name = rule.Name
+    sample.Help = convert_help(sample.Help, rule)
+    sample.Value *= rule.Factor
+    // The bounds of a histogram and the values of a summary are in the unit
+    // of the metric, their counts are not
+    if sample.Buckets != nil {
+      buckets := make(map[float64]uint64, len(sample.Buckets))
+      for bound, count := range sample.Buckets {
+        buckets[bound * rule.Factor] = count
+      }
+      sample.Buckets = buckets
+    }
+    for quantile, value := range sample.Quantiles {
+      sample.Quantiles[quantile] = value * rule.Factor
+    }
+  } else {
+    sample.Name = rule.Name + present_suffix
+    sample.Help = strings.Replace(sample.Help, legacy_name, rule.Name, -1)
+  }
+  converted, err := newSampleMetric(sample)
+  if err != nil {
+    log.Err_msg("Cannot convert %s to %s: %s", legacy_name, rule.Name, err)
+    return metric
+  }
+  return converted
+}
@@ -18,6 +18,7 @@ import (
     "strings"
 
     // Own libraries
+    cm "keedio/cloudera_exporter/cm_client"
     jp "keedio/cloudera_exporter/json_parser"
     log "keedio/cloudera_exporter/logger"
 
@@ -25,16 +26,14 @@ import (
     "github.com/prometheus/client_golang/prometheus"
 )
 
-/* ======================================================================
- * Data Structs
- * ====================================================================== */
-// None (following the style of hdfs_module.go)
-
 /* ======================================================================
  * Constants with the ZooKeeper module TSquery sentences
  * ====================================================================== */
 const ZK_SCRAPER_NAME = "zookeeper"
 
+// Cloudera Manager type of the ZooKeeper services
+const ZK_SERVICE_TYPE = "ZOOKEEPER"
+
 // --- Base Metric Queries ---
 // Each query is now a single line with proper escaping of quotes.
 const (
@@ -66,7 +65,8 @@ const (
     ZK_EVENTS_INFORMATIONAL_RATE =
     "SELECT LAST(events_informational_rate) WHERE category=\"SERVICE\" AND serviceName=\"ZOOKEEPER\""
 
-    // Percentage of Time with Bad Health
+    // Percentage of Time with Bad Health. The health rates are deprecated by
+    // the health checks of the ZooKeeper Health module
     ZK_HEALTH_BAD_RATE =
     "SELECT LAST(health_bad_rate) WHERE category=\"SERVICE\" AND serviceName=\"ZOOKEEPER\""
 
@@ -99,6 +99,15 @@ const (
     "SELECT LAST(total_alerts_rate_across_clusters)"
 )
 
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Value of a series emitted by collectZKMetric, with its service
+type zkSample struct {
+    service clouderaService
+    value   float64
+}
+
 /* ======================================================================
  * Global variables (Prometheus descriptors)
  * ====================================================================== */
@@ -148,28 +157,43 @@ var (
     zkTotalAlertsRateAcrossClusters = createZKMetricStruct("total_alerts_rate_across_servers",
         "Total alerts rate aggregated across all clusters",
     )
+
+    // Set to 1 when the role-level series of a metric exceed the configured
+    // limit and the metric is exposed aggregated by service
+    zkCardinalityBackoffDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "cardinality_backoff"),
+        "Whether the metric has been downgraded to service-level aggregation due to high cardinality (1 for downgraded)",
+        []string{"metric"},
+        nil,
+    )
 )
 
 // This array ties each query to its corresponding Prometheus descriptor.
 // Add or remove items here based on your needs.
 var zkQueryVariableRelationship = []relation{
     // Base metrics
-    {ZK_ALERTS_RATE,                *zkAlertsRate},
-    {ZK_CANARY_DURATION,            *zkCanaryDuration},
-    {ZK_CURRENT_EPOCH_RATE,         *zkCurrentEpochRate},
-    {ZK_CURRENT_XID,                *zkCurrentXID},
-    {ZK_EVENTS_CRITICAL_RATE,       *zkEventsCriticalRate},
-    {ZK_EVENTS_IMPORTANT_RATE,      *zkEventsImportantRate},
-    {ZK_EVENTS_INFORMATIONAL_RATE,  *zkEventsInformationalRate},
-    {ZK_HEALTH_BAD_RATE,            *zkHealthBadRate},
-    {ZK_HEALTH_CONCERNING_RATE,     *zkHealthConcerningRate},
-    {ZK_HEALTH_DISABLED_RATE,       *zkHealthDisabledRate},
-    {ZK_HEALTH_GOOD_RATE,           *zkHealthGoodRate},
-    {ZK_HEALTH_UNKNOWN_RATE,        *zkHealthUnknownRate},
+    {ZK_ALERTS_RATE,                zkAlertsRate},
+    {ZK_CANARY_DURATION,            zkCanaryDuration},
+    {ZK_CURRENT_EPOCH_RATE,         zkCurrentEpochRate},
+    {ZK_CURRENT_XID,                zkCurrentXID},
+    {ZK_EVENTS_CRITICAL_RATE,       zkEventsCriticalRate},
+    {ZK_EVENTS_IMPORTANT_RATE,      zkEventsImportantRate},
+    {ZK_EVENTS_INFORMATIONAL_RATE,  zkEventsInformationalRate},
 
     // Example aggregator queries
-    {ZK_ALERTS_RATE_ACROSS_CLUSTERS,        *zkAlertsRateAcrossClusters},
-    {ZK_TOTAL_ALERTS_RATE_ACROSS_CLUSTERS,  *zkTotalAlertsRateAcrossClusters},
+    {ZK_ALERTS_RATE_ACROSS_CLUSTERS,        zkAlertsRateAcrossClusters},
+    {ZK_TOTAL_ALERTS_RATE_ACROSS_CLUSTERS,  zkTotalAlertsRateAcrossClusters},
+}
+
+// Percentages of time in each health state, deprecated by the per-check
+// kbdi_zookeeper_health_check. Collected unless health_rates is disabled,
+// while the dashboards and alerts are migrated
+var zkHealthRateRelationship = []relation{
+    {ZK_HEALTH_BAD_RATE,            zkHealthBadRate},
+    {ZK_HEALTH_CONCERNING_RATE,     zkHealthConcerningRate},
+    {ZK_HEALTH_DISABLED_RATE,       zkHealthDisabledRate},
+    {ZK_HEALTH_GOOD_RATE,           zkHealthGoodRate},
+    {ZK_HEALTH_UNKNOWN_RATE,        zkHealthUnknownRate},
 }
 
 /* ======================================================================
@@ -184,7 +208,7 @@ func createZKMetricStruct(metricName string, description string) *prometheus.Des
     }
 
     // Return a Prometheus descriptor
-    return prometheus.NewDesc(
+    return new_desc(
         prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, metricName),
         description,
         []string{"cluster", "entityName"}, // Same label pattern as HDFS
@@ -197,45 +221,105 @@ func createZKMetric(
     ctx context.Context,
     config Collector_connection_data,
     query string,
-    metricStruct prometheus.Desc,
+    metricStruct *prometheus.Desc,
     ch chan<- prometheus.Metric,
 ) bool {
+    _, ok := collectZKMetric(ctx, config, query, metricStruct, ch)
+    return ok
+}
+
+// collectZKMetric emits the series of the query as createZKMetric, and
+// returns their values with the service of each one, for the metrics
+// computed from them
+func collectZKMetric(
+    ctx context.Context,
+    config Collector_connection_data,
+    query string,
+    metricStruct *prometheus.Desc,
+    ch chan<- prometheus.Metric,
+) ([]zkSample, bool) {
+    samples := []zkSample{}
 
-    // 1. Perform the timeseries query
-    jsonParsed, err := make_and_parse_timeseries_query(ctx, config, query)
+    // 1. Perform the timeseries query, with a broader scope if the user is
+    //    not allowed to read the requested one
+    jsonParsed, scope, err := make_and_parse_scoped_timeseries_query(ctx, config, query)
     if err != nil {
-        return false
+        return samples, false
+    }
+    if requestedScope := cm.Get_tsquery_scope(query); requestedScope != "" {
+        ch <- prometheus.MustNewConstMetric(
+            degradedScopeDesc,
+            prometheus.GaugeValue,
+            boolToValue(scope != requestedScope),
+            get_desc_fq_name(metricStruct),
+            scope,
+        )
     }
 
     // 2. Number of timeSeries in the response
     numTsSeries, err := jp.Get_timeseries_num(jsonParsed)
     if err != nil {
-        return false
+        return samples, false
     }
 
-    // 3. Extract metadata for each TimeSeries
+    // 3. Too many role-level series: downgrade to the aggregates by service
+    //    of Cloudera Manager (<metric>_across_servers), which keep the
+    //    meaning of each metric, rates and latencies included. Only the
+    //    role-level queries can back off, the others already return a series
+    //    per service
+    roleLevel := scope == cm.SCOPE_ROLE
+    backoff := false
+    if roleLevel && config.Max_role_series > 0 && numTsSeries > config.Max_role_series {
+        if serviceQuery, ok := cm.Rescope_tsquery(query, cm.SCOPE_SERVICE); !ok {
+            log.Warn_msg("Query %s returned %d series (limit %d), but its metrics have no aggregates by service", query, numTsSeries, config.Max_role_series)
+        } else if serviceParsed, err := make_and_parse_timeseries_query(ctx, config, serviceQuery); err != nil {
+            log.Warn_msg("Query %s returned %d series (limit %d), and its aggregates by service cannot be read: %s", query, numTsSeries, config.Max_role_series, err)
+        } else if numServiceSeries, err := jp.Get_timeseries_num(serviceParsed); err == nil {
+            log.Warn_msg("Query %s returned %d series (limit %d). Collecting its aggregates by service", query, numTsSeries, config.Max_role_series)
+            backoff = true
+            jsonParsed = serviceParsed
+            numTsSeries = numServiceSeries
+        }
+    }
+
+    // 4. Extract metadata for each TimeSeries
     for tsIndex := 0; tsIndex < numTsSeries; tsIndex++ {
         clusterName := jp.Get_timeseries_query_cluster(jsonParsed, tsIndex)
         entityName := jp.Get_timeseries_query_entity_name(jsonParsed, tsIndex)
 
-        // 4. Grab the last data point’s value
-        value, err := jp.Get_timeseries_query_value(jsonParsed, tsIndex)
+        // 5. Grab the last data point’s value
+        value, timestamp, err := get_timeseries_sample(config, jsonParsed, tsIndex)
+        emit_present_metric(ch, config, metricStruct, err == nil, clusterName, entityName)
         if err != nil {
-            // Skip if no valid data
+            // Skip if no valid or stale data
             continue
         }
 
-        // 5. Emit to Prometheus
+        // 6. Emit to Prometheus. The aggregates by service take the service
+        //    as entityName and have no entity labels, as they differ between
+        //    the roles
+        metric := new_timeseries_metric(config, metricStruct, value, timestamp, clusterName, entityName)
+        if !backoff {
+            metric = with_entity_labels(config, jsonParsed, tsIndex, metric)
+        }
+        ch <- metric
+        samples = append(samples, zkSample{
+            service: clouderaService{Cluster: clusterName, Name: jp.Get_timeseries_query_service_name(jsonParsed, tsIndex), Type: ZK_SERVICE_TYPE},
+            value:   value,
+        })
+    }
+
+    // Warning gauge for the role-level metrics
+    if roleLevel {
         ch <- prometheus.MustNewConstMetric(
-            &metricStruct,
+            zkCardinalityBackoffDesc,
             prometheus.GaugeValue,
-            value,
-            clusterName,
-            entityName,
+            boolToValue(backoff),
+            get_desc_fq_name(metricStruct),
         )
     }
 
-    return true
+    return samples, true
 }
 
 /* ======================================================================
@@ -258,8 +342,15 @@ func (ScrapeZookeeperMetrics) Version() float64 {
     return 1.0
 }
 
-// Scrape runs the queries defined in zkQueryVariableRelationship
-// and emits metrics to the Prometheus channel.
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperMetrics) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape runs the queries defined in zkQueryVariableRelationship, and the
+// ones of zkHealthRateRelationship unless the health rates are disabled, and
+// emits metrics to the Prometheus channel. With the direct_zookeeper feature
+// flag, the stats of the servers are also read from them.
 func (ScrapeZookeeperMetrics) Scrape(
     ctx context.Context,
     config *Collector_connection_data,
@@ -270,9 +361,14 @@ func (ScrapeZookeeperMetrics) Scrape(
     successQueries := 0
     errorQueries := 0
 
+    relationships := zkQueryVariableRelationship
+    if config.Health_rates {
+        relationships = append(append([]relation{}, relationships...), zkHealthRateRelationship...)
+    }
+
     // Loop over each (QUERY, PROM_DESC) relation
-    for i := range zkQueryVariableRelationship {
-        rel := zkQueryVariableRelationship[i]
+    for i := range relationships {
+        rel := relationships[i]
         if createZKMetric(ctx, *config, rel.Query, rel.Metric_struct, ch) {
             successQueries++
         } else {
@@ -280,6 +376,17 @@ func (ScrapeZookeeperMetrics) Scrape(
         }
     }
 
+    // Stats read from the servers, with the direct_zookeeper feature flag
+    if Is_feature_enabled(FEATURE_DIRECT_ZOOKEEPER) {
+        services, err := discoverServices(ctx, *config, ZK_SERVICE_TYPE)
+        if err != nil {
+            return err
+        }
+        for _, service := range services {
+            eval_scrape(scrapeZKDirect(ctx, *config, service, ch), &successQueries, &errorQueries)
+        }
+    }
+
     log.Debug_msg(
         "ZK Scraper: %d queries run, %d successful, %d errors",
         successQueries+errorQueries,
@@ -289,5 +396,9 @@ func (ScrapeZookeeperMetrics) Scrape(
     return nil
 }
 
-// Ensure ScrapeZookeeperMetrics implements the Scraper interface
-var _ Scraper = ScrapeZookeeperMetrics{}
+// Ensure ScrapeZookeeperMetrics implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperMetrics{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperMetrics{})
+}
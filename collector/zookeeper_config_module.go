@@ -0,0 +1,227 @@
+/*
+ *
+ * title           :collector/zookeeper_config_module.go
+ * description     :Submodule Collector for the configuration drift of the
+ *                  ZooKeeper services, diffing snapshots of their config
+ * author          :Enes Erdoğan
+ * date            :2025/06/23
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_CONFIG_SCRAPER_NAME = "zookeeper_config"
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Last configuration snapshot of a service and the changes found between
+// the snapshots
+type zkConfigSnapshot struct {
+    values      map[string]string
+    takenAt     time.Time
+    changedAt   time.Time
+    changedKeys float64
+}
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+// Snapshots of each service, kept between scrapes
+var zkConfigSnapshots = struct {
+    sync.Mutex
+    byService map[clouderaService]*zkConfigSnapshot
+}{byService: map[clouderaService]*zkConfigSnapshot{}}
+
+var (
+    zkConfigChangedTimestampDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "config_changed_timestamp_seconds"),
+        "Time when a change of the ZooKeeper service configuration was last detected (0 if none since the exporter started)",
+        []string{"cluster", "service"},
+        nil,
+    )
+    zkConfigChangedKeysDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "config_changed_keys_total"),
+        "Total number of ZooKeeper service configuration keys added, removed or modified between snapshots",
+        []string{"cluster", "service"},
+        nil,
+    )
+)
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// diffZKConfig returns the keys added, removed or modified, sorted
+func diffZKConfig(previous map[string]string, current map[string]string) []string {
+    changed := []string{}
+    for key, value := range current {
+        if previousValue, ok := previous[key]; !ok || previousValue != value {
+            changed = append(changed, key)
+        }
+    }
+    for key := range previous {
+        if _, ok := current[key]; !ok {
+            changed = append(changed, key)
+        }
+    }
+    sort.Strings(changed)
+    return changed
+}
+
+// takeZKConfigSnapshot reads the configuration of the service. Only the
+// values that differ from the defaults are listed by Cloudera Manager
+func takeZKConfigSnapshot(
+    ctx context.Context,
+    config Collector_connection_data,
+    service clouderaService,
+) (map[string]string, error) {
+    jsonParsed, err := make_and_parse_api_query(ctx, config, service.apiPath("config"))
+    if err != nil {
+        return nil, err
+    }
+    values := make(map[string]string)
+    numItems := jp.Get_api_query_items_num(jsonParsed)
+    for itemIndex := 0; itemIndex < numItems; itemIndex++ {
+        values[jp.Get_api_query_config_name(jsonParsed, itemIndex)] = jp.Get_api_query_config_value(jsonParsed, itemIndex)
+    }
+    return values, nil
+}
+
+// scrapeZKConfig takes a new snapshot of the service configuration if the
+// interval has passed, diffs it against the previous one and emits the drift
+// metrics
+func scrapeZKConfig(
+    ctx context.Context,
+    config Collector_connection_data,
+    service clouderaService,
+    ch chan<- prometheus.Metric,
+) bool {
+    zkConfigSnapshots.Lock()
+    snapshot := zkConfigSnapshots.byService[service]
+    due := snapshot == nil || time.Since(snapshot.takenAt) >= config.Config_snapshot_interval
+    zkConfigSnapshots.Unlock()
+
+    success := true
+    if due {
+        values, err := takeZKConfigSnapshot(ctx, config, service)
+        zkConfigSnapshots.Lock()
+        snapshot = zkConfigSnapshots.byService[service]
+        switch {
+        case err != nil:
+            success = false
+        case snapshot == nil:
+            snapshot = &zkConfigSnapshot{values: values, takenAt: time.Now()}
+            zkConfigSnapshots.byService[service] = snapshot
+        default:
+            if changed := diffZKConfig(snapshot.values, values); len(changed) > 0 {
+                log.Warn_msg(
+                    "Configuration of the ZooKeeper service %s/%s changed: %s",
+                    service.Cluster,
+                    service.Name,
+                    strings.Join(changed, ", "),
+                )
+                snapshot.changedAt = time.Now()
+                snapshot.changedKeys += float64(len(changed))
+            }
+            snapshot.values = values
+            snapshot.takenAt = time.Now()
+        }
+        zkConfigSnapshots.Unlock()
+    }
+    if snapshot == nil {
+        return success
+    }
+
+    zkConfigSnapshots.Lock()
+    changedAt, changedKeys := 0.0, snapshot.changedKeys
+    if !snapshot.changedAt.IsZero() {
+        changedAt = float64(snapshot.changedAt.UnixNano()) / 1e9
+    }
+    zkConfigSnapshots.Unlock()
+
+    ch <- prometheus.MustNewConstMetric(zkConfigChangedTimestampDesc, prometheus.GaugeValue, changedAt, service.Cluster, service.Name)
+    ch <- prometheus.MustNewConstMetric(zkConfigChangedKeysDesc, prometheus.CounterValue, changedKeys, service.Cluster, service.Name)
+    return success
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperConfig struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperConfig) Name() string {
+    return ZK_CONFIG_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperConfig) Help() string {
+    return "Collects the configuration drift of the ZooKeeper services from Cloudera Manager"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperConfig) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperConfig) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape discovers the ZooKeeper services and emits their configuration drift
+func (ScrapeZookeeperConfig) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Config Scraper")
+
+    services, err := discoverServices(ctx, *config, ZK_SERVICE_TYPE)
+    if err != nil {
+        return err
+    }
+
+    successQueries := 0
+    errorQueries := 0
+    for _, service := range services {
+        eval_scrape(scrapeZKConfig(ctx, *config, service, ch), &successQueries, &errorQueries)
+    }
+
+    log.Debug_msg(
+        "ZK Config Scraper: %d queries run, %d successful, %d errors",
+        successQueries+errorQueries,
+        successQueries,
+        errorQueries,
+    )
+    return nil
+}
+
+// Ensure ScrapeZookeeperConfig implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperConfig{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperConfig{})
+}
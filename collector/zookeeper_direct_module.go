@@ -0,0 +1,175 @@
+/*
+ *
+ * title           :collector/zookeeper_direct_module.go
+ * description     :Collection of the ZooKeeper metrics directly from the
+ *                  servers with the srvr four letter word, besides Cloudera
+ *                  Manager. Gated by the direct_zookeeper feature flag
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "bufio"
+    "context"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+    "time"
+
+    // Own libraries
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Time to connect to a ZooKeeper server and read its srvr output
+const ZK_DIRECT_TIMEOUT = 5 * time.Second
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+var (
+    zkDirectUpDesc = createZKDirectDesc("up",
+        "Whether the ZooKeeper server answered the srvr command (1 for answered)",
+    )
+    zkDirectLeaderDesc = createZKDirectDesc("leader",
+        "Whether the ZooKeeper server is the leader of the ensemble (1 for leader)",
+    )
+    zkDirectLatencyMinDesc = createZKDirectDesc("request_latency_min_ms",
+        "Min latency of the requests served by the ZooKeeper server since its stats were reset (ms)",
+    )
+    zkDirectLatencyAvgDesc = createZKDirectDesc("request_latency_avg_ms",
+        "Average latency of the requests served by the ZooKeeper server since its stats were reset (ms)",
+    )
+    zkDirectLatencyMaxDesc = createZKDirectDesc("request_latency_max_ms",
+        "Max latency of the requests served by the ZooKeeper server since its stats were reset (ms)",
+    )
+    zkDirectConnectionsDesc = createZKDirectDesc("connections",
+        "Client connections open with the ZooKeeper server",
+    )
+    zkDirectOutstandingDesc = createZKDirectDesc("outstanding_requests",
+        "Requests queued by the ZooKeeper server",
+    )
+    zkDirectZnodeCountDesc = createZKDirectDesc("znode_count",
+        "Znodes in the data tree of the ZooKeeper server",
+    )
+    zkDirectPacketsReceivedDesc = createZKDirectDesc("packets_received_total",
+        "Packets received by the ZooKeeper server since its stats were reset",
+    )
+    zkDirectPacketsSentDesc = createZKDirectDesc("packets_sent_total",
+        "Packets sent by the ZooKeeper server since its stats were reset",
+    )
+)
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// createZKDirectDesc returns the descriptor of a metric read from the
+// ZooKeeper servers, by service and server
+func createZKDirectDesc(metricName string, description string) *prometheus.Desc {
+    return new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "direct_"+metricName),
+        description,
+        []string{"cluster", "service", "server"},
+        nil,
+    )
+}
+
+// readZKSrvr sends the srvr four letter word to the server and returns the
+// fields of its output ("Mode" => "leader", "Node count" => "5"...)
+func readZKSrvr(ctx context.Context, server string) (map[string]string, error) {
+    ctx, cancel := context.WithTimeout(ctx, ZK_DIRECT_TIMEOUT)
+    defer cancel()
+    var dialer net.Dialer
+    conn, err := dialer.DialContext(ctx, "tcp", server)
+    if err != nil {
+        return nil, err
+    }
+    defer conn.Close()
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+    if _, err := io.WriteString(conn, "srvr"); err != nil {
+        return nil, err
+    }
+
+    fields := map[string]string{}
+    scanner := bufio.NewScanner(conn)
+    for scanner.Scan() {
+        if separator := strings.Index(scanner.Text(), ": "); separator > 0 {
+            fields[scanner.Text()[:separator]] = strings.TrimSpace(scanner.Text()[separator+2:])
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    // The servers answer the four letter words they don't allow with a
+    // message, not with the stats
+    if _, ok := fields["Mode"]; !ok {
+        return nil, fmt.Errorf("no srvr stats in the answer, check that srvr is in 4lw.commands.whitelist")
+    }
+    return fields, nil
+}
+
+// emitZKSrvrField emits a numeric field of the srvr output. The fields
+// missing or not numeric are skipped
+func emitZKSrvrField(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value string, labelValues ...string) {
+    if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+        ch <- prometheus.MustNewConstMetric(desc, valueType, parsed, labelValues...)
+    }
+}
+
+// scrapeZKDirect reads the stats of every server of the service and emits
+// them. Returns false if a server can't be read
+func scrapeZKDirect(
+    ctx context.Context,
+    config Collector_connection_data,
+    service clouderaService,
+    ch chan<- prometheus.Metric,
+) bool {
+    servers, err := getZKServers(ctx, config, service)
+    if err != nil {
+        log.Err_msg("Cannot find the servers of the ZooKeeper service %s/%s: %s", service.Cluster, service.Name, err)
+        return false
+    }
+
+    success := true
+    for _, server := range servers {
+        fields, err := readZKSrvr(ctx, server)
+        if err != nil {
+            log.Err_msg("Cannot read the stats of the ZooKeeper server %s: %s", server, err)
+            ch <- prometheus.MustNewConstMetric(zkDirectUpDesc, prometheus.GaugeValue, 0, service.Cluster, service.Name, server)
+            success = false
+            continue
+        }
+        labelValues := []string{service.Cluster, service.Name, server}
+        ch <- prometheus.MustNewConstMetric(zkDirectUpDesc, prometheus.GaugeValue, 1, labelValues...)
+        ch <- prometheus.MustNewConstMetric(zkDirectLeaderDesc, prometheus.GaugeValue, boolToValue(fields["Mode"] == "leader"), labelValues...)
+
+        // Latency min/avg/max: 0/0.5/12
+        if latencies := strings.Split(fields["Latency min/avg/max"], "/"); len(latencies) == 3 {
+            emitZKSrvrField(ch, zkDirectLatencyMinDesc, prometheus.GaugeValue, latencies[0], labelValues...)
+            emitZKSrvrField(ch, zkDirectLatencyAvgDesc, prometheus.GaugeValue, latencies[1], labelValues...)
+            emitZKSrvrField(ch, zkDirectLatencyMaxDesc, prometheus.GaugeValue, latencies[2], labelValues...)
+        }
+        emitZKSrvrField(ch, zkDirectConnectionsDesc, prometheus.GaugeValue, fields["Connections"], labelValues...)
+        emitZKSrvrField(ch, zkDirectOutstandingDesc, prometheus.GaugeValue, fields["Outstanding"], labelValues...)
+        emitZKSrvrField(ch, zkDirectZnodeCountDesc, prometheus.GaugeValue, fields["Node count"], labelValues...)
+        emitZKSrvrField(ch, zkDirectPacketsReceivedDesc, prometheus.CounterValue, fields["Received"], labelValues...)
+        emitZKSrvrField(ch, zkDirectPacketsSentDesc, prometheus.CounterValue, fields["Sent"], labelValues...)
+    }
+    return success
+}
@@ -0,0 +1,295 @@
+/*
+ *
+ * title           :collector/zookeeper_events_module.go
+ * description     :Submodule Collector for the events of the ZooKeeper
+ *                  services, by category and severity
+ * author          :Enes Erdoğan
+ * date            :2025/09/29
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "fmt"
+    "net/url"
+    "sync"
+    "time"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_EVENTS_SCRAPER_NAME = "zookeeper_events"
+
+// Events requested per page of the events API
+const ZK_EVENTS_PAGE_SIZE = 1000
+
+// Severity of the events that set the last critical event timestamp
+const EVENT_SEVERITY_CRITICAL = "CRITICAL"
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Service of the events, with the cluster as the events refer to it (by its
+// name or display name, empty if they don't)
+type zkEventSource struct {
+    cluster string
+    service string
+}
+
+// Category and severity of the counted events
+type zkEventKey struct {
+    category string
+    severity string
+}
+
+// Events counted for a service since the exporter started
+type zkServiceEvents struct {
+    counts       map[zkEventKey]float64
+    lastCritical time.Time
+}
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+// Events of each ZooKeeper service, kept between scrapes, and the cursor of
+// the events already counted: the time Cloudera Manager received the last
+// one and the IDs of the events received at that time. The cursor is checked
+// again when the events fetched are counted, so none is counted twice by
+// concurrent scrapes, and the events of every ZooKeeper service are counted,
+// whatever the scope of the scrape
+var zkEvents = struct {
+    sync.Mutex
+    since    time.Time
+    seenIDs  map[string]bool
+    bySource map[zkEventSource]*zkServiceEvents
+}{bySource: map[zkEventSource]*zkServiceEvents{}}
+
+var (
+    zkEventsTotalDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "events_total"),
+        "Total number of Cloudera Manager events of the ZooKeeper service, by category and severity",
+        []string{"cluster", "service", "category", "severity"},
+        nil,
+    )
+    zkLastCriticalEventDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "last_critical_event_timestamp_seconds"),
+        "Time when the last critical Cloudera Manager event of the ZooKeeper service occurred (0 if none)",
+        []string{"cluster", "service"},
+        nil,
+    )
+)
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// getClusterDisplayNames returns the display name of each cluster, by name
+func getClusterDisplayNames(ctx context.Context, config Collector_connection_data) map[string]string {
+    displayNames := make(map[string]string)
+    jsonClusters, err := make_and_parse_api_query(ctx, config, "clusters")
+    if err != nil {
+        return displayNames
+    }
+    names := jp.Get_api_query_cluster_names_list(jsonClusters)
+    for clusterIndex, displayName := range jp.Get_api_query_clusters_list(jsonClusters) {
+        if clusterIndex < len(names) {
+            displayNames[names[clusterIndex].String()] = displayName.String()
+        }
+    }
+    return displayNames
+}
+
+// fetchZKEvents counts the events of the ZooKeeper services received by
+// Cloudera Manager since the previous scrape (since the lookback in the
+// first one). Nothing is counted unless every page of events is read, so the
+// next scrape fetches them again. The pages are read without zkEvents
+// locked, and the events counted meanwhile by another scrape are skipped
+func fetchZKEvents(ctx context.Context, config Collector_connection_data) bool {
+    zkEvents.Lock()
+    if zkEvents.since.IsZero() {
+        zkEvents.since = time.Now().Add(-config.Events_lookback)
+    }
+    since, seenIDs := zkEvents.since, copyEventIDs(zkEvents.seenIDs)
+    zkEvents.Unlock()
+    query := url.QueryEscape(fmt.Sprintf("timeReceived=ge=%s;serviceType==%s", since.UTC().Format(jp.TIMESERIES_TIME_FORMAT), ZK_SERVICE_TYPE))
+
+    type zkEvent struct {
+        id       string
+        received time.Time
+        source   zkEventSource
+        key      zkEventKey
+        occurred time.Time
+    }
+    newEvents := []zkEvent{}
+    for offset := 0; ; offset += ZK_EVENTS_PAGE_SIZE {
+        jsonParsed, err := make_and_parse_api_query(ctx, config, fmt.Sprintf("events?query=%s&resultOffset=%d&maxResults=%d", query, offset, ZK_EVENTS_PAGE_SIZE))
+        if err != nil {
+            return false
+        }
+
+        numEvents := jp.Get_api_query_items_num(jsonParsed)
+        for eventIndex := 0; eventIndex < numEvents; eventIndex++ {
+            eventID := jp.Get_api_query_event_id(jsonParsed, eventIndex)
+            received, err := time.Parse(time.RFC3339Nano, jp.Get_api_query_event_time_received(jsonParsed, eventIndex))
+            if err != nil || seenIDs[eventID] {
+                continue
+            }
+            occurred, _ := time.Parse(time.RFC3339Nano, jp.Get_api_query_event_time_occurred(jsonParsed, eventIndex))
+            newEvents = append(newEvents, zkEvent{
+                id:       eventID,
+                received: received,
+                source: zkEventSource{
+                    jp.Get_api_query_event_attribute(jsonParsed, eventIndex, "CLUSTER"),
+                    jp.Get_api_query_event_attribute(jsonParsed, eventIndex, "SERVICE"),
+                },
+                key:      zkEventKey{jp.Get_api_query_event_category(jsonParsed, eventIndex), jp.Get_api_query_event_severity(jsonParsed, eventIndex)},
+                occurred: occurred,
+            })
+        }
+        if numEvents < ZK_EVENTS_PAGE_SIZE {
+            break
+        }
+    }
+
+    zkEvents.Lock()
+    defer zkEvents.Unlock()
+    since, seenIDs = zkEvents.since, copyEventIDs(zkEvents.seenIDs)
+    for _, event := range newEvents {
+        // Counted by a scrape that moved the cursor while the pages were read
+        if event.received.Before(zkEvents.since) || (event.received.Equal(zkEvents.since) && zkEvents.seenIDs[event.id]) {
+            continue
+        }
+
+        // Move the cursor to the last event received
+        switch {
+        case event.received.After(since):
+            since, seenIDs = event.received, map[string]bool{event.id: true}
+        case event.received.Equal(since):
+            seenIDs[event.id] = true
+        }
+
+        events := zkEvents.bySource[event.source]
+        if events == nil {
+            events = &zkServiceEvents{counts: map[zkEventKey]float64{}}
+            zkEvents.bySource[event.source] = events
+        }
+        events.counts[event.key]++
+        if event.key.severity == EVENT_SEVERITY_CRITICAL && event.occurred.After(events.lastCritical) {
+            events.lastCritical = event.occurred
+        }
+    }
+    zkEvents.since, zkEvents.seenIDs = since, seenIDs
+    return true
+}
+
+// copyEventIDs returns a copy of the set of event IDs
+func copyEventIDs(ids map[string]bool) map[string]bool {
+    copied := make(map[string]bool, len(ids))
+    for id := range ids {
+        copied[id] = true
+    }
+    return copied
+}
+
+// emitZKEvents emits the event counters and the last critical event of the
+// service, with the events that refer to its cluster by name, by display
+// name or not at all. Must be called with zkEvents locked
+func emitZKEvents(service clouderaService, displayName string, ch chan<- prometheus.Metric) {
+    counts := map[zkEventKey]float64{}
+    var lastCritical time.Time
+    merged := map[string]bool{}
+    for _, cluster := range []string{service.Cluster, displayName, ""} {
+        events := zkEvents.bySource[zkEventSource{cluster, service.Name}]
+        if events == nil || merged[cluster] {
+            continue
+        }
+        merged[cluster] = true
+        for key, count := range events.counts {
+            counts[key] += count
+        }
+        if events.lastCritical.After(lastCritical) {
+            lastCritical = events.lastCritical
+        }
+    }
+
+    for key, count := range counts {
+        ch <- prometheus.MustNewConstMetric(zkEventsTotalDesc, prometheus.CounterValue, count, service.Cluster, service.Name, key.category, key.severity)
+    }
+    lastCriticalSeconds := 0.0
+    if !lastCritical.IsZero() {
+        lastCriticalSeconds = float64(lastCritical.UnixNano()) / 1e9
+    }
+    ch <- prometheus.MustNewConstMetric(zkLastCriticalEventDesc, prometheus.GaugeValue, lastCriticalSeconds, service.Cluster, service.Name)
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperEvents struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperEvents) Name() string {
+    return ZK_EVENTS_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperEvents) Help() string {
+    return "Collects the events of the ZooKeeper services from Cloudera Manager"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperEvents) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperEvents) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape discovers the ZooKeeper services, counts their new events and emits
+// the counters. They are emitted even if the events can't be fetched
+func (ScrapeZookeeperEvents) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Events Scraper")
+
+    services, err := discoverServices(ctx, *config, ZK_SERVICE_TYPE)
+    if err != nil {
+        return err
+    }
+
+    displayNames := getClusterDisplayNames(ctx, *config)
+
+    if !fetchZKEvents(ctx, *config) {
+        log.Err_msg("Cannot fetch the events of the ZooKeeper services")
+    }
+    zkEvents.Lock()
+    defer zkEvents.Unlock()
+    for _, service := range services {
+        emitZKEvents(service, displayNames[service.Cluster], ch)
+    }
+    return nil
+}
+
+// Ensure ScrapeZookeeperEvents implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperEvents{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperEvents{})
+}
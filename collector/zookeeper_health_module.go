@@ -0,0 +1,162 @@
+/*
+ *
+ * title           :collector/zookeeper_health_module.go
+ * description     :Submodule Collector for the ZooKeeper service health checks
+ * author          :Enes Erdoğan
+ * date            :2025/01/20
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "strconv"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_HEALTH_SCRAPER_NAME = "zookeeper_health"
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+var (
+    // One series per health check (ZOOKEEPER_CANARY_HEALTH,
+    // ZOOKEEPER_SERVERS_HEALTHY, ...). The value follows the same state
+    // mapping used by the Status module.
+    zkHealthCheckDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "health_check"),
+        "State of each Cloudera Manager health check of the ZooKeeper service",
+        []string{"cluster", "service", "check", "severity"},
+        nil,
+    )
+    // Same series with the maintenance label (maintenance_label), true while
+    // the service is in maintenance mode, so the alerts can be silenced
+    zkHealthCheckMaintenanceDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "health_check"),
+        "State of each Cloudera Manager health check of the ZooKeeper service",
+        []string{"cluster", "service", "check", "severity", "maintenance"},
+        nil,
+    )
+)
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// scrapeZKHealthChecks emits one gauge per health check of the service
+func scrapeZKHealthChecks(
+    ctx context.Context,
+    config Collector_connection_data,
+    service clouderaService,
+    ch chan<- prometheus.Metric,
+) bool {
+    jsonParsed, err := make_and_parse_api_query(ctx, config, service.apiPath("")+"?view=full")
+    if err != nil {
+        return false
+    }
+
+    // The maintenance mode of the service covers the one of its cluster
+    maintenance := jp.Get_api_query_service_maintenance_mode(jsonParsed) == "true"
+
+    numChecks := jp.Get_api_query_cm_health_checks_num(jsonParsed)
+    for checkIndex := 0; checkIndex < numChecks; checkIndex++ {
+        checkName := jp.Get_api_query_cm_health_check_service_name(jsonParsed, checkIndex)
+        severity := jp.Get_api_query_cm_health_check_service_state(jsonParsed, checkIndex)
+        if config.Maintenance_label {
+            ch <- prometheus.MustNewConstMetric(
+                zkHealthCheckMaintenanceDesc,
+                prometheus.GaugeValue,
+                get_value_from_state(severity),
+                service.Cluster,
+                service.Name,
+                checkName,
+                severity,
+                strconv.FormatBool(maintenance),
+            )
+            continue
+        }
+        ch <- prometheus.MustNewConstMetric(
+            zkHealthCheckDesc,
+            prometheus.GaugeValue,
+            get_value_from_state(severity),
+            service.Cluster,
+            service.Name,
+            checkName,
+            severity,
+        )
+    }
+    return true
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperHealthChecks struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperHealthChecks) Name() string {
+    return ZK_HEALTH_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperHealthChecks) Help() string {
+    return "Collects the individual ZooKeeper health checks from Cloudera Manager"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperHealthChecks) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperHealthChecks) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape discovers the ZooKeeper services and emits their health checks
+func (ScrapeZookeeperHealthChecks) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Health Checks Scraper")
+
+    services, err := discoverServices(ctx, *config, ZK_SERVICE_TYPE)
+    if err != nil {
+        return err
+    }
+
+    successQueries := 0
+    errorQueries := 0
+    for _, service := range services {
+        eval_scrape(scrapeZKHealthChecks(ctx, *config, service, ch), &successQueries, &errorQueries)
+    }
+
+    log.Debug_msg(
+        "ZK Health Scraper: %d queries run, %d successful, %d errors",
+        successQueries+errorQueries,
+        successQueries,
+        errorQueries,
+    )
+    return nil
+}
+
+// Ensure ScrapeZookeeperHealthChecks implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperHealthChecks{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperHealthChecks{})
+}
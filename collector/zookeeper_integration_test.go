@@ -0,0 +1,364 @@
+//go:build integration
+// +build integration
+
+/*
+ *
+ * title           :collector/zookeeper_integration_test.go
+ * description     :Integration tests of the collectors that read the
+ *                  ZooKeeper servers directly, against a real ensemble run
+ *                  in Docker containers and the fake Cloudera Manager.
+ *                  Run with: go test -tags integration ./collector
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "fmt"
+    "net"
+    "os"
+    "os/exec"
+    "strings"
+    "testing"
+    "time"
+
+    // Own libraries
+    "keedio/cloudera_exporter/internal/cmmock"
+
+    // Go external libraries
+    "github.com/go-zookeeper/zk"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Image of the ZooKeeper servers, unless ZK_INTEGRATION_IMAGE is set
+const ZK_INTEGRATION_IMAGE = "zookeeper:3.8"
+
+// Servers of the ensemble. Each one publishes its client port on its own
+// loopback address, so the exporter reaches all of them on the same port,
+// as it does in a cluster
+const ZK_INTEGRATION_SERVERS = 3
+
+// Time for the ensemble to elect a leader, and for a walk to finish
+const ZK_INTEGRATION_TIMEOUT = 2 * time.Minute
+
+// Series of the time of the last walk of the service
+const ZK_INTEGRATION_WALK_TIMESTAMP = `kbdi_zookeeper_znode_walk_timestamp_seconds{cluster="c1",service="zookeeper"}`
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// ZooKeeper ensemble run in Docker containers on its own network
+type zkEnsemble struct {
+    network    string
+    containers []string
+    hosts      []string
+    port       uint
+}
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// docker runs the docker command and returns its output
+func docker(t *testing.T, args ...string) string {
+    out, err := exec.Command("docker", args...).CombinedOutput()
+    if err != nil {
+        t.Fatalf("docker %s: %s: %s", strings.Join(args, " "), err, out)
+    }
+    return strings.TrimSpace(string(out))
+}
+
+// freePort returns a TCP port free on the loopback interface
+func freePort(t *testing.T) uint {
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+    return uint(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// startZKEnsemble starts the ensemble and waits until it has a leader.
+// Stop it with stop
+func startZKEnsemble(t *testing.T) *zkEnsemble {
+    if _, err := exec.LookPath("docker"); err != nil {
+        t.Skip("docker is required by the integration tests")
+    }
+    image := os.Getenv("ZK_INTEGRATION_IMAGE")
+    if image == "" {
+        image = ZK_INTEGRATION_IMAGE
+    }
+
+    name := fmt.Sprintf("kbdi-zk-it-%d", os.Getpid())
+    ensemble := &zkEnsemble{network: name, port: freePort(t)}
+    docker(t, "network", "create", ensemble.network)
+
+    servers := []string{}
+    for id := 1; id <= ZK_INTEGRATION_SERVERS; id++ {
+        servers = append(servers, fmt.Sprintf("server.%d=%s-%d:2888:3888;2181", id, name, id))
+    }
+    for id := 1; id <= ZK_INTEGRATION_SERVERS; id++ {
+        host := fmt.Sprintf("127.0.0.%d", id)
+        container := fmt.Sprintf("%s-%d", name, id)
+        ensemble.hosts = append(ensemble.hosts, host)
+        ensemble.containers = append(ensemble.containers, container)
+        docker(t, "run", "--detach", "--rm",
+            "--name", container,
+            "--hostname", container,
+            "--network", ensemble.network,
+            "--publish", fmt.Sprintf("%s:%d:2181", host, ensemble.port),
+            "--env", fmt.Sprintf("ZOO_MY_ID=%d", id),
+            "--env", "ZOO_SERVERS="+strings.Join(servers, " "),
+            "--env", "ZOO_4LW_COMMANDS_WHITELIST=srvr,ruok",
+            image,
+        )
+    }
+
+    // Every server must be a follower or the leader. The srvr output is read
+    // as the direct module reads it: zk.FLWSrvr can't parse the build date of
+    // the recent releases ("built on 2024-02-12 22:16 UTC")
+    deadline := time.Now().Add(ZK_INTEGRATION_TIMEOUT)
+    for {
+        answered, leaders := 0, 0
+        for _, server := range ensemble.servers() {
+            fields, err := readZKSrvr(context.Background(), server)
+            if err != nil {
+                continue
+            }
+            answered++
+            if fields["Mode"] == "leader" {
+                leaders++
+            }
+        }
+        if answered == len(ensemble.hosts) && leaders == 1 {
+            return ensemble
+        }
+        if time.Now().After(deadline) {
+            ensemble.stop(t)
+            t.Fatalf("The ZooKeeper ensemble has no leader after %s", ZK_INTEGRATION_TIMEOUT)
+        }
+        time.Sleep(time.Second)
+    }
+}
+
+// servers returns the host:port addresses of the servers
+func (ensemble *zkEnsemble) servers() []string {
+    servers := []string{}
+    for _, host := range ensemble.hosts {
+        servers = append(servers, fmt.Sprintf("%s:%d", host, ensemble.port))
+    }
+    return servers
+}
+
+// stop removes the containers and the network
+func (ensemble *zkEnsemble) stop(t *testing.T) {
+    for _, container := range ensemble.containers {
+        exec.Command("docker", "rm", "--force", container).Run()
+    }
+    exec.Command("docker", "network", "rm", ensemble.network).Run()
+}
+
+// connect opens a session with the ensemble to write the znodes of a test
+func (ensemble *zkEnsemble) connect(t *testing.T) *zk.Conn {
+    conn, err := connectZK(ensemble.servers())
+    if err != nil {
+        t.Fatal(err)
+    }
+    return conn
+}
+
+// createZnodes creates the znodes with their data, the parents first
+func createZnodes(t *testing.T, conn *zk.Conn, acl []zk.ACL, znodes map[string]string) {
+    paths := []string{}
+    for path := range znodes {
+        paths = append(paths, path)
+    }
+    // The parents are shorter than their children
+    for length := 1; len(paths) > 0; length++ {
+        pending := []string{}
+        for _, path := range paths {
+            if len(path) != length {
+                pending = append(pending, path)
+                continue
+            }
+            if _, err := conn.Create(path, []byte(znodes[path]), 0, acl); err != nil {
+                t.Fatalf("Cannot create %s: %s", path, err)
+            }
+        }
+        paths = pending
+    }
+}
+
+// newZKIntegrationServer starts a fake Cloudera Manager with a ZooKeeper
+// service whose SERVER roles run on the hosts of the ensemble
+func newZKIntegrationServer(ensemble *zkEnsemble) *cmmock.Server {
+    s := cmmock.New_server("v19")
+    s.Set_basic_auth("admin", "admin")
+    roles := []cmmock.Role{}
+    for i, host := range ensemble.hosts {
+        hostID := fmt.Sprintf("host-%d", i+1)
+        s.Add_host(cmmock.Host{Host_id: hostID, Hostname: host, Ip_address: host})
+        roles = append(roles, cmmock.Role{
+            Name:    fmt.Sprintf("zookeeper-SERVER-%d", i+1),
+            Type:    ZK_SERVER_ROLE_TYPE,
+            Host_id: hostID,
+            State:   "STARTED",
+        })
+    }
+    s.Add_cluster(cmmock.Cluster{Name: "c1", Services: []cmmock.Service{{
+        Name:  "zookeeper",
+        Type:  ZK_SERVICE_TYPE,
+        State: "STARTED",
+        Roles: roles,
+    }}})
+    return s
+}
+
+// waitZKWalk scrapes until a walk finished after the time exports the
+// expected values, as the walks run in the background
+func waitZKWalk(t *testing.T, config Collector_connection_data, after time.Time, want map[string]float64) {
+    deadline := time.Now().Add(ZK_INTEGRATION_TIMEOUT)
+    for {
+        series := gatherZKSeries(t, config, ScrapeZookeeperZnodes{})
+        missing := []string{}
+        if walked := series[ZK_INTEGRATION_WALK_TIMESTAMP]; walked < float64(after.UnixNano())/1e9 {
+            missing = append(missing, fmt.Sprintf("walk after %s", after))
+        }
+        for name, value := range want {
+            if got, ok := series[name]; !ok || got != value {
+                missing = append(missing, fmt.Sprintf("%s = %v (want %v)", name, got, value))
+            }
+        }
+        if len(missing) == 0 {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("Series not exported after %s: %s", ZK_INTEGRATION_TIMEOUT, strings.Join(missing, ", "))
+        }
+        time.Sleep(500 * time.Millisecond)
+    }
+}
+
+// znodeSeries returns the name of a series of the znodes of the prefix
+func znodeSeries(name string, prefix string) string {
+    return fmt.Sprintf(`kbdi_zookeeper_%s{cluster="c1",prefix=%q,service="zookeeper"}`, name, prefix)
+}
+
+func TestZookeeperIntegration(t *testing.T) {
+    ensemble := startZKEnsemble(t)
+    defer ensemble.stop(t)
+    resetZKTestState()
+    defer resetZKTestState()
+
+    s := newZKIntegrationServer(ensemble)
+    defer s.Close()
+    config := newZKTestConfig(t, s)
+    config.Znode_client_port = ensemble.port
+    config.Znode_prefixes = []string{"/hbase", "/kafka", "/solr", "/secured"}
+    config.Znode_walk_interval = 0
+
+    conn := ensemble.connect(t)
+    defer conn.Close()
+    createZnodes(t, conn, zk.WorldACL(zk.PermAll), map[string]string{
+        "/hbase":               "",
+        "/hbase/rs":            "",
+        "/hbase/rs/node1":      "0123456789",
+        "/hbase/rs/node2":      "0123456789",
+        "/hbase/master":        "node1",
+        "/kafka":               "",
+        "/kafka/brokers":       "",
+        "/kafka/brokers/ids":   "",
+        "/kafka/brokers/ids/1": `{"host":"node1"}`,
+        "/secured":             "",
+    })
+    // Readable by its owner only: the exporter skips it with its children
+    createZnodes(t, conn, zk.DigestACL(zk.PermAll, "owner", "secret"), map[string]string{
+        "/secured/tokens": "abc",
+    })
+    seeded := time.Now()
+
+    t.Run("walk of the znode tree", func(t *testing.T) {
+        waitZKWalk(t, config, seeded, map[string]float64{
+            znodeSeries("znode_count", "/hbase"):      5,
+            znodeSeries("znode_data_bytes", "/hbase"): 25,
+            znodeSeries("znode_count", "/kafka"):      4,
+            znodeSeries("znode_data_bytes", "/kafka"): 16,
+            // A prefix that doesn't exist has no znodes
+            znodeSeries("znode_count", "/solr"):      0,
+            znodeSeries("znode_data_bytes", "/solr"): 0,
+            // The znode the exporter can't read is not counted
+            znodeSeries("znode_count", "/secured"): 1,
+        })
+    })
+
+    // The stats of every server, read with the direct_zookeeper flag
+    t.Run("direct stats", func(t *testing.T) {
+        if err := Set_feature_flags(map[string]bool{FEATURE_DIRECT_ZOOKEEPER: true}); err != nil {
+            t.Fatal(err)
+        }
+        defer Set_feature_flags(nil)
+        series := gatherZKSeries(t, config, ScrapeZookeeperMetrics{})
+        leaders := 0.0
+        for _, server := range ensemble.servers() {
+            labels := fmt.Sprintf(`{cluster="c1",server=%q,service="zookeeper"}`, server)
+            if got := series["kbdi_zookeeper_direct_up"+labels]; got != 1 {
+                t.Errorf("kbdi_zookeeper_direct_up%s = %v, want 1", labels, got)
+            }
+            if got := series["kbdi_zookeeper_direct_znode_count"+labels]; got < 10 {
+                t.Errorf("kbdi_zookeeper_direct_znode_count%s = %v, want the znodes created", labels, got)
+            }
+            leaders += series["kbdi_zookeeper_direct_leader"+labels]
+        }
+        if leaders != 1 {
+            t.Errorf("%v leaders, want 1", leaders)
+        }
+    })
+
+    // The walks connect to the servers still running
+    t.Run("server down", func(t *testing.T) {
+        createZnodes(t, conn, zk.WorldACL(zk.PermAll), map[string]string{
+            "/hbase/rs/node3": "0123456789",
+        })
+        docker(t, "stop", ensemble.containers[0])
+        waitZKWalk(t, config, time.Now(), map[string]float64{
+            znodeSeries("znode_count", "/hbase"):      6,
+            znodeSeries("znode_data_bytes", "/hbase"): 35,
+        })
+    })
+
+    // Cloudera Manager can't list the servers: the last walk is exported
+    t.Run("servers not discovered", func(t *testing.T) {
+        s.Inject_fault("clusters/c1/services/zookeeper/roles", cmmock.Fault{Status: 500, Times: 1})
+        series := gatherZKSeries(t, config, ScrapeZookeeperZnodes{})
+        if got := series[znodeSeries("znode_count", "/hbase")]; got != 6 {
+            t.Errorf("znode_count of /hbase = %v, want the one of the last walk (6)", got)
+        }
+    })
+
+    // No server is reachable: the walks fail and the last one is exported
+    t.Run("ensemble down", func(t *testing.T) {
+        for _, container := range ensemble.containers[1:] {
+            docker(t, "stop", container)
+        }
+        // The walk running when the servers stopped, if any, finishes
+        time.Sleep(2 * ZK_SESSION_TIMEOUT)
+        walked := gatherZKSeries(t, config, ScrapeZookeeperZnodes{})[ZK_INTEGRATION_WALK_TIMESTAMP]
+        // The walk started by the previous scrape fails
+        time.Sleep(2 * ZK_SESSION_TIMEOUT)
+        series := gatherZKSeries(t, config, ScrapeZookeeperZnodes{})
+        if got := series[znodeSeries("znode_count", "/hbase")]; got != 6 {
+            t.Errorf("znode_count of /hbase = %v, want the one of the last walk (6)", got)
+        }
+        if got := series[ZK_INTEGRATION_WALK_TIMESTAMP]; got != walked {
+            t.Errorf("The last walk moved from %v to %v without any server", walked, got)
+        }
+    })
+}
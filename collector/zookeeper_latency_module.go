@@ -0,0 +1,272 @@
+/*
+ *
+ * title           :collector/zookeeper_latency_module.go
+ * description     :Submodule Collector for the request latency of the
+ *                  ZooKeeper servers, as min/avg/max series or a summary,
+ *                  and a histogram of the window datapoints
+ * author          :Enes Erdoğan
+ * date            :2025/06/16
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_LATENCY_SCRAPER_NAME = "zookeeper_latency"
+
+// Modes of the latency metrics: one series per statistic or a summary with
+// the min and max as the 0 and 1 quantiles
+const (
+    LATENCY_MODE_SERIES = "series"
+    LATENCY_MODE_SUMMARY = "summary"
+)
+
+// Request latency statistics of each ZooKeeper server (ms)
+const (
+    ZK_MIN_LATENCY =
+    "SELECT LAST(min_latency) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    ZK_AVG_LATENCY =
+    "SELECT LAST(avg_latency) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    ZK_MAX_LATENCY =
+    "SELECT LAST(max_latency) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    // Every datapoint of the window, for the histogram
+    ZK_AVG_LATENCY_WINDOW =
+    "SELECT avg_latency WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+)
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Latency statistic reported by Cloudera Manager, with its series and, if it
+// has one, its quantile in the summary mode
+type zkLatencyStat struct {
+    query    string
+    desc     *prometheus.Desc
+    quantile string
+}
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+var (
+    zkRequestLatencyMin = createZKMetricStruct("request_latency_min_ms",
+        "Minimum request latency of the ZooKeeper server (ms)",
+    )
+    zkRequestLatencyAvg = createZKMetricStruct("request_latency_avg_ms",
+        "Average request latency of the ZooKeeper server (ms)",
+    )
+    zkRequestLatencyMax = createZKMetricStruct("request_latency_max_ms",
+        "Maximum request latency of the ZooKeeper server (ms)",
+    )
+
+    // Summary mode: the min and max latencies as quantiles. Cloudera Manager
+    // does not report other percentiles of the ZooKeeper servers
+    zkRequestLatencyDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "request_latency_ms"),
+        "Request latency quantiles of the ZooKeeper server (ms). Quantile 0 is the minimum and 1 the maximum",
+        []string{"cluster", "entityName", "quantile"},
+        nil,
+    )
+
+    // Histogram of the average latency datapoints of the window
+    zkRequestLatencyWindowDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "request_latency_window_ms"),
+        "Histogram of the average request latency datapoints of the ZooKeeper server in the timeseries window (ms)",
+        []string{"cluster", "entityName"},
+        nil,
+    )
+)
+
+var zkLatencyStats = []zkLatencyStat{
+    {ZK_MIN_LATENCY, zkRequestLatencyMin, "0"},
+    {ZK_AVG_LATENCY, zkRequestLatencyAvg, ""},
+    {ZK_MAX_LATENCY, zkRequestLatencyMax, "1"},
+}
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// scrapeZKLatencyStat emits the statistic of each server. In the summary
+// mode, the statistics with a quantile are emitted as that quantile
+func scrapeZKLatencyStat(
+    ctx context.Context,
+    config Collector_connection_data,
+    stat zkLatencyStat,
+    ch chan<- prometheus.Metric,
+) bool {
+    jsonParsed, err := make_and_parse_timeseries_query(ctx, config, stat.query)
+    if err != nil {
+        return false
+    }
+    numTsSeries, err := jp.Get_timeseries_num(jsonParsed)
+    if err != nil {
+        return false
+    }
+
+    asQuantile := config.Latency_mode == LATENCY_MODE_SUMMARY && stat.quantile != ""
+    for tsIndex := 0; tsIndex < numTsSeries; tsIndex++ {
+        clusterName := jp.Get_timeseries_query_cluster(jsonParsed, tsIndex)
+        entityName := jp.Get_timeseries_query_entity_name(jsonParsed, tsIndex)
+        value, timestamp, err := get_timeseries_sample(config, jsonParsed, tsIndex)
+        emit_present_metric(ch, config, stat.desc, err == nil, clusterName, entityName)
+        if err != nil {
+            continue
+        }
+
+        metric := new_timeseries_metric(config, stat.desc, value, timestamp, clusterName, entityName)
+        if asQuantile {
+            metric = new_timeseries_metric(config, zkRequestLatencyDesc, value, timestamp, clusterName, entityName, stat.quantile)
+        }
+        ch <- with_entity_labels(config, jsonParsed, tsIndex, metric)
+    }
+    return true
+}
+
+// newZKLatencyHistogram returns the cumulative count of the values of each
+// bucket, their sum and their count
+func newZKLatencyHistogram(bounds []float64, values []float64) (map[float64]uint64, float64, uint64) {
+    buckets := make(map[float64]uint64, len(bounds))
+    sum := 0.0
+    for _, bound := range bounds {
+        buckets[bound] = 0
+    }
+    for _, value := range values {
+        sum += value
+        for _, bound := range bounds {
+            if value <= bound {
+                buckets[bound]++
+            }
+        }
+    }
+    return buckets, sum, uint64(len(values))
+}
+
+// scrapeZKLatencyHistogram emits a histogram of the average latency
+// datapoints of the window of each server. The buckets are the datapoints of
+// the current window, not accumulated between scrapes
+func scrapeZKLatencyHistogram(
+    ctx context.Context,
+    config Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) bool {
+    jsonParsed, err := make_and_parse_timeseries_query(ctx, config, ZK_AVG_LATENCY_WINDOW)
+    if err != nil {
+        return false
+    }
+    numTsSeries, err := jp.Get_timeseries_num(jsonParsed)
+    if err != nil {
+        return false
+    }
+
+    statistic := config.Rollup_statistic
+    if statistic == "" {
+        statistic = jp.ROLLUP_STATISTIC_VALUE
+    }
+    // The bounds are configured in ms. In base units, the bounds and the
+    // values are converted to seconds
+    desc, bounds, factor := zkRequestLatencyWindowDesc, config.Latency_buckets, 1.0
+    if rule, ok := get_unit_rule(config, get_desc_fq_name(desc)); ok {
+        desc = new_desc(rule.Name, convert_help(get_desc_help(desc), rule), []string{"cluster", "entityName"}, nil)
+        factor = rule.Factor
+        bounds = make([]float64, len(config.Latency_buckets))
+        for i, bound := range config.Latency_buckets {
+            bounds[i] = bound * factor
+        }
+    }
+    for tsIndex := 0; tsIndex < numTsSeries; tsIndex++ {
+        values := []float64{}
+        numDatapoints := jp.Get_timeseries_query_datapoints_num(jsonParsed, tsIndex)
+        for datapointIndex := 0; datapointIndex < numDatapoints; datapointIndex++ {
+            if _, value, err := jp.Get_timeseries_query_datapoint(jsonParsed, tsIndex, datapointIndex, statistic); err == nil {
+                values = append(values, value*factor)
+            }
+        }
+        if len(values) == 0 {
+            continue
+        }
+        buckets, sum, count := newZKLatencyHistogram(bounds, values)
+        ch <- prometheus.MustNewConstHistogram(desc, count, sum, buckets,
+            jp.Get_timeseries_query_cluster(jsonParsed, tsIndex),
+            jp.Get_timeseries_query_entity_name(jsonParsed, tsIndex),
+        )
+    }
+    return true
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperLatency struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperLatency) Name() string {
+    return ZK_LATENCY_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperLatency) Help() string {
+    return "Collects the request latency of the ZooKeeper servers from Cloudera Manager"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperLatency) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperLatency) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape runs the query of each latency statistic and emits its series
+func (ScrapeZookeeperLatency) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Latency Scraper")
+
+    successQueries := 0
+    errorQueries := 0
+    for _, stat := range zkLatencyStats {
+        eval_scrape(scrapeZKLatencyStat(ctx, *config, stat, ch), &successQueries, &errorQueries)
+    }
+    if len(config.Latency_buckets) > 0 {
+        eval_scrape(scrapeZKLatencyHistogram(ctx, *config, ch), &successQueries, &errorQueries)
+    }
+
+    log.Debug_msg(
+        "ZK Latency Scraper: %d queries run, %d successful, %d errors",
+        successQueries+errorQueries,
+        successQueries,
+        errorQueries,
+    )
+    return nil
+}
+
+// Ensure ScrapeZookeeperLatency implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperLatency{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperLatency{})
+}
@@ -0,0 +1,154 @@
+/*
+ *
+ * title           :collector/zookeeper_maintenance_module.go
+ * description     :Submodule Collector for the maintenance mode of the
+ *                  ZooKeeper services, their clusters and roles
+ * author          :Enes Erdoğan
+ * date            :2025/09/22
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "fmt"
+    "net/url"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_MAINTENANCE_SCRAPER_NAME = "zookeeper_maintenance"
+
+// Scopes of the maintenance mode
+const (
+    MAINTENANCE_SCOPE_CLUSTER = "cluster"
+    MAINTENANCE_SCOPE_SERVICE = "service"
+    MAINTENANCE_SCOPE_ROLE    = "role"
+)
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+var (
+    // One series for the cluster of the service, one for the service and one
+    // per role. The role is empty in the cluster and service scopes
+    zkMaintenanceModeDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "maintenance_mode"),
+        "Whether the cluster, the ZooKeeper service or its role is in maintenance mode (1) or not (0)",
+        []string{"scope", "cluster", "service", "role"},
+        nil,
+    )
+)
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// scrapeZKMaintenanceMode emits the maintenance mode of the cluster of the
+// service, of the service and of each of its roles
+func scrapeZKMaintenanceMode(
+    ctx context.Context,
+    config Collector_connection_data,
+    service clouderaService,
+    ch chan<- prometheus.Metric,
+) bool {
+    jsonCluster, err := make_and_parse_api_query(ctx, config, fmt.Sprintf("clusters/%s", url.PathEscape(service.Cluster)))
+    if err != nil {
+        return false
+    }
+    ch <- prometheus.MustNewConstMetric(zkMaintenanceModeDesc, prometheus.GaugeValue,
+        boolToValue(jp.Get_api_query_cluster_maintenance_mode(jsonCluster) == "true"),
+        MAINTENANCE_SCOPE_CLUSTER, service.Cluster, service.Name, "")
+
+    jsonService, err := make_and_parse_api_query(ctx, config, service.apiPath(""))
+    if err != nil {
+        return false
+    }
+    ch <- prometheus.MustNewConstMetric(zkMaintenanceModeDesc, prometheus.GaugeValue,
+        boolToValue(jp.Get_api_query_service_maintenance_mode(jsonService) == "true"),
+        MAINTENANCE_SCOPE_SERVICE, service.Cluster, service.Name, "")
+
+    jsonRoles, err := make_and_parse_api_query(ctx, config, service.apiPath("roles"))
+    if err != nil {
+        return false
+    }
+    numRoles := jp.Get_api_query_items_num(jsonRoles)
+    for roleIndex := 0; roleIndex < numRoles; roleIndex++ {
+        ch <- prometheus.MustNewConstMetric(zkMaintenanceModeDesc, prometheus.GaugeValue,
+            boolToValue(jp.Get_api_query_role_maintenance_mode(jsonRoles, roleIndex) == "true"),
+            MAINTENANCE_SCOPE_ROLE, service.Cluster, service.Name, jp.Get_api_query_role_name(jsonRoles, roleIndex))
+    }
+    return true
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperMaintenance struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperMaintenance) Name() string {
+    return ZK_MAINTENANCE_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperMaintenance) Help() string {
+    return "Collects the maintenance mode of the ZooKeeper services, their clusters and roles from Cloudera Manager"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperMaintenance) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperMaintenance) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape discovers the ZooKeeper services and emits their maintenance mode
+func (ScrapeZookeeperMaintenance) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Maintenance Scraper")
+
+    services, err := discoverServices(ctx, *config, ZK_SERVICE_TYPE)
+    if err != nil {
+        return err
+    }
+
+    successQueries := 0
+    errorQueries := 0
+    for _, service := range services {
+        eval_scrape(scrapeZKMaintenanceMode(ctx, *config, service, ch), &successQueries, &errorQueries)
+    }
+
+    log.Debug_msg(
+        "ZK Maintenance Scraper: %d queries run, %d successful, %d errors",
+        successQueries+errorQueries,
+        successQueries,
+        errorQueries,
+    )
+    return nil
+}
+
+// Ensure ScrapeZookeeperMaintenance implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperMaintenance{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperMaintenance{})
+}
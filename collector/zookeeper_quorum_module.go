@@ -0,0 +1,214 @@
+/*
+ *
+ * title           :collector/zookeeper_quorum_module.go
+ * description     :Submodule Collector for the quorum activity of the
+ *                  ZooKeeper servers: packets, proposals and commits, and
+ *                  the followers synced with the leader or not
+ * author          :Enes Erdoğan
+ * date            :2025/11/17
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "math"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_QUORUM_SCRAPER_NAME = "zookeeper_quorum"
+
+// Quorum metrics of each ZooKeeper server. The proposals, the synced
+// followers and the pending syncs are only reported by the leader
+const (
+    // Packets received from the clients and the other servers (packets per second)
+    ZK_PACKETS_RECEIVED_RATE =
+    "SELECT LAST(packets_received_rate) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    // Packets sent to the clients and the other servers (packets per second)
+    ZK_PACKETS_SENT_RATE =
+    "SELECT LAST(packets_sent_rate) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    // Proposals sent by the leader to the followers (proposals per second)
+    ZK_PROPOSAL_COUNT_RATE =
+    "SELECT LAST(proposal_count_rate) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    // Transactions committed by the server (commits per second)
+    ZK_COMMIT_COUNT_RATE =
+    "SELECT LAST(commit_count_rate) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    // Followers in sync with the leader
+    ZK_SYNCED_FOLLOWERS =
+    "SELECT LAST(synced_followers) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+
+    // Followers still being synced by the leader
+    ZK_PENDING_SYNCS =
+    "SELECT LAST(pending_syncs) WHERE roleType=\"SERVER\" AND serviceType=\"ZOOKEEPER\""
+)
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+var (
+    zkPacketsReceivedRate = createZKMetricStruct("packets_received_rate",
+        "Packets received by the ZooKeeper server (packets per second)",
+    )
+    zkPacketsSentRate = createZKMetricStruct("packets_sent_rate",
+        "Packets sent by the ZooKeeper server (packets per second)",
+    )
+    zkProposalCountRate = createZKMetricStruct("proposal_count_rate",
+        "Proposals sent to the followers by the ZooKeeper leader (proposals per second)",
+    )
+    zkCommitCountRate = createZKMetricStruct("commit_count_rate",
+        "Transactions committed by the ZooKeeper server (commits per second)",
+    )
+    zkSyncedFollowers = createZKMetricStruct("synced_followers",
+        "Followers in sync with the ZooKeeper leader",
+    )
+    zkPendingSyncs = createZKMetricStruct("pending_syncs",
+        "Followers still being synced by the ZooKeeper leader",
+    )
+    zkFollowersNotSyncedDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "followers_not_synced"),
+        "Followers of the ZooKeeper service not in sync with the leader: the SERVER roles besides the leader minus the synced followers it reports",
+        []string{"cluster", "service"},
+        nil,
+    )
+)
+
+var zkQuorumQueryVariableRelationship = []relation{
+    {ZK_PACKETS_RECEIVED_RATE,  zkPacketsReceivedRate},
+    {ZK_PACKETS_SENT_RATE,      zkPacketsSentRate},
+    {ZK_PROPOSAL_COUNT_RATE,    zkProposalCountRate},
+    {ZK_COMMIT_COUNT_RATE,      zkCommitCountRate},
+    {ZK_SYNCED_FOLLOWERS,       zkSyncedFollowers},
+    {ZK_PENDING_SYNCS,          zkPendingSyncs},
+}
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// countZKServers returns the number of SERVER roles of the service, started
+// or not, as they are all expected in the quorum
+func countZKServers(ctx context.Context, config Collector_connection_data, service clouderaService) (int, error) {
+    jsonParsed, err := make_and_parse_api_query(ctx, config, service.apiPath("roles"))
+    if err != nil {
+        return 0, err
+    }
+    servers := 0
+    numRoles := jp.Get_api_query_items_num(jsonParsed)
+    for roleIndex := 0; roleIndex < numRoles; roleIndex++ {
+        if jp.Get_api_query_role_type(jsonParsed, roleIndex) == ZK_SERVER_ROLE_TYPE {
+            servers++
+        }
+    }
+    return servers, nil
+}
+
+// emitZKFollowersNotSynced emits the followers each leader is missing, from
+// the synced followers of the servers. Only the leader reports synced
+// followers (the others report 0 or nothing), so the highest value of the
+// servers of the service is used. The services without any report are
+// skipped, as their value is unknown
+func emitZKFollowersNotSynced(
+    ctx context.Context,
+    config Collector_connection_data,
+    syncedFollowers []zkSample,
+    ch chan<- prometheus.Metric,
+) bool {
+    synced := make(map[clouderaService]float64)
+    for _, sample := range syncedFollowers {
+        if previous, ok := synced[sample.service]; !ok || sample.value > previous {
+            synced[sample.service] = sample.value
+        }
+    }
+
+    success := true
+    for service, followers := range synced {
+        servers, err := countZKServers(ctx, config, service)
+        if err != nil {
+            log.Err_msg("Cannot count the servers of the ZooKeeper service %s/%s: %s", service.Cluster, service.Name, err)
+            success = false
+            continue
+        }
+        // The observers are not followers, but a report of more synced
+        // followers than servers is not a negative lag
+        ch <- prometheus.MustNewConstMetric(zkFollowersNotSyncedDesc, prometheus.GaugeValue, math.Max(float64(servers-1)-followers, 0), service.Cluster, service.Name)
+    }
+    return success
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperQuorum struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperQuorum) Name() string {
+    return ZK_QUORUM_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperQuorum) Help() string {
+    return "Collects the packets, proposals, commits and follower syncs of the ZooKeeper servers from Cloudera Manager"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperQuorum) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperQuorum) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape runs the queries defined in zkQuorumQueryVariableRelationship and
+// emits the series of each server, and the followers not synced of each
+// service from the synced followers
+func (ScrapeZookeeperQuorum) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Quorum Scraper")
+
+    successQueries := 0
+    errorQueries := 0
+    for _, rel := range zkQuorumQueryVariableRelationship {
+        samples, ok := collectZKMetric(ctx, *config, rel.Query, rel.Metric_struct, ch)
+        eval_scrape(ok, &successQueries, &errorQueries)
+        if ok && rel.Query == ZK_SYNCED_FOLLOWERS {
+            eval_scrape(emitZKFollowersNotSynced(ctx, *config, samples, ch), &successQueries, &errorQueries)
+        }
+    }
+
+    log.Debug_msg(
+        "ZK Quorum Scraper: %d queries run, %d successful, %d errors",
+        successQueries+errorQueries,
+        successQueries,
+        errorQueries,
+    )
+    return nil
+}
+
+// Ensure ScrapeZookeeperQuorum implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperQuorum{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperQuorum{})
+}
@@ -0,0 +1,187 @@
+/*
+ *
+ * title           :collector/zookeeper_roles_module.go
+ * description     :Submodule Collector for the ZooKeeper roles state
+ * author          :Enes Erdoğan
+ * date            :2025/01/27
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_ROLES_SCRAPER_NAME = "zookeeper_roles"
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+var (
+    zkRoleStateDesc = createZKRoleMetricStruct("role_state",
+        "Whether the ZooKeeper role is started (1) or not (0)",
+        "state",
+    )
+    zkRoleConfigStalenessDesc = createZKRoleMetricStruct("role_config_staleness",
+        "Configuration staleness of the ZooKeeper role (0 FRESH, 1 STALE_REFRESHABLE, 2 STALE: restart required)",
+        "status",
+    )
+    zkRoleMaintenanceModeDesc = createZKRoleMetricStruct("role_maintenance_mode",
+        "Whether the ZooKeeper role is in maintenance mode (1) or not (0)",
+    )
+    zkRoleCommissionStateDesc = createZKRoleMetricStruct("role_commission_state",
+        "Whether the ZooKeeper role is commissioned (1) or not (0)",
+        "state",
+    )
+)
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// createZKRoleMetricStruct returns a descriptor with the role labels plus
+// the extra ones
+func createZKRoleMetricStruct(metricName string, description string, extraLabels ...string) *prometheus.Desc {
+    return new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, metricName),
+        description,
+        append([]string{"cluster", "service", "role", "host"}, extraLabels...),
+        nil,
+    )
+}
+
+// Numeric value of the role configStalenessStatus
+func getValueFromStaleness(status string) float64 {
+    switch status {
+    case "FRESH":
+        return 0.0
+    case "STALE_REFRESHABLE":
+        return 1.0
+    case "STALE":
+        return 2.0
+    default:
+        return -1.0
+    }
+}
+
+// Returns 1 if the condition holds, else 0
+func boolToValue(condition bool) float64 {
+    if condition {
+        return 1.0
+    }
+    return 0.0
+}
+
+// scrapeZKRoles emits the state metrics of each role of the service
+func scrapeZKRoles(
+    ctx context.Context,
+    config Collector_connection_data,
+    service clouderaService,
+    hostNames map[string]string,
+    ch chan<- prometheus.Metric,
+) bool {
+    jsonParsed, err := make_and_parse_api_query(ctx, config, service.apiPath("roles"))
+    if err != nil {
+        return false
+    }
+
+    numRoles := jp.Get_api_query_items_num(jsonParsed)
+    for roleIndex := 0; roleIndex < numRoles; roleIndex++ {
+        roleName := jp.Get_api_query_role_name(jsonParsed, roleIndex)
+        hostID := jp.Get_api_query_host_id_by_hostRef(jsonParsed, roleIndex)
+        hostName := Get_hostName_with_hostId(hostNames, hostID)
+        if hostName == "" {
+            hostName = hostID
+        }
+        roleState := jp.Get_api_query_role_state(jsonParsed, roleIndex)
+        staleness := jp.Get_api_query_role_config_staleness(jsonParsed, roleIndex)
+        maintenanceMode := jp.Get_api_query_role_maintenance_mode(jsonParsed, roleIndex)
+        commissionState := jp.Get_api_query_role_commission_state(jsonParsed, roleIndex)
+
+        labels := []string{service.Cluster, service.Name, roleName, hostName}
+        ch <- prometheus.MustNewConstMetric(zkRoleStateDesc, prometheus.GaugeValue,
+            boolToValue(roleState == "STARTED"), append(labels, roleState)...)
+        ch <- prometheus.MustNewConstMetric(zkRoleConfigStalenessDesc, prometheus.GaugeValue,
+            getValueFromStaleness(staleness), append(labels, staleness)...)
+        ch <- prometheus.MustNewConstMetric(zkRoleMaintenanceModeDesc, prometheus.GaugeValue,
+            boolToValue(maintenanceMode == "true"), labels...)
+        ch <- prometheus.MustNewConstMetric(zkRoleCommissionStateDesc, prometheus.GaugeValue,
+            boolToValue(commissionState == "COMMISSIONED"), append(labels, commissionState)...)
+    }
+    return true
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperRoles struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperRoles) Name() string {
+    return ZK_ROLES_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperRoles) Help() string {
+    return "Collects the state of the ZooKeeper roles from Cloudera Manager"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperRoles) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperRoles) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape discovers the ZooKeeper services and emits the state of their roles
+func (ScrapeZookeeperRoles) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Roles Scraper")
+
+    services, err := discoverServices(ctx, *config, ZK_SERVICE_TYPE)
+    if err != nil {
+        return err
+    }
+    hostNames := scrape_hostName(ctx, *config, "hosts")
+
+    successQueries := 0
+    errorQueries := 0
+    for _, service := range services {
+        eval_scrape(scrapeZKRoles(ctx, *config, service, hostNames, ch), &successQueries, &errorQueries)
+    }
+
+    log.Debug_msg(
+        "ZK Roles Scraper: %d queries run, %d successful, %d errors",
+        successQueries+errorQueries,
+        successQueries,
+        errorQueries,
+    )
+    return nil
+}
+
+// Ensure ScrapeZookeeperRoles implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperRoles{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperRoles{})
+}
@@ -0,0 +1,885 @@
+/*
+ *
+ * title           :collector/zookeeper_scrapers_test.go
+ * description     :Tests of the ZooKeeper scrapers against a fake Cloudera
+ *                  Manager, with the series each one exports
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math"
+    "net"
+    "os"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    // Own libraries
+    cm "keedio/cloudera_exporter/cm_client"
+    "keedio/cloudera_exporter/internal/cmmock"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Scrape of a ZooKeeper scraper against the fake Cloudera Manager: the
+// fixtures of the server, the changes to the default configuration, the
+// series expected with their values and the series that must not be exported
+type zkScraperCase struct {
+    name        string
+    scraper     Scraper
+    fixtures    func(s *cmmock.Server)
+    config      func(config *Collector_connection_data)
+    want        map[string]float64
+    absent      []string
+    requests    []string
+    // Requests that must not be made
+    unrequested []string
+}
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+func TestMain(m *testing.M) {
+    log.Init(ioutil.Discard, ioutil.Discard, ioutil.Discard, ioutil.Discard, ioutil.Discard, 0)
+    os.Exit(m.Run())
+}
+
+// newZKTestServer starts a fake Cloudera Manager with a cluster c1 and a
+// ZooKeeper service of three servers, zookeeper-SERVER-1 to 3 on zk1 to zk3
+func newZKTestServer() *cmmock.Server {
+    s := cmmock.New_server("v19")
+    s.Set_basic_auth("admin", "admin")
+    roles := []cmmock.Role{}
+    for i := 1; i <= 3; i++ {
+        hostID := fmt.Sprintf("host-%d", i)
+        s.Add_host(cmmock.Host{Host_id: hostID, Hostname: fmt.Sprintf("zk%d", i)})
+        roles = append(roles, cmmock.Role{
+            Name:             fmt.Sprintf("zookeeper-SERVER-%d", i),
+            Type:             ZK_SERVER_ROLE_TYPE,
+            Host_id:          hostID,
+            State:            "STARTED",
+            Health:           "GOOD",
+            Config_staleness: "FRESH",
+            Commission_state: "COMMISSIONED",
+        })
+    }
+    s.Add_cluster(cmmock.Cluster{
+        Name:         "c1",
+        Display_name: "Cluster 1",
+        Full_version: "6.3.4",
+        Services: []cmmock.Service{{
+            Name:   "zookeeper",
+            Type:   ZK_SERVICE_TYPE,
+            State:  "STARTED",
+            Health: "GOOD",
+            Roles:  roles,
+        }},
+    })
+    return s
+}
+
+// newZKTestConfig returns the configuration of the exporter for the server
+func newZKTestConfig(t *testing.T, s *cmmock.Server) Collector_connection_data {
+    host, port := s.Host_port()
+    credentials, err := cm.New_credentials(cm.Literal_secret("admin"), cm.Literal_secret("admin"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return Collector_connection_data{
+        Host:               host,
+        Port:               port,
+        Api_version:        "v19",
+        Api_version_pinned: true,
+        Credentials:        credentials,
+        Http_client:        cm.New_http_client(cm.Http_client_options{Timeout: 5 * time.Second}),
+        Latency_mode:       LATENCY_MODE_SERIES,
+        No_data:            NO_DATA_OMIT,
+        Timeseries_window:  5 * time.Minute,
+        Events_lookback:    time.Hour,
+        Health_rates:       true,
+    }
+}
+
+// resetZKTestState forgets what the previous scrapes kept between them
+func resetZKTestState() {
+    negotiated_api_version.Lock()
+    negotiated_api_version.version = ""
+    negotiated_api_version.renegotiated_at = time.Time{}
+    negotiated_api_version.Unlock()
+
+    degraded_scopes.Lock()
+    degraded_scopes.scopes = nil
+    degraded_scopes.Unlock()
+
+    zkConfigSnapshots.Lock()
+    zkConfigSnapshots.byService = map[clouderaService]*zkConfigSnapshot{}
+    zkConfigSnapshots.Unlock()
+
+    zkEvents.Lock()
+    zkEvents.since = time.Time{}
+    zkEvents.seenIDs = nil
+    zkEvents.bySource = map[zkEventSource]*zkServiceEvents{}
+    zkEvents.Unlock()
+
+    zkZnodes.Lock()
+    zkZnodes.prefixes = ""
+    zkZnodes.byService = map[clouderaService]*zkZnodeStats{}
+    zkZnodes.Unlock()
+}
+
+// gatherZKSeries scrapes the scraper and returns the value of each series
+// exported, by its name and labels: name{label="value",...}
+func gatherZKSeries(t *testing.T, config Collector_connection_data, scraper Scraper) map[string]float64 {
+    registry := prometheus.NewRegistry()
+    registry.MustRegister(New(context.Background(), config, NewMetrics(), []Scraper{scraper}))
+    families, err := registry.Gather()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    series := make(map[string]float64)
+    for _, family := range families {
+        for _, metric := range family.GetMetric() {
+            labels := []string{}
+            for _, label := range metric.GetLabel() {
+                labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+            }
+            value := metric.GetGauge().GetValue() + metric.GetCounter().GetValue() + metric.GetUntyped().GetValue()
+            series[fmt.Sprintf("%s{%s}", family.GetName(), strings.Join(labels, ","))] = value
+        }
+    }
+    return series
+}
+
+// zkTestSerie returns a TimeSerie of a ZooKeeper entity with a datapoint of
+// a minute ago, or without datapoints if the value is NaN
+func zkTestSerie(entityName string, hostname string, value float64) cmmock.Timeseries {
+    serie := cmmock.Timeseries{Attributes: map[string]string{
+        "clusterName": "c1",
+        "serviceName": "zookeeper",
+        "entityName":  entityName,
+        "hostname":    hostname,
+    }}
+    if !math.IsNaN(value) {
+        serie.Datapoints = []cmmock.Datapoint{{Timestamp: time.Now().Add(-time.Minute), Value: value}}
+    }
+    return serie
+}
+
+// serviceAggregateQuery returns the query of the aggregates by service of a
+// role query
+func serviceAggregateQuery(t *testing.T, query string) string {
+    aggregateQuery, ok := cm.Rescope_tsquery(query, cm.SCOPE_SERVICE)
+    if !ok {
+        t.Fatalf("%s has no aggregates by service", query)
+    }
+    return aggregateQuery
+}
+
+func TestZookeeperScrapers(t *testing.T) {
+    occurred := time.Now().Add(-2 * time.Minute).Truncate(time.Second)
+    cases := []zkScraperCase{
+        {
+            name:    "service metrics",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", 4242))
+                s.Set_timeseries(ZK_CANARY_DURATION, nil, zkTestSerie("zookeeper", "", 250))
+                s.Set_timeseries(ZK_HEALTH_GOOD_RATE, nil, zkTestSerie("zookeeper", "", 0.9))
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_current_xid{cluster="c1",entityName="zookeeper"}`:                    4242,
+                `kbdi_zookeeper_canary_duration_seconds{cluster="c1",entityName="zookeeper"}`:        0.25,
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_current_xid",scope="SERVICE"}`: 0,
+                `kbdi_zookeeper_health_good_rate{cluster="c1",entityName="zookeeper"}`:               0.9,
+            },
+            absent: []string{
+                `kbdi_zookeeper_cardinality_backoff{metric="kbdi_zookeeper_current_xid"}`,
+                `kbdi_zookeeper_alerts_rate{cluster="c1",entityName="zookeeper"}`,
+                `kbdi_zookeeper_canary_duration_ms{cluster="c1",entityName="zookeeper"}`,
+            },
+        },
+        {
+            // The names in milliseconds
+            name:    "legacy metric names",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_CANARY_DURATION, nil, zkTestSerie("zookeeper", "", 250))
+            },
+            config: func(config *Collector_connection_data) {
+                config.Legacy_metric_names = true
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_canary_duration_ms{cluster="c1",entityName="zookeeper"}`: 250,
+            },
+            absent: []string{
+                `kbdi_zookeeper_canary_duration_seconds{cluster="c1",entityName="zookeeper"}`,
+            },
+        },
+        {
+            // The health rates deprecated by the health checks
+            name:    "health rates disabled",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_HEALTH_GOOD_RATE, nil, zkTestSerie("zookeeper", "", 0.9))
+            },
+            config: func(config *Collector_connection_data) {
+                config.Health_rates = false
+            },
+            absent: []string{
+                `kbdi_zookeeper_health_good_rate{cluster="c1",entityName="zookeeper"}`,
+            },
+            unrequested: []string{"/api/v19/timeseries?query=SELECT+LAST(health_good_rate)"},
+        },
+        {
+            name:    "no data omitted",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", math.NaN()))
+            },
+            absent: []string{
+                `kbdi_zookeeper_current_xid{cluster="c1",entityName="zookeeper"}`,
+                `kbdi_zookeeper_current_xid_present{cluster="c1",entityName="zookeeper"}`,
+            },
+        },
+        {
+            name:    "no data as NaN",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", math.NaN()))
+            },
+            config: func(config *Collector_connection_data) {
+                config.No_data = NO_DATA_NAN
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_current_xid{cluster="c1",entityName="zookeeper"}`: math.NaN(),
+            },
+        },
+        {
+            name:    "no data with present gauge",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", math.NaN()))
+                s.Set_timeseries(ZK_CANARY_DURATION, nil, zkTestSerie("zookeeper", "", 250))
+            },
+            config: func(config *Collector_connection_data) {
+                config.No_data = NO_DATA_PRESENT
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_current_xid_present{cluster="c1",entityName="zookeeper"}`:              0,
+                `kbdi_zookeeper_canary_duration_seconds_present{cluster="c1",entityName="zookeeper"}`: 1,
+                `kbdi_zookeeper_canary_duration_seconds{cluster="c1",entityName="zookeeper"}`:         0.25,
+            },
+            absent: []string{
+                `kbdi_zookeeper_current_xid{cluster="c1",entityName="zookeeper"}`,
+            },
+        },
+        {
+            // The first query (packets_received_rate) is forbidden with the
+            // ROLE scope and collected from the aggregates by service
+            name:    "permission fallback",
+            scraper: ScrapeZookeeperQuorum{},
+            fixtures: func(s *cmmock.Server) {
+                s.Inject_fault("timeseries", cmmock.Fault{Status: 403, Times: 1})
+                s.Set_timeseries(serviceAggregateQuery(t, ZK_PACKETS_RECEIVED_RATE), nil, zkTestSerie("zookeeper", "", 120))
+                s.Set_timeseries(ZK_PENDING_SYNCS, nil, zkTestSerie("zookeeper-SERVER-1", "zk1", 1))
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper"}`:                 120,
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_packets_received_rate",scope="SERVICE"}`: 1,
+                `kbdi_zookeeper_pending_syncs{cluster="c1",entityName="zookeeper-SERVER-1"}`:                1,
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_pending_syncs",scope="ROLE"}`:           0,
+            },
+            absent: []string{
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_packets_received_rate",scope="ROLE"}`,
+            },
+        },
+        {
+            // More role series than allowed: the aggregate by service is
+            // collected instead of summing the roles
+            name:    "cardinality backoff",
+            scraper: ScrapeZookeeperQuorum{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_PACKETS_RECEIVED_RATE, nil,
+                    zkTestSerie("zookeeper-SERVER-1", "zk1", 40),
+                    zkTestSerie("zookeeper-SERVER-2", "zk2", 50),
+                    zkTestSerie("zookeeper-SERVER-3", "zk3", 60),
+                )
+                s.Set_timeseries(serviceAggregateQuery(t, ZK_PACKETS_RECEIVED_RATE), nil, zkTestSerie("zookeeper", "", 50))
+                s.Set_timeseries(ZK_PENDING_SYNCS, nil, zkTestSerie("zookeeper-SERVER-1", "zk1", 1))
+            },
+            config: func(config *Collector_connection_data) {
+                config.Max_role_series = 2
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper"}`:          50,
+                `kbdi_zookeeper_cardinality_backoff{metric="kbdi_zookeeper_packets_received_rate"}`: 1,
+                `kbdi_zookeeper_pending_syncs{cluster="c1",entityName="zookeeper-SERVER-1"}`:         1,
+                `kbdi_zookeeper_cardinality_backoff{metric="kbdi_zookeeper_pending_syncs"}`:         0,
+            },
+            absent: []string{
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper-SERVER-1"}`,
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper-SERVER-3"}`,
+            },
+        },
+        {
+            // The service metrics have no aggregate by cluster to fall back to
+            name:    "permission error without broader scope",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                s.Inject_fault("timeseries", cmmock.Fault{Status: 403, Times: 1})
+                s.Set_timeseries(ZK_ALERTS_RATE, nil, zkTestSerie("zookeeper", "", 3))
+                s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", 4242))
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_current_xid{cluster="c1",entityName="zookeeper"}`: 4242,
+            },
+            absent: []string{
+                `kbdi_zookeeper_alerts_rate{cluster="c1",entityName="zookeeper"}`,
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_alerts_rate",scope="SERVICE"}`,
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_alerts_rate",scope="CLUSTER"}`,
+            },
+        },
+        {
+            // Degraded in a previous scrape, not retried yet
+            name:    "degraded scope",
+            scraper: ScrapeZookeeperQuorum{},
+            fixtures: func(s *cmmock.Server) {
+                degraded_scopes.scopes = map[string]degraded_scope{
+                    ZK_PACKETS_RECEIVED_RATE: {cm.SCOPE_SERVICE, time.Now().Add(time.Minute)},
+                }
+                s.Set_timeseries(ZK_PACKETS_RECEIVED_RATE, nil, zkTestSerie("zookeeper-SERVER-1", "zk1", 40))
+                s.Set_timeseries(serviceAggregateQuery(t, ZK_PACKETS_RECEIVED_RATE), nil, zkTestSerie("zookeeper", "", 120))
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper"}`:                 120,
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_packets_received_rate",scope="SERVICE"}`: 1,
+            },
+            absent: []string{
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper-SERVER-1"}`,
+            },
+        },
+        {
+            // Degraded in a previous scrape, and permitted again when retried
+            name:    "degraded scope retried",
+            scraper: ScrapeZookeeperQuorum{},
+            fixtures: func(s *cmmock.Server) {
+                degraded_scopes.scopes = map[string]degraded_scope{
+                    ZK_PACKETS_RECEIVED_RATE: {cm.SCOPE_SERVICE, time.Now().Add(-time.Second)},
+                }
+                s.Set_timeseries(ZK_PACKETS_RECEIVED_RATE, nil, zkTestSerie("zookeeper-SERVER-1", "zk1", 40))
+                s.Set_timeseries(serviceAggregateQuery(t, ZK_PACKETS_RECEIVED_RATE), nil, zkTestSerie("zookeeper", "", 120))
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper-SERVER-1"}`:      40,
+                `kbdi_exporter_degraded_scope{metric="kbdi_zookeeper_packets_received_rate",scope="ROLE"}`: 0,
+            },
+            absent: []string{
+                `kbdi_zookeeper_packets_received_rate{cluster="c1",entityName="zookeeper"}`,
+            },
+        },
+        {
+            // Cloudera Manager was upgraded: the configured v18 is not
+            // supported anymore and the exporter moves to v19
+            name:    "API version renegotiation",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                // Renegotiated as the version is unsupported, even if the
+                // last renegotiation is too recent for other errors
+                negotiated_api_version.renegotiated_at = time.Now().Add(-API_VERSION_RENEGOTIATION_INTERVAL / 2)
+                s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", 4242))
+            },
+            config: func(config *Collector_connection_data) {
+                config.Api_version = "v18"
+                config.Api_version_pinned = false
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_current_xid{cluster="c1",entityName="zookeeper"}`: 4242,
+            },
+            requests: []string{"/api/version", "/api/v19/timeseries"},
+        },
+        {
+            // A 404 of a resource that does not exist, soon after the last
+            // renegotiation
+            name:    "no API version renegotiation",
+            scraper: ScrapeZookeeperMetrics{},
+            fixtures: func(s *cmmock.Server) {
+                negotiated_api_version.renegotiated_at = time.Now()
+                s.Inject_fault("timeseries", cmmock.Fault{Status: 404, Times: 1})
+                s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", 4242))
+            },
+            config: func(config *Collector_connection_data) {
+                config.Api_version_pinned = false
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_current_xid{cluster="c1",entityName="zookeeper"}`: 4242,
+            },
+            unrequested: []string{"/api/version"},
+        },
+        {
+            name:    "health checks",
+            scraper: ScrapeZookeeperHealthChecks{},
+            fixtures: func(s *cmmock.Server) {
+                s.Add_cluster(cmmock.Cluster{Name: "c2", Services: []cmmock.Service{{
+                    Name: "zookeeper2",
+                    Type: ZK_SERVICE_TYPE,
+                    Health_checks: map[string]string{
+                        "ZOOKEEPER_CANARY_HEALTH":   "GOOD",
+                        "ZOOKEEPER_SERVERS_HEALTHY": "BAD",
+                    },
+                }}})
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_health_check{check="ZOOKEEPER_CANARY_HEALTH",cluster="c2",service="zookeeper2",severity="GOOD"}`:   get_value_from_state("GOOD"),
+                `kbdi_zookeeper_health_check{check="ZOOKEEPER_SERVERS_HEALTHY",cluster="c2",service="zookeeper2",severity="BAD"}`: get_value_from_state("BAD"),
+            },
+        },
+        {
+            name:    "request latency",
+            scraper: ScrapeZookeeperLatency{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_AVG_LATENCY, nil,
+                    zkTestSerie("zookeeper-SERVER-1", "zk1", 7),
+                    zkTestSerie("zookeeper-SERVER-2", "zk2", 3),
+                )
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_request_latency_avg_seconds{cluster="c1",entityName="zookeeper-SERVER-1"}`: 0.007,
+                `kbdi_zookeeper_request_latency_avg_seconds{cluster="c1",entityName="zookeeper-SERVER-2"}`: 0.003,
+            },
+            absent: []string{
+                `kbdi_zookeeper_request_latency_avg_ms{cluster="c1",entityName="zookeeper-SERVER-1"}`,
+            },
+        },
+        {
+            name:    "quorum",
+            scraper: ScrapeZookeeperQuorum{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_SYNCED_FOLLOWERS, nil,
+                    zkTestSerie("zookeeper-SERVER-1", "zk1", 2),
+                    zkTestSerie("zookeeper-SERVER-2", "zk2", 0),
+                )
+                s.Set_timeseries(ZK_PENDING_SYNCS, nil, zkTestSerie("zookeeper-SERVER-1", "zk1", 1))
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_synced_followers{cluster="c1",entityName="zookeeper-SERVER-1"}`: 2,
+                `kbdi_zookeeper_synced_followers{cluster="c1",entityName="zookeeper-SERVER-2"}`: 0,
+                `kbdi_zookeeper_pending_syncs{cluster="c1",entityName="zookeeper-SERVER-1"}`:    1,
+                `kbdi_zookeeper_followers_not_synced{cluster="c1",service="zookeeper"}`:           0,
+            },
+        },
+        {
+            // The leader reports one synced follower of the two expected
+            name:    "followers not synced",
+            scraper: ScrapeZookeeperQuorum{},
+            fixtures: func(s *cmmock.Server) {
+                s.Set_timeseries(ZK_SYNCED_FOLLOWERS, nil,
+                    zkTestSerie("zookeeper-SERVER-1", "zk1", 1),
+                    zkTestSerie("zookeeper-SERVER-2", "zk2", 0),
+                )
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_followers_not_synced{cluster="c1",service="zookeeper"}`: 1,
+            },
+        },
+        {
+            name:    "followers not synced without data",
+            scraper: ScrapeZookeeperQuorum{},
+            absent: []string{
+                `kbdi_zookeeper_followers_not_synced{cluster="c1",service="zookeeper"}`,
+            },
+        },
+        {
+            name:    "roles",
+            scraper: ScrapeZookeeperRoles{},
+            want: map[string]float64{
+                `kbdi_zookeeper_role_state{cluster="c1",host="zk1",role="zookeeper-SERVER-1",service="zookeeper",state="STARTED"}`: 1,
+                `kbdi_zookeeper_role_state{cluster="c1",host="zk3",role="zookeeper-SERVER-3",service="zookeeper",state="STARTED"}`: 1,
+                `kbdi_zookeeper_role_maintenance_mode{cluster="c1",host="zk2",role="zookeeper-SERVER-2",service="zookeeper"}`:      0,
+            },
+        },
+        {
+            name:    "maintenance mode",
+            scraper: ScrapeZookeeperMaintenance{},
+            fixtures: func(s *cmmock.Server) {
+                s.Add_cluster(cmmock.Cluster{Name: "c2", Maintenance_mode: true, Services: []cmmock.Service{{
+                    Name:  "zookeeper2",
+                    Type:  ZK_SERVICE_TYPE,
+                    Roles: []cmmock.Role{{Name: "zookeeper2-SERVER-1", Type: ZK_SERVER_ROLE_TYPE, Host_id: "host-1"}},
+                }}})
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_maintenance_mode{cluster="c1",role="",scope="cluster",service="zookeeper"}`:                    0,
+                `kbdi_zookeeper_maintenance_mode{cluster="c2",role="",scope="cluster",service="zookeeper2"}`:                   1,
+                `kbdi_zookeeper_maintenance_mode{cluster="c2",role="zookeeper2-SERVER-1",scope="role",service="zookeeper2"}`: 0,
+            },
+        },
+        {
+            name:    "configuration",
+            scraper: ScrapeZookeeperConfig{},
+            want: map[string]float64{
+                `kbdi_zookeeper_config_changed_keys_total{cluster="c1",service="zookeeper"}`:         0,
+                `kbdi_zookeeper_config_changed_timestamp_seconds{cluster="c1",service="zookeeper"}`: 0,
+            },
+        },
+        {
+            name:    "events",
+            scraper: ScrapeZookeeperEvents{},
+            fixtures: func(s *cmmock.Server) {
+                for i, severity := range []string{"CRITICAL", "IMPORTANT", "IMPORTANT"} {
+                    s.Add_event(cmmock.Event{
+                        Id:            fmt.Sprintf("event-%d", i),
+                        Category:      "HEALTH_EVENT",
+                        Severity:      severity,
+                        Time_occurred: occurred,
+                        Time_received: occurred,
+                        Attributes:    map[string]string{"CLUSTER": "c1", "SERVICE": "zookeeper", "SERVICE_TYPE": ZK_SERVICE_TYPE},
+                    })
+                }
+                s.Add_event(cmmock.Event{
+                    Id:            "event-hdfs",
+                    Category:      "HEALTH_EVENT",
+                    Severity:      "CRITICAL",
+                    Time_occurred: occurred,
+                    Time_received: occurred,
+                    Attributes:    map[string]string{"CLUSTER": "c1", "SERVICE": "hdfs", "SERVICE_TYPE": "HDFS"},
+                })
+            },
+            want: map[string]float64{
+                `kbdi_zookeeper_events_total{category="HEALTH_EVENT",cluster="c1",service="zookeeper",severity="CRITICAL"}`:  1,
+                `kbdi_zookeeper_events_total{category="HEALTH_EVENT",cluster="c1",service="zookeeper",severity="IMPORTANT"}`: 2,
+                `kbdi_zookeeper_last_critical_event_timestamp_seconds{cluster="c1",service="zookeeper"}`:                    float64(occurred.Unix()),
+            },
+        },
+    }
+
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            resetZKTestState()
+            defer resetZKTestState()
+
+            s := newZKTestServer()
+            defer s.Close()
+            if c.fixtures != nil {
+                c.fixtures(s)
+            }
+            config := newZKTestConfig(t, s)
+            if c.config != nil {
+                c.config(&config)
+            }
+
+            series := gatherZKSeries(t, config, c.scraper)
+            for name, want := range c.want {
+                got, ok := series[name]
+                switch {
+                case !ok:
+                    t.Errorf("%s not exported", name)
+                case math.IsNaN(want) && !math.IsNaN(got), !math.IsNaN(want) && math.Abs(got-want) > 1e-9:
+                    t.Errorf("%s = %v, want %v", name, got, want)
+                }
+            }
+            for _, name := range c.absent {
+                if value, ok := series[name]; ok {
+                    t.Errorf("%s exported with value %v", name, value)
+                }
+            }
+
+            requests := s.Requests()
+            for _, prefix := range c.requests {
+                found := false
+                for _, request := range requests {
+                    found = found || strings.HasPrefix(request, prefix)
+                }
+                if !found {
+                    t.Errorf("No request to %s. Requests: %v", prefix, requests)
+                }
+            }
+            for _, prefix := range c.unrequested {
+                for _, request := range requests {
+                    if strings.HasPrefix(request, prefix) {
+                        t.Errorf("Request to %s. Requests: %v", prefix, requests)
+                        break
+                    }
+                }
+            }
+        })
+    }
+}
+
+// TestAPIVersionRenegotiatedOnce checks that once Cloudera Manager is
+// upgraded, the queries of the next scrapes use the renegotiated version
+// instead of the one of the configuration
+func TestAPIVersionRenegotiatedOnce(t *testing.T) {
+    resetZKTestState()
+    defer resetZKTestState()
+
+    s := newZKTestServer()
+    defer s.Close()
+    s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", 4242))
+    config := newZKTestConfig(t, s)
+    config.Api_version = "v18"
+    config.Api_version_pinned = false
+
+    collector := New(context.Background(), config, NewMetrics(), []Scraper{ScrapeZookeeperMetrics{}})
+    for scrape := 0; scrape < 2; scrape++ {
+        registry := prometheus.NewRegistry()
+        registry.MustRegister(collector)
+        if _, err := registry.Gather(); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    negotiations, unsupported := 0, 0
+    for _, request := range s.Requests() {
+        switch {
+        case strings.HasPrefix(request, "/api/version"):
+            negotiations++
+        case strings.HasPrefix(request, "/api/v18/"):
+            unsupported++
+        }
+    }
+    if negotiations != 1 || unsupported != 1 {
+        t.Errorf("%d negotiations and %d queries with v18, want 1 of each. Requests: %v", negotiations, unsupported, s.Requests())
+    }
+}
+
+// TestConcurrentScrapesNegotiateAPIVersion checks that the concurrent
+// scrapes of a collector activated from standby negotiate the API version
+// without writing the shared configuration. Run with -race
+func TestConcurrentScrapesNegotiateAPIVersion(t *testing.T) {
+    resetZKTestState()
+    defer resetZKTestState()
+
+    s := newZKTestServer()
+    defer s.Close()
+    s.Set_timeseries(ZK_CURRENT_XID, nil, zkTestSerie("zookeeper", "", 4242))
+    config := newZKTestConfig(t, s)
+    config.Api_version = ""
+    config.Api_version_pinned = false
+
+    collector := New(context.Background(), config, NewMetrics(), []Scraper{ScrapeZookeeperMetrics{}})
+    var wg sync.WaitGroup
+    for scrape := 0; scrape < 4; scrape++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            ch := make(chan prometheus.Metric)
+            go func() {
+                collector.Collect(ch)
+                close(ch)
+            }()
+            for range ch {
+            }
+        }()
+    }
+    wg.Wait()
+
+    if got := get_api_version(config); got != "v19" {
+        t.Errorf("API version %q, want v19", got)
+    }
+}
+
+// TestConcurrentEventScrapes checks that the events are fetched without
+// blocking the other scrapes, and that the events fetched by concurrent
+// scrapes are counted once
+func TestConcurrentEventScrapes(t *testing.T) {
+    resetZKTestState()
+    defer resetZKTestState()
+
+    s := newZKTestServer()
+    defer s.Close()
+    occurred := time.Now().Add(-2 * time.Minute).Truncate(time.Second)
+    for i, severity := range []string{"CRITICAL", "IMPORTANT", "IMPORTANT"} {
+        s.Add_event(cmmock.Event{
+            Id:            fmt.Sprintf("event-%d", i),
+            Category:      "HEALTH_EVENT",
+            Severity:      severity,
+            Time_occurred: occurred,
+            Time_received: occurred.Add(time.Duration(i) * time.Second),
+            Attributes:    map[string]string{"CLUSTER": "c1", "SERVICE": "zookeeper", "SERVICE_TYPE": ZK_SERVICE_TYPE},
+        })
+    }
+    s.Add_event(cmmock.Event{
+        Id:            "event-hdfs",
+        Category:      "HEALTH_EVENT",
+        Severity:      "CRITICAL",
+        Time_occurred: occurred,
+        Time_received: occurred,
+        Attributes:    map[string]string{"CLUSTER": "c1", "SERVICE": "hdfs", "SERVICE_TYPE": "HDFS"},
+    })
+    s.Inject_fault("events", cmmock.Fault{Delay: 500 * time.Millisecond, Times: 2})
+    config := newZKTestConfig(t, s)
+
+    var wg sync.WaitGroup
+    for scrape := 0; scrape < 2; scrape++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            ch := make(chan prometheus.Metric)
+            go func() {
+                ScrapeZookeeperEvents{}.Scrape(context.Background(), &config, ch)
+                close(ch)
+            }()
+            for range ch {
+            }
+        }()
+    }
+    time.Sleep(200 * time.Millisecond)
+    start := time.Now()
+    zkEvents.Lock()
+    zkEvents.Unlock()
+    if waited := time.Since(start); waited > 100*time.Millisecond {
+        t.Errorf("Waited %s for the events while they were fetched", waited)
+    }
+    wg.Wait()
+
+    series := gatherZKSeries(t, config, ScrapeZookeeperEvents{})
+    for name, want := range map[string]float64{
+        `kbdi_zookeeper_events_total{category="HEALTH_EVENT",cluster="c1",service="zookeeper",severity="CRITICAL"}`:  1,
+        `kbdi_zookeeper_events_total{category="HEALTH_EVENT",cluster="c1",service="zookeeper",severity="IMPORTANT"}`: 2,
+    } {
+        if got := series[name]; got != want {
+            t.Errorf("%s = %v, want %v", name, got, want)
+        }
+    }
+    // The events of the other services are filtered by the events API
+    if events := zkEvents.bySource[zkEventSource{"c1", "hdfs"}]; events != nil {
+        t.Errorf("Fetched the events of HDFS: %v", events.counts)
+    }
+}
+
+// TestZnodesPrefixesReloaded checks that the walks of the prefixes removed
+// from the configuration are not exported anymore
+func TestZnodesPrefixesReloaded(t *testing.T) {
+    resetZKTestState()
+    defer resetZKTestState()
+
+    s := newZKTestServer()
+    defer s.Close()
+    config := newZKTestConfig(t, s)
+    config.Znode_prefixes = []string{"/hbase"}
+    config.Znode_walk_interval = time.Hour
+
+    // Last walk of the previous configuration
+    service := clouderaService{Cluster: "c1", Name: "zookeeper", Type: ZK_SERVICE_TYPE}
+    zkZnodes.Lock()
+    zkZnodes.prefixes = "/kafka"
+    zkZnodes.byService[service] = &zkZnodeStats{
+        byPrefix:    map[string]zkPrefixStats{"/kafka": {count: 12, dataBytes: 2048}},
+        walkedAt:    time.Now(),
+        walkSeconds: 0.5,
+    }
+    zkZnodes.Unlock()
+
+    series := gatherZKSeries(t, config, ScrapeZookeeperZnodes{})
+    for _, name := range []string{
+        `kbdi_zookeeper_znode_count{cluster="c1",prefix="/kafka",service="zookeeper"}`,
+        `kbdi_zookeeper_znode_walk_timestamp_seconds{cluster="c1",service="zookeeper"}`,
+    } {
+        if value, ok := series[name]; ok {
+            t.Errorf("%s exported with value %v", name, value)
+        }
+    }
+}
+
+// TestZookeeperDirect checks the stats read from the ZooKeeper servers with
+// the direct_zookeeper feature flag, and that they are not read without it
+func TestZookeeperDirect(t *testing.T) {
+    resetZKTestState()
+    defer resetZKTestState()
+    defer Set_feature_flags(nil)
+
+    // ZooKeeper server answering srvr on 127.0.0.1. Nothing listens on
+    // 127.0.0.2, the host of the second server
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return
+            }
+            command := make([]byte, 4)
+            if _, err := io.ReadFull(conn, command); err == nil && string(command) == "srvr" {
+                io.WriteString(conn, "Zookeeper version: 3.8.4-9316c2a7a97e1666d8f4593f34dd6fc36ecc436c, built on 2024-02-12 22:16 UTC\n"+
+                    "Latency min/avg/max: 0/0.5/12\nReceived: 120\nSent: 119\nConnections: 3\nOutstanding: 1\n"+
+                    "Zxid: 0x100000004\nMode: leader\nNode count: 42\nProposal sizes last/min/max: 48/48/91\n")
+            }
+            conn.Close()
+        }
+    }()
+
+    s := cmmock.New_server("v19")
+    defer s.Close()
+    s.Set_basic_auth("admin", "admin")
+    roles := []cmmock.Role{}
+    for i := 1; i <= 2; i++ {
+        hostID := fmt.Sprintf("host-%d", i)
+        s.Add_host(cmmock.Host{Host_id: hostID, Hostname: fmt.Sprintf("127.0.0.%d", i)})
+        roles = append(roles, cmmock.Role{Name: fmt.Sprintf("zookeeper-SERVER-%d", i), Type: ZK_SERVER_ROLE_TYPE, Host_id: hostID, State: "STARTED"})
+    }
+    s.Add_cluster(cmmock.Cluster{Name: "c1", Services: []cmmock.Service{{Name: "zookeeper", Type: ZK_SERVICE_TYPE, State: "STARTED", Roles: roles}}})
+    config := newZKTestConfig(t, s)
+    config.Znode_client_port = uint(listener.Addr().(*net.TCPAddr).Port)
+    leader := fmt.Sprintf("127.0.0.1:%d", config.Znode_client_port)
+    down := fmt.Sprintf("127.0.0.2:%d", config.Znode_client_port)
+    direct := func(name string, server string) string {
+        return fmt.Sprintf(`kbdi_zookeeper_direct_%s{cluster="c1",server=%q,service="zookeeper"}`, name, server)
+    }
+
+    for name := range gatherZKSeries(t, config, ScrapeZookeeperMetrics{}) {
+        if strings.HasPrefix(name, "kbdi_zookeeper_direct_") {
+            t.Errorf("%s exported without the direct_zookeeper feature flag", name)
+        }
+    }
+
+    if err := Set_feature_flags(map[string]bool{FEATURE_DIRECT_ZOOKEEPER: true}); err != nil {
+        t.Fatal(err)
+    }
+    series := gatherZKSeries(t, config, ScrapeZookeeperMetrics{})
+    for name, want := range map[string]float64{
+        direct("up", leader):                             1,
+        direct("leader", leader):                         1,
+        direct("request_latency_min_seconds", leader):    0,
+        direct("request_latency_avg_seconds", leader):    0.0005,
+        direct("request_latency_max_seconds", leader):    0.012,
+        direct("connections", leader):                    3,
+        direct("outstanding_requests", leader):           1,
+        direct("znode_count", leader):                    42,
+        direct("packets_received_total", leader):         120,
+        direct("packets_sent_total", leader):             119,
+        direct("up", down):                               0,
+    } {
+        got, ok := series[name]
+        switch {
+        case !ok:
+            t.Errorf("%s not exported", name)
+        case math.Abs(got-want) > 1e-9:
+            t.Errorf("%s = %v, want %v", name, got, want)
+        }
+    }
+    if value, ok := series[direct("leader", down)]; ok {
+        t.Errorf("%s exported with value %v for a server down", direct("leader", down), value)
+    }
+}
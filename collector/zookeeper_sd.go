@@ -0,0 +1,92 @@
+/*
+ *
+ * title           :collector/zookeeper_sd.go
+ * description     :Prometheus HTTP service discovery of the ZooKeeper
+ *                  servers managed by Cloudera Manager
+ * author          :Enes Erdoğan
+ * date            :2025/07/14
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "fmt"
+    "sort"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Cloudera Manager role type of the ZooKeeper servers
+const ZK_SERVER_ROLE_TYPE = "SERVER"
+
+// Prefix of the labels of the discovered targets. Prometheus drops the
+// __meta_ labels after the relabeling
+const SD_LABEL_PREFIX = "__meta_cloudera_"
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// TargetGroup is a target group of the Prometheus HTTP SD format
+type TargetGroup struct {
+    Targets []string          `json:"targets"`
+    Labels  map[string]string `json:"labels"`
+}
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// DiscoverZookeeperTargets returns a target group for each ZooKeeper server
+// role, with the host of the role and the given port as target, sorted by
+// target
+func DiscoverZookeeperTargets(ctx context.Context, config Collector_connection_data, port uint) ([]TargetGroup, error) {
+    services, err := discoverServices(ctx, config, ZK_SERVICE_TYPE)
+    if err != nil {
+        return nil, err
+    }
+    hostNames := scrape_hostName(ctx, config, "hosts")
+
+    groups := []TargetGroup{}
+    for _, service := range services {
+        jsonParsed, err := make_and_parse_api_query(ctx, config, service.apiPath("roles"))
+        if err != nil {
+            log.Err_msg("Cannot list the roles of the ZooKeeper service %s/%s: %s", service.Cluster, service.Name, err)
+            continue
+        }
+
+        numRoles := jp.Get_api_query_items_num(jsonParsed)
+        for roleIndex := 0; roleIndex < numRoles; roleIndex++ {
+            if jp.Get_api_query_role_type(jsonParsed, roleIndex) != ZK_SERVER_ROLE_TYPE {
+                continue
+            }
+            hostID := jp.Get_api_query_host_id_by_hostRef(jsonParsed, roleIndex)
+            hostName := Get_hostName_with_hostId(hostNames, hostID)
+            if hostName == "" {
+                log.Warn_msg("Unknown host %s of the ZooKeeper role %s", hostID, jp.Get_api_query_role_name(jsonParsed, roleIndex))
+                continue
+            }
+            groups = append(groups, TargetGroup{
+                Targets: []string{fmt.Sprintf("%s:%d", hostName, port)},
+                Labels: map[string]string{
+                    SD_LABEL_PREFIX + "cluster":    service.Cluster,
+                    SD_LABEL_PREFIX + "service":    service.Name,
+                    SD_LABEL_PREFIX + "role":       jp.Get_api_query_role_name(jsonParsed, roleIndex),
+                    SD_LABEL_PREFIX + "role_state": jp.Get_api_query_role_state(jsonParsed, roleIndex),
+                    SD_LABEL_PREFIX + "host":       hostName,
+                },
+            })
+        }
+    }
+    sort.Slice(groups, func(i, j int) bool { return groups[i].Targets[0] < groups[j].Targets[0] })
+    return groups, nil
+}
@@ -0,0 +1,355 @@
+/*
+ *
+ * title           :collector/zookeeper_znodes_module.go
+ * description     :Submodule Collector for the znode tree of the ZooKeeper
+ *                  services: count and data size of the znodes under the
+ *                  configured path prefixes, read from the servers
+ * author          :Enes Erdoğan
+ * date            :2025/10/27
+ * version         :1.0
+ *
+ */
+package collector
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+    // Go Default libraries
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    // Own libraries
+    jp "keedio/cloudera_exporter/json_parser"
+    log "keedio/cloudera_exporter/logger"
+
+    // Go external libraries
+    "github.com/go-zookeeper/zk"
+
+    // Go Prometheus libraries
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+const ZK_ZNODES_SCRAPER_NAME = "zookeeper_znodes"
+
+// Session timeout of the connections to the ZooKeeper servers, and time to
+// establish the session
+const ZK_SESSION_TIMEOUT = 10 * time.Second
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Znodes found under a path prefix, the prefix included
+type zkPrefixStats struct {
+    count     float64
+    dataBytes float64
+}
+
+// Last walk of the znode tree of a service
+type zkZnodeStats struct {
+    byPrefix    map[string]zkPrefixStats
+    walkedAt    time.Time
+    walkSeconds float64
+    walking     bool
+}
+
+// Logger of the ZooKeeper client, which logs its connections in debug mode
+type zkLogger struct{}
+
+/* ======================================================================
+ * Global variables (Prometheus descriptors)
+ * ====================================================================== */
+// Last walk of each service, kept between scrapes, as the walks run on their
+// own schedule, and the prefixes they walked. The walks are dropped when the
+// configured prefixes change, so the removed prefixes are not exported
+var zkZnodes = struct {
+    sync.Mutex
+    prefixes  string
+    byService map[clouderaService]*zkZnodeStats
+}{byService: map[clouderaService]*zkZnodeStats{}}
+
+var (
+    zkZnodeCountDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "znode_count"),
+        "Number of znodes under the path prefix (itself included) in the last walk of the ZooKeeper service znode tree",
+        []string{"cluster", "service", "prefix"},
+        nil,
+    )
+    zkZnodeDataBytesDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "znode_data_bytes"),
+        "Total size of the data of the znodes under the path prefix (itself included) in the last walk of the ZooKeeper service znode tree",
+        []string{"cluster", "service", "prefix"},
+        nil,
+    )
+    zkZnodeWalkTimestampDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "znode_walk_timestamp_seconds"),
+        "Time when the last walk of the ZooKeeper service znode tree finished",
+        []string{"cluster", "service"},
+        nil,
+    )
+    zkZnodeWalkDurationDesc = new_desc(
+        prometheus.BuildFQName(namespace, ZK_SCRAPER_NAME, "znode_walk_duration_seconds"),
+        "Time the last walk of the ZooKeeper service znode tree took",
+        []string{"cluster", "service"},
+        nil,
+    )
+)
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Printf implements zk.Logger.
+func (zkLogger) Printf(format string, args ...interface{}) {
+    log.Debug_msg(append([]interface{}{format}, args...)...)
+}
+
+// getZKServers returns the host:port addresses of the servers of the service,
+// with the hosts of its SERVER roles and the client port, sorted
+func getZKServers(ctx context.Context, config Collector_connection_data, service clouderaService) ([]string, error) {
+    jsonParsed, err := make_and_parse_api_query(ctx, config, service.apiPath("roles"))
+    if err != nil {
+        return nil, err
+    }
+    hostNames := scrape_hostName(ctx, config, "hosts")
+
+    servers := []string{}
+    numRoles := jp.Get_api_query_items_num(jsonParsed)
+    for roleIndex := 0; roleIndex < numRoles; roleIndex++ {
+        if jp.Get_api_query_role_type(jsonParsed, roleIndex) != ZK_SERVER_ROLE_TYPE {
+            continue
+        }
+        if hostName := Get_hostName_with_hostId(hostNames, jp.Get_api_query_host_id_by_hostRef(jsonParsed, roleIndex)); hostName != "" {
+            servers = append(servers, fmt.Sprintf("%s:%d", hostName, config.Znode_client_port))
+        }
+    }
+    if len(servers) == 0 {
+        return nil, errors.New("no ZooKeeper server with a known host")
+    }
+    sort.Strings(servers)
+    return servers, nil
+}
+
+// connectZK opens a session with one of the servers
+func connectZK(servers []string) (*zk.Conn, error) {
+    conn, events, err := zk.Connect(servers, ZK_SESSION_TIMEOUT, zk.WithLogger(zkLogger{}))
+    if err != nil {
+        return nil, err
+    }
+    timeout := time.After(ZK_SESSION_TIMEOUT)
+    for {
+        select {
+        case event := <-events:
+            if event.State == zk.StateHasSession {
+                return conn, nil
+            }
+        case <-timeout:
+            conn.Close()
+            return nil, errors.New("timeout establishing the session")
+        }
+    }
+}
+
+// walkZnodes counts the znodes under the prefix and the size of their data.
+// The znodes that don't exist (removed during the walk) are not counted, and
+// the ones the exporter can't read (ACL) are skipped with their children
+func walkZnodes(conn *zk.Conn, prefix string) (zkPrefixStats, int, error) {
+    stats, skipped := zkPrefixStats{}, 0
+    pending := []string{prefix}
+    for len(pending) > 0 {
+        path := pending[len(pending)-1]
+        pending = pending[:len(pending)-1]
+
+        children, stat, err := conn.Children(path)
+        switch err {
+        case nil:
+        case zk.ErrNoNode:
+            continue
+        case zk.ErrNoAuth:
+            skipped++
+            continue
+        default:
+            return stats, skipped, err
+        }
+        stats.count++
+        stats.dataBytes += float64(stat.DataLength)
+        for _, child := range children {
+            if path == "/" {
+                pending = append(pending, "/"+child)
+            } else {
+                pending = append(pending, path+"/"+child)
+            }
+        }
+    }
+    return stats, skipped, nil
+}
+
+// resetZKZnodes drops the walks of every service if the prefixes are not the
+// ones they walked (e.g. after a reload of the configuration). The walks in
+// progress keep the dropped stats, so their result is not exported
+func resetZKZnodes(prefixes []string) {
+    key := strings.Join(prefixes, "\n")
+    zkZnodes.Lock()
+    defer zkZnodes.Unlock()
+    if zkZnodes.prefixes != key {
+        zkZnodes.prefixes = key
+        zkZnodes.byService = map[clouderaService]*zkZnodeStats{}
+    }
+}
+
+// walkZKService walks the znode tree of the service under every prefix and
+// keeps the result in its stats. The previous result is kept if the walk
+// fails
+func walkZKService(service clouderaService, stats *zkZnodeStats, servers []string, prefixes []string) {
+    start := time.Now()
+    byPrefix, err := func() (map[string]zkPrefixStats, error) {
+        conn, err := connectZK(servers)
+        if err != nil {
+            return nil, err
+        }
+        defer conn.Close()
+
+        byPrefix := make(map[string]zkPrefixStats, len(prefixes))
+        for _, prefix := range prefixes {
+            stats, skipped, err := walkZnodes(conn, prefix)
+            if err != nil {
+                return nil, err
+            }
+            if skipped > 0 {
+                log.Warn_msg("%d znodes under %s of the ZooKeeper service %s/%s not readable by the exporter (ACL), not counted with their children", skipped, prefix, service.Cluster, service.Name)
+            }
+            byPrefix[prefix] = stats
+        }
+        return byPrefix, nil
+    }()
+
+    zkZnodes.Lock()
+    defer zkZnodes.Unlock()
+    stats.walking = false
+    if err != nil {
+        log.Err_msg("Cannot walk the znode tree of the ZooKeeper service %s/%s: %s", service.Cluster, service.Name, err)
+        return
+    }
+    stats.byPrefix, stats.walkedAt, stats.walkSeconds = byPrefix, time.Now(), time.Since(start).Seconds()
+}
+
+// scrapeZKZnodes starts a walk of the znode tree of the service in the
+// background if the interval has passed, and emits the last one. The walk
+// can take longer than a scrape, so it never delays the scrapes
+func scrapeZKZnodes(
+    ctx context.Context,
+    config Collector_connection_data,
+    service clouderaService,
+    ch chan<- prometheus.Metric,
+) bool {
+    zkZnodes.Lock()
+    stats := zkZnodes.byService[service]
+    if stats == nil {
+        stats = &zkZnodeStats{}
+        zkZnodes.byService[service] = stats
+    }
+    due := !stats.walking && time.Since(stats.walkedAt) >= config.Znode_walk_interval
+    if due {
+        stats.walking = true
+    }
+    zkZnodes.Unlock()
+
+    success := true
+    if due {
+        servers, err := getZKServers(ctx, config, service)
+        if err != nil {
+            log.Err_msg("Cannot find the servers of the ZooKeeper service %s/%s: %s", service.Cluster, service.Name, err)
+            zkZnodes.Lock()
+            stats.walking = false
+            zkZnodes.Unlock()
+            success = false
+        } else {
+            go walkZKService(service, stats, servers, config.Znode_prefixes)
+        }
+    }
+
+    zkZnodes.Lock()
+    defer zkZnodes.Unlock()
+    if stats.walkedAt.IsZero() {
+        return success
+    }
+    for prefix, prefixStats := range stats.byPrefix {
+        ch <- prometheus.MustNewConstMetric(zkZnodeCountDesc, prometheus.GaugeValue, prefixStats.count, service.Cluster, service.Name, prefix)
+        ch <- prometheus.MustNewConstMetric(zkZnodeDataBytesDesc, prometheus.GaugeValue, prefixStats.dataBytes, service.Cluster, service.Name, prefix)
+    }
+    ch <- prometheus.MustNewConstMetric(zkZnodeWalkTimestampDesc, prometheus.GaugeValue, float64(stats.walkedAt.UnixNano())/1e9, service.Cluster, service.Name)
+    ch <- prometheus.MustNewConstMetric(zkZnodeWalkDurationDesc, prometheus.GaugeValue, stats.walkSeconds, service.Cluster, service.Name)
+    return success
+}
+
+/* ======================================================================
+ * Scrape "Class"
+ * ====================================================================== */
+type ScrapeZookeeperZnodes struct{}
+
+// Name returns the Scraper name (must be unique).
+func (ScrapeZookeeperZnodes) Name() string {
+    return ZK_ZNODES_SCRAPER_NAME
+}
+
+// Help describes the role of this Scraper.
+func (ScrapeZookeeperZnodes) Help() string {
+    return "Collects the count and data size of the znodes under the configured path prefixes from the ZooKeeper servers"
+}
+
+// Version is an arbitrary float for the scraper version.
+func (ScrapeZookeeperZnodes) Version() float64 {
+    return 1.0
+}
+
+// ServiceType returns the type of the collected services.
+func (ScrapeZookeeperZnodes) ServiceType() string {
+    return ZK_SERVICE_TYPE
+}
+
+// Scrape discovers the ZooKeeper services and emits the statistics of their
+// znode trees
+func (ScrapeZookeeperZnodes) Scrape(
+    ctx context.Context,
+    config *Collector_connection_data,
+    ch chan<- prometheus.Metric,
+) error {
+    log.Debug_msg("Executing ZooKeeper Znodes Scraper")
+    resetZKZnodes(config.Znode_prefixes)
+    if len(config.Znode_prefixes) == 0 {
+        return nil
+    }
+
+    services, err := discoverServices(ctx, *config, ZK_SERVICE_TYPE)
+    if err != nil {
+        return err
+    }
+
+    successQueries := 0
+    errorQueries := 0
+    for _, service := range services {
+        eval_scrape(scrapeZKZnodes(ctx, *config, service, ch), &successQueries, &errorQueries)
+    }
+
+    log.Debug_msg(
+        "ZK Znodes Scraper: %d queries run, %d successful, %d errors",
+        successQueries+errorQueries,
+        successQueries,
+        errorQueries,
+    )
+    return nil
+}
+
+// Ensure ScrapeZookeeperZnodes implements the ClouderaServiceCollector interface
+var _ ClouderaServiceCollector = ScrapeZookeeperZnodes{}
+
+func init() {
+    MustRegisterServiceCollector(ScrapeZookeeperZnodes{})
+}
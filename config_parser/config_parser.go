@@ -15,16 +15,42 @@ package config_parser
 import (
   // Own Libraries
   cl "keedio/cloudera_exporter/collector"
+  cm "keedio/cloudera_exporter/cm_client"
   log "keedio/cloudera_exporter/logger"
+  "keedio/cloudera_exporter/otlp"
+  "keedio/cloudera_exporter/remote_write"
+  "crypto/tls"
+  "crypto/x509"
   "errors"
+  "io/ioutil"
+  "net/http"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
 
   // Go External libraries
   "gopkg.in/ini.v1"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/common/model"
 )
 
 
 
 
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Prefix of the sections that define custom metrics
+const CUSTOM_METRIC_SECTION_PREFIX = "custom_metric."
+
+// Prefix of the sections that override the endpoint of a cluster
+const CLUSTER_SECTION_PREFIX = "cluster."
+
+
+
+
 /* ======================================================================
  * Error Messages
  * ====================================================================== */
@@ -37,6 +63,43 @@ import (
   error_msg_no_deploy_ip = "No deploy_ip specified in config file. The exporter will use the public IP"
   error_msg_no_deploy_port = "No deploy_port specified in config file"
   error_msg_no_log_level = "No log_level specified in config file"
+  error_msg_bad_window = "Invalid window in [timeseries] section of config file"
+  error_msg_bad_rollup = "Invalid desired_rollup in [timeseries] section of config file"
+  error_msg_bad_rollup_statistic = "Invalid rollup_statistic in [timeseries] section of config file"
+  error_msg_bad_max_sample_age = "Invalid max_sample_age in [timeseries] section of config file"
+  error_msg_bad_http_client = "Invalid idle_conn_timeout or timeout in [http_client] section of config file"
+  error_msg_bad_max_response_size = "Invalid max_response_size in [http_client] section of config file"
+  error_msg_bad_rate_limit = "Invalid rate_limit or rate_limit_burst in [http_client] section of config file"
+  error_msg_bad_proxy_url = "Invalid proxy_url in [http_client] section of config file"
+  error_msg_bad_cluster_base_url = "Invalid base_url in [cluster.<name>] section of config file"
+  error_msg_bad_cluster_auth = "Invalid auth in [cluster.<name>] section of config file. Use basic, bearer or none"
+  error_msg_bad_cluster_ca_file = "Invalid tls_ca_file in [cluster.<name>] section of config file"
+  error_msg_no_otlp_endpoint = "No endpoint specified in [otlp] section of config file"
+  error_msg_bad_otlp_interval = "Invalid interval or timeout in [otlp] section of config file"
+  error_msg_bad_otlp_compression = "Invalid compression in [otlp] section of config file, must be gzip or none"
+  error_msg_no_remote_write_url = "No url specified in [remote_write] section of config file"
+  error_msg_bad_remote_write_interval = "Invalid interval or timeout in [remote_write] section of config file"
+  error_msg_bad_secrets_refresh = "Invalid secrets_refresh_interval in [user] section of config file"
+  error_msg_no_vault_address = "No address or path specified in [vault] section of config file"
+  error_msg_bad_latency_mode = "Invalid latency_mode (series, summary) in [zookeeper] section of config file"
+  error_msg_bad_latency_buckets = "Invalid latency_buckets (increasing positive numbers) in [zookeeper] section of config file"
+  error_msg_bad_config_snapshot_interval = "Invalid config_snapshot_interval in [zookeeper] section of config file"
+  error_msg_bad_no_data = "Invalid no_data (omit, nan, present) in [timeseries] section of config file"
+  error_msg_bad_feature_flag = "Unknown feature flag or invalid value in [feature_flags] section of config file"
+  error_msg_bad_sd_target_port = "Invalid target_port in [service_discovery] section of config file"
+  error_msg_bad_collection_interval = "Invalid collection_interval in [system] section of config file"
+  error_msg_bad_events_lookback = "Invalid events_lookback in [zookeeper] section of config file"
+  error_msg_bad_znode_prefixes = "Invalid znode_prefixes (absolute paths) in [zookeeper] section of config file"
+  error_msg_bad_znode_walk = "Invalid znode_walk_interval or znode_client_port in [zookeeper] section of config file"
+  error_msg_bad_shutdown_grace_period = "Invalid shutdown_grace_period in [system] section of config file"
+  error_msg_bad_shard = "Invalid shard_index or shard_total in [system] section of config file (0 <= shard_index < shard_total)"
+  error_msg_no_debug_token = "No debug_token specified in [system] section of config file. It is required by debug_endpoint"
+  error_msg_no_standby_token = "No standby_token specified in [system] section of config file. It is required by standby_endpoint"
+  error_msg_no_reload_token = "No reload_token specified in [system] section of config file. It is required by reload_endpoint"
+  error_msg_bad_const_label = "Invalid label name in [const_labels] section of config file"
+  error_msg_const_identity_label = "Label of the [const_labels] section of config file already set as an identity label in [system] section"
+  error_msg_no_update_check_url = "No url specified in [update_check] section of config file"
+  error_msg_bad_update_check_interval = "Invalid interval or timeout in [update_check] section of config file"
 )
 
 
@@ -45,11 +108,25 @@ import (
 /* ======================================================================
  * Data Structs
  * ====================================================================== */
+// Files of the credentials given in the execution flags. They have priority
+// over the config file
+type Secret_files struct {
+  User_file string
+  Password_file string
+}
+
 // Struct to store the list of Scrapers and if they are going to be loaded
 type CE_collectors_flags struct {
   Scrapers map [cl.Scraper] bool
 }
 
+// Check of the latest release of the exporter
+type Update_check_options struct {
+  Url string
+  Interval time.Duration
+  Timeout time.Duration
+}
+
 // Struct to group the two previous structs and some exporter configuration parameters
 type CE_config struct {
   Num_procs int
@@ -58,6 +135,24 @@ type CE_config struct {
   Deploy_ip string
   Deploy_port uint
   Log_level int
+  Identity_labels map[string]string
+  Standby bool
+  Standby_endpoint bool
+  Standby_token string
+  Otlp *otlp.Options
+  Secrets_refresh_interval time.Duration
+  Feature_flags map[string]bool
+  Reload_endpoint bool
+  Reload_token string
+  Service_discovery bool
+  Sd_target_port uint
+  Collection_interval time.Duration
+  Update_check *Update_check_options
+  Shutdown_grace_period time.Duration
+  Debug_endpoint bool
+  Debug_token string
+  Const_labels map[string]string
+  Remote_write *remote_write.Options
 }
 
 
@@ -66,26 +161,83 @@ type CE_config struct {
 /* ======================================================================
  * Functions
  * ====================================================================== */
-func parse_user (config_reader *ini.File) (string, error) {
-  user := config_reader.Section("user").Key("username").String()
-  if user == "" {
+// Secret of the Vault secret with the credentials. Nil if Vault is disabled
+func parse_vault_secret (config_reader *ini.File) (*cm.Vault_secret, error) {
+  section := config_reader.Section("vault")
+  if !section.Key("enabled").MustBool(false) {
+    return nil, nil
+  }
+  if section.Key("address").String() == "" || section.Key("path").String() == "" {
+    log.Err_msg(error_msg_no_vault_address)
+    return nil, errors.New(error_msg_no_vault_address)
+  }
+
+  var token cm.Secret = cm.Env_secret{Name: "VAULT_TOKEN"}
+  if token_file := section.Key("token_file").String(); token_file != "" {
+    token = cm.File_secret{Path: token_file}
+  }
+  return &cm.Vault_secret {
+    Address: section.Key("address").String(),
+    Path: section.Key("path").String(),
+    Token: token,
+    Client: &http.Client{Timeout: 10 * time.Second},
+  }, nil
+}
+
+
+// Source of a credential of the [user] section. By priority: the file of the
+// execution flags, the <key>_file file, the <key>_env environment variable,
+// the Vault secret or the <key> value
+func parse_credential_secret (config_reader *ini.File, key string, flag_file string, vault *cm.Vault_secret) cm.Secret {
+  section := config_reader.Section("user")
+  switch {
+  case flag_file != "":
+    return cm.File_secret{Path: flag_file}
+  case section.Key(key + "_file").String() != "":
+    return cm.File_secret{Path: section.Key(key + "_file").String()}
+  case section.Key(key + "_env").String() != "":
+    return cm.Env_secret{Name: section.Key(key + "_env").String()}
+  case vault != nil:
+    vault_key := *vault
+    vault_key.Key = config_reader.Section("vault").Key(key + "_key").MustString(key)
+    return vault_key
+  }
+  return cm.Literal_secret(section.Key(key).String())
+}
+
+
+func parse_user (config_reader *ini.File, flag_file string, vault *cm.Vault_secret) (cm.Secret, error) {
+  user := parse_credential_secret(config_reader, "username", flag_file, vault)
+  if user == cm.Literal_secret("") {
     log.Err_msg(error_msg_no_user)
-    return "", errors.New(error_msg_no_user)
+    return nil, errors.New(error_msg_no_user)
   }
   return user, nil
 }
 
 
-func parse_passwd (config_reader *ini.File) (string, error) {
-  password := config_reader.Section("user").Key("password").String()
-  if password == "" {
+func parse_passwd (config_reader *ini.File, flag_file string, vault *cm.Vault_secret) (cm.Secret, error) {
+  password := parse_credential_secret(config_reader, "password", flag_file, vault)
+  if password == cm.Literal_secret("") {
     log.Err_msg(error_msg_no_password)
-    return "", errors.New(error_msg_no_password)
+    return nil, errors.New(error_msg_no_password)
   }
   return password, nil
 }
 
 
+// Interval to read the credentials again, so the rotated secrets are used
+// without a restart. 0 disables it
+func parse_secrets_refresh_interval (config_reader *ini.File) (time.Duration, error) {
+  interval, err := time.ParseDuration(config_reader.Section("user").Key("secrets_refresh_interval").MustString("30s"))
+  if err != nil || interval < 0 {
+    log.Err_msg(error_msg_bad_secrets_refresh)
+    return 0, errors.New(error_msg_bad_secrets_refresh)
+  }
+  return interval, nil
+}
+
+
 func parse_host (config_reader *ini.File) (string, error) {
   host := config_reader.Section("target").Key("host").String()
   if host == "" {
@@ -115,10 +267,12 @@ func parse_api_version (config_reader *ini.File) (string, error) {
   return api_version, nil
 }
 
-func parse_zookeeper_module_flag(config_reader *ini.File) bool {
-    // If [modules] section has "zookeeper_module = true", we load the ZooKeeper scraper
-    zookeeper_module_flag := config_reader.Section("modules").Key("zookeeper_module").MustBool(false)
-    return zookeeper_module_flag
+// Enabled collectors of Cloudera services. Each one is loaded if the [modules]
+// section has "<name>_module = true"
+func parse_service_collector_flags(config_reader *ini.File, flags map[cl.Scraper]bool) {
+  for _, collector := range cl.ServiceCollectors() {
+    flags[collector] = config_reader.Section("modules").Key(collector.Name() + "_module").MustBool(false)
+  }
 }
 
 // Dynamic load of modules
@@ -148,6 +302,541 @@ func parse_yarn_module_flag (config_reader *ini.File) bool {
 }
 
 
+// Time window of the TimeSeries Queries (from=now-window&to=now). Blank to
+// use the Cloudera Manager default
+func parse_timeseries_window (config_reader *ini.File) (time.Duration, error) {
+  window := config_reader.Section("timeseries").Key("window").String()
+  if window == "" {
+    return 0, nil
+  }
+  duration, err := time.ParseDuration(window)
+  if err != nil || duration < 0 {
+    log.Err_msg(error_msg_bad_window)
+    return 0, errors.New(error_msg_bad_window)
+  }
+  return duration, nil
+}
+
+// Rollup of the TimeSeries Queries (RAW, TEN_MINUTELY, HOURLY, SIX_HOURLY,
+// DAILY, WEEKLY). Blank to let Cloudera Manager choose it
+func parse_desired_rollup (config_reader *ini.File) (string, bool, error) {
+  section := config_reader.Section("timeseries")
+  rollup := section.Key("desired_rollup").In("", []string{"", "RAW", "TEN_MINUTELY", "HOURLY", "SIX_HOURLY", "DAILY", "WEEKLY"})
+  if rollup == "" && section.Key("desired_rollup").String() != "" {
+    log.Err_msg(error_msg_bad_rollup)
+    return "", false, errors.New(error_msg_bad_rollup)
+  }
+  return rollup, section.Key("must_use_desired_rollup").MustBool(false), nil
+}
+
+// Statistic used as value for the rollup datapoints
+func parse_rollup_statistic (config_reader *ini.File) (string, error) {
+  key := config_reader.Section("timeseries").Key("rollup_statistic")
+  statistic := key.In("", []string{"value", "mean", "min", "max", "count", "sampleValue", "stdDev"})
+  if statistic == "" && key.String() != "" {
+    log.Err_msg(error_msg_bad_rollup_statistic)
+    return "", errors.New(error_msg_bad_rollup_statistic)
+  }
+  if statistic == "" {
+    statistic = "value"
+  }
+  return statistic, nil
+}
+
+// Retries of the TimeSeries queries that time out, each one with half the
+// window and a coarser rollup. 0 disables them
+func parse_timeout_retries (config_reader *ini.File) int {
+  return config_reader.Section("timeseries").Key("timeout_retries").MustInt(2)
+}
+
+// Feature flags of the experimental behaviors set in the config file. The
+// flags not set keep their default
+func parse_feature_flags (config_reader *ini.File) (map[string]bool, error) {
+  known := map[string]bool{}
+  for _, flag := range cl.Get_feature_flags() {
+    known[flag.Name] = true
+  }
+  flags := map[string]bool{}
+  for _, key := range config_reader.Section("feature_flags").Keys() {
+    enabled, err := key.Bool()
+    if err != nil || !known[key.Name()] {
+      log.Err_msg("%s: %s", error_msg_bad_feature_flag, key.Name())
+      return nil, errors.New(error_msg_bad_feature_flag)
+    }
+    flags[key.Name()] = enabled
+  }
+  return flags, nil
+}
+
+// Enable the /-/reload endpoint to reload the config file
+func parse_reload_endpoint (config_reader *ini.File) bool {
+  return config_reader.Section("system").Key("reload_endpoint").MustBool(false)
+}
+
+// Bearer token required by the /-/reload endpoint and the POST to /-/flags,
+// which can't be enabled without it
+func parse_reload_token (config_reader *ini.File) (string, error) {
+  reload_token := config_reader.Section("system").Key("reload_token").String()
+  if parse_reload_endpoint(config_reader) && reload_token == "" {
+    log.Err_msg(error_msg_no_reload_token)
+    return "", errors.New(error_msg_no_reload_token)
+  }
+  return reload_token, nil
+}
+
+// Enable the /debug/cm endpoint with the last raw responses of Cloudera
+// Manager
+func parse_debug_endpoint (config_reader *ini.File) bool {
+  return config_reader.Section("system").Key("debug_endpoint").MustBool(false)
+}
+
+// Bearer token required by the /debug/cm endpoint, which can't be enabled
+// without it
+func parse_debug_token (config_reader *ini.File) (string, error) {
+  debug_token := config_reader.Section("system").Key("debug_token").String()
+  if parse_debug_endpoint(config_reader) && debug_token == "" {
+    log.Err_msg(error_msg_no_debug_token)
+    return "", errors.New(error_msg_no_debug_token)
+  }
+  return debug_token, nil
+}
+
+// Enable the /sd endpoint with the ZooKeeper servers as HTTP SD targets
+func parse_service_discovery (config_reader *ini.File) bool {
+  return config_reader.Section("service_discovery").Key("enabled").MustBool(false)
+}
+
+// Port of the targets published by the /sd endpoint
+func parse_sd_target_port (config_reader *ini.File) (uint, error) {
+  target_port := config_reader.Section("service_discovery").Key("target_port").MustUint(9100)
+  if target_port == 0 || target_port > 65535 {
+    log.Err_msg(error_msg_bad_sd_target_port)
+    return 0, errors.New(error_msg_bad_sd_target_port)
+  }
+  return target_port, nil
+}
+
+// Keep the legacy names and units (ms) of the metrics not reported by
+// Cloudera Manager in base units
+func parse_legacy_metric_names (config_reader *ini.File) bool {
+  return config_reader.Section("system").Key("legacy_metric_names").MustBool(false)
+}
+
+// Shard of the collection of this replica, when it is split between
+// several replicas of the exporter
+func parse_shard (config_reader *ini.File) cl.Shard {
+  return cl.Shard {
+    Index: config_reader.Section("system").Key("shard_index").MustInt(0),
+    Total: config_reader.Section("system").Key("shard_total").MustInt(1),
+  }
+}
+
+// Set the shard of the collection of this replica. With several replicas,
+// the index of the shard is added to the identity labels, so the series
+// tell which replica collected them
+func Set_shard (config *CE_config, shard cl.Shard) error {
+  if shard.Total < 1 || shard.Index < 0 || shard.Index >= shard.Total {
+    log.Err_msg(error_msg_bad_shard)
+    return errors.New(error_msg_bad_shard)
+  }
+  config.Connection.Shard = shard
+  delete(config.Identity_labels, "shard")
+  if shard.Is_enabled() {
+    config.Identity_labels["shard"] = strconv.Itoa(shard.Index)
+  }
+  if err := cl.Validate_series(config.Connection, config.Identity_labels); err != nil {
+    log.Err_msg(err.Error())
+    return err
+  }
+  return nil
+}
+
+// Time the scrapes in progress have to finish on shutdown
+func parse_shutdown_grace_period (config_reader *ini.File) (time.Duration, error) {
+  grace_period, err := time.ParseDuration(config_reader.Section("system").Key("shutdown_grace_period").MustString("25s"))
+  if err != nil || grace_period < 0 {
+    log.Err_msg(error_msg_bad_shutdown_grace_period)
+    return 0, errors.New(error_msg_bad_shutdown_grace_period)
+  }
+  return grace_period, nil
+}
+
+// Interval of the background collections served by /metrics. 0 to collect
+// on every scrape
+func parse_collection_interval (config_reader *ini.File) (time.Duration, error) {
+  interval, err := time.ParseDuration(config_reader.Section("system").Key("collection_interval").MustString("0s"))
+  if err != nil || interval < 0 {
+    log.Err_msg(error_msg_bad_collection_interval)
+    return 0, errors.New(error_msg_bad_collection_interval)
+  }
+  return interval, nil
+}
+
+// Behavior of the TimeSeries without datapoints or with a stale one
+func parse_no_data (config_reader *ini.File) (string, error) {
+  no_data := config_reader.Section("timeseries").Key("no_data").MustString(cl.NO_DATA_OMIT)
+  if !cl.Is_valid_no_data(no_data) {
+    log.Err_msg(error_msg_bad_no_data)
+    return "", errors.New(error_msg_bad_no_data)
+  }
+  return no_data, nil
+}
+
+// Validate the collected values against the semantics of their metrics
+func parse_validate_metrics (config_reader *ini.File) bool {
+  return config_reader.Section("timeseries").Key("validate_metrics").MustBool(true)
+}
+
+// Start the exporter in standby, without querying Cloudera Manager until it
+// is activated
+func parse_standby (config_reader *ini.File) bool {
+  return config_reader.Section("system").Key("standby").MustBool(false)
+}
+
+// Enable the /-/activate and /-/standby endpoints
+func parse_standby_endpoint (config_reader *ini.File) bool {
+  return config_reader.Section("system").Key("standby_endpoint").MustBool(false)
+}
+
+// Bearer token required by the /-/activate and /-/standby endpoints, which
+// can't be enabled without it
+func parse_standby_token (config_reader *ini.File) (string, error) {
+  standby_token := config_reader.Section("system").Key("standby_token").String()
+  if parse_standby_endpoint(config_reader) && standby_token == "" {
+    log.Err_msg(error_msg_no_standby_token)
+    return "", errors.New(error_msg_no_standby_token)
+  }
+  return standby_token, nil
+}
+
+// Attach the timestamps reported by Cloudera Manager to the metrics
+func parse_honor_timestamps (config_reader *ini.File) bool {
+  return config_reader.Section("timeseries").Key("honor_timestamps").MustBool(false)
+}
+
+// Max age of the datapoints. Older ones are dropped. Blank or 0 to keep all
+func parse_max_sample_age (config_reader *ini.File) (time.Duration, error) {
+  max_age := config_reader.Section("timeseries").Key("max_sample_age").String()
+  if max_age == "" {
+    return 0, nil
+  }
+  duration, err := time.ParseDuration(max_age)
+  if err != nil || duration < 0 {
+    log.Err_msg(error_msg_bad_max_sample_age)
+    return 0, errors.New(error_msg_bad_max_sample_age)
+  }
+  return duration, nil
+}
+
+// Settings of the pool of connections to Cloudera Manager
+func parse_http_client_options (config_reader *ini.File) (cm.Http_client_options, error) {
+  section := config_reader.Section("http_client")
+  idle_conn_timeout, err := time.ParseDuration(section.Key("idle_conn_timeout").MustString("90s"))
+  if err != nil {
+    log.Err_msg(error_msg_bad_http_client)
+    return cm.Http_client_options{}, errors.New(error_msg_bad_http_client)
+  }
+  timeout, err := time.ParseDuration(section.Key("timeout").MustString("0s"))
+  if err != nil {
+    log.Err_msg(error_msg_bad_http_client)
+    return cm.Http_client_options{}, errors.New(error_msg_bad_http_client)
+  }
+  var proxy_url *url.URL
+  if proxy := section.Key("proxy_url").String(); proxy != "" {
+    if proxy_url, err = url.Parse(proxy); err != nil || proxy_url.Host == "" {
+      log.Err_msg(error_msg_bad_proxy_url)
+      return cm.Http_client_options{}, errors.New(error_msg_bad_proxy_url)
+    }
+  }
+  rate_limit := section.Key("rate_limit").MustFloat64(0)
+  rate_limit_burst := section.Key("rate_limit_burst").MustInt(10)
+  if rate_limit < 0 || rate_limit_burst < 1 {
+    log.Err_msg(error_msg_bad_rate_limit)
+    return cm.Http_client_options{}, errors.New(error_msg_bad_rate_limit)
+  }
+  max_response_size := section.Key("max_response_size").MustInt64(cm.DEFAULT_MAX_RESPONSE_SIZE)
+  if max_response_size < 0 {
+    log.Err_msg(error_msg_bad_max_response_size)
+    return cm.Http_client_options{}, errors.New(error_msg_bad_max_response_size)
+  }
+  allow_mutations := section.Key("allow_mutations").MustBool(false)
+  if allow_mutations {
+    log.Warn_msg("allow_mutations is set: requests other than GET to Cloudera Manager are allowed")
+  }
+  return cm.Http_client_options {
+    Max_idle_conns_per_host: section.Key("max_idle_conns_per_host").MustInt(16),
+    Idle_conn_timeout: idle_conn_timeout,
+    Timeout: timeout,
+    Http2: section.Key("http2").MustBool(true),
+    Proxy_url: proxy_url,
+    Headers: config_reader.Section("http_headers").KeysHash(),
+    Rate_limit: rate_limit,
+    Rate_limit_burst: rate_limit_burst,
+    Allow_mutations: allow_mutations,
+    Max_response_size: max_response_size,
+  }, nil
+}
+
+// TLS settings of a cluster endpoint. Nil if the section has none
+func parse_cluster_tls_config (section *ini.Section) (*tls.Config, error) {
+  ca_file := section.Key("tls_ca_file").String()
+  server_name := section.Key("tls_server_name").String()
+  insecure := section.Key("tls_insecure_skip_verify").MustBool(false)
+  if ca_file == "" && server_name == "" && !insecure {
+    return nil, nil
+  }
+
+  tls_config := &tls.Config{ServerName: server_name, InsecureSkipVerify: insecure}
+  if ca_file != "" {
+    ca, err := ioutil.ReadFile(ca_file)
+    if err != nil {
+      return nil, err
+    }
+    tls_config.RootCAs = x509.NewCertPool()
+    if !tls_config.RootCAs.AppendCertsFromPEM(ca) {
+      return nil, errors.New(error_msg_bad_cluster_ca_file)
+    }
+  }
+  return tls_config, nil
+}
+
+// Endpoint overrides of the clusters. Each [cluster.<name>] section overrides
+// the base URL, authentication and TLS settings of the requests to the
+// resources of the cluster. The credentials not set are the ones of [user]
+func parse_cluster_endpoints (config_reader *ini.File, http_client *cm.Http_client) (map[string]*cm.Cluster_endpoint, error) {
+  endpoints := make(map[string]*cm.Cluster_endpoint)
+  for _, section := range config_reader.Sections() {
+    if !strings.HasPrefix(section.Name(), CLUSTER_SECTION_PREFIX) {
+      continue
+    }
+    cluster := strings.TrimPrefix(section.Name(), CLUSTER_SECTION_PREFIX)
+    endpoint := &cm.Cluster_endpoint {
+      Auth: cm.Auth {
+        Module: section.Key("auth").MustString(cm.AUTH_BASIC),
+        User: section.Key("username").String(),
+        Passwd: section.Key("password").String(),
+        Token: section.Key("token").String(),
+      },
+      Http_client: http_client,
+    }
+
+    if base_url := section.Key("base_url").String(); base_url != "" {
+      parsed, err := url.Parse(base_url)
+      if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+        log.Err_msg("%s: %s", error_msg_bad_cluster_base_url, cluster)
+        return nil, errors.New(error_msg_bad_cluster_base_url)
+      }
+      endpoint.Base_url = parsed
+    }
+    if !cm.Is_valid_auth_module(endpoint.Auth.Module) {
+      log.Err_msg("%s: %s", error_msg_bad_cluster_auth, cluster)
+      return nil, errors.New(error_msg_bad_cluster_auth)
+    }
+
+    tls_config, err := parse_cluster_tls_config(section)
+    if err != nil {
+      log.Err_msg("%s: %s", error_msg_bad_cluster_ca_file, cluster)
+      return nil, err
+    }
+    if tls_config != nil {
+      endpoint.Http_client = http_client.With_tls(tls_config)
+    }
+    endpoints[cluster] = endpoint
+  }
+  return endpoints, nil
+}
+
+// Push of the metrics to an OpenTelemetry collector. Nil if it is disabled
+func parse_otlp_options (config_reader *ini.File) (*otlp.Options, error) {
+  section := config_reader.Section("otlp")
+  if !section.Key("enabled").MustBool(false) {
+    return nil, nil
+  }
+  endpoint := section.Key("endpoint").String()
+  if endpoint == "" {
+    log.Err_msg(error_msg_no_otlp_endpoint)
+    return nil, errors.New(error_msg_no_otlp_endpoint)
+  }
+  interval, err := time.ParseDuration(section.Key("interval").MustString("60s"))
+  if err != nil || interval <= 0 {
+    log.Err_msg(error_msg_bad_otlp_interval)
+    return nil, errors.New(error_msg_bad_otlp_interval)
+  }
+  timeout, err := time.ParseDuration(section.Key("timeout").MustString("10s"))
+  if err != nil {
+    log.Err_msg(error_msg_bad_otlp_interval)
+    return nil, errors.New(error_msg_bad_otlp_interval)
+  }
+  compression := section.Key("compression").MustString(otlp.COMPRESSION_GZIP)
+  if compression != otlp.COMPRESSION_GZIP && compression != otlp.COMPRESSION_NONE {
+    log.Err_msg(error_msg_bad_otlp_compression)
+    return nil, errors.New(error_msg_bad_otlp_compression)
+  }
+  return &otlp.Options {
+    Endpoint: endpoint,
+    Interval: interval,
+    Timeout: timeout,
+    Headers: config_reader.Section("otlp_headers").KeysHash(),
+    Compression: compression,
+    Resource_attributes: map[string]string {
+      "service.name": section.Key("service_name").MustString("cloudera_exporter"),
+    },
+  }, nil
+}
+
+// Push of the metrics to a Prometheus remote write endpoint. Nil if it is
+// disabled
+func parse_remote_write_options (config_reader *ini.File) (*remote_write.Options, error) {
+  section := config_reader.Section("remote_write")
+  if !section.Key("enabled").MustBool(false) {
+    return nil, nil
+  }
+  write_url, err := url.Parse(section.Key("url").String())
+  if err != nil || write_url.Scheme == "" || write_url.Host == "" {
+    log.Err_msg(error_msg_no_remote_write_url)
+    return nil, errors.New(error_msg_no_remote_write_url)
+  }
+  interval, err := time.ParseDuration(section.Key("interval").MustString("60s"))
+  if err != nil || interval <= 0 {
+    log.Err_msg(error_msg_bad_remote_write_interval)
+    return nil, errors.New(error_msg_bad_remote_write_interval)
+  }
+  timeout, err := time.ParseDuration(section.Key("timeout").MustString("30s"))
+  if err != nil || timeout <= 0 {
+    log.Err_msg(error_msg_bad_remote_write_interval)
+    return nil, errors.New(error_msg_bad_remote_write_interval)
+  }
+  return &remote_write.Options {
+    Url: write_url.String(),
+    Interval: interval,
+    Timeout: timeout,
+    Headers: config_reader.Section("remote_write_headers").KeysHash(),
+  }, nil
+}
+
+// Check of the latest release of the exporter. Nil if it is disabled, so
+// the air-gapped sites make no request
+func parse_update_check_options (config_reader *ini.File) (*Update_check_options, error) {
+  section := config_reader.Section("update_check")
+  if !section.Key("enabled").MustBool(false) {
+    return nil, nil
+  }
+  check_url, err := url.Parse(section.Key("url").String())
+  if err != nil || check_url.Scheme == "" || check_url.Host == "" {
+    log.Err_msg(error_msg_no_update_check_url)
+    return nil, errors.New(error_msg_no_update_check_url)
+  }
+  interval, err := time.ParseDuration(section.Key("interval").MustString("24h"))
+  if err != nil || interval <= 0 {
+    log.Err_msg(error_msg_bad_update_check_interval)
+    return nil, errors.New(error_msg_bad_update_check_interval)
+  }
+  timeout, err := time.ParseDuration(section.Key("timeout").MustString("10s"))
+  if err != nil || timeout <= 0 {
+    log.Err_msg(error_msg_bad_update_check_interval)
+    return nil, errors.New(error_msg_bad_update_check_interval)
+  }
+  return &Update_check_options {
+    Url: check_url.String(),
+    Interval: interval,
+    Timeout: timeout,
+  }, nil
+}
+
+// Mode of the latency metrics of the ZooKeeper servers
+func parse_latency_mode (config_reader *ini.File) (string, error) {
+  latency_mode := config_reader.Section("zookeeper").Key("latency_mode").MustString(cl.LATENCY_MODE_SERIES)
+  if latency_mode != cl.LATENCY_MODE_SERIES && latency_mode != cl.LATENCY_MODE_SUMMARY {
+    log.Err_msg(error_msg_bad_latency_mode)
+    return "", errors.New(error_msg_bad_latency_mode)
+  }
+  return latency_mode, nil
+}
+
+// Upper bounds (ms) of the buckets of the ZooKeeper latency histogram. Empty
+// to not export it
+func parse_latency_buckets (config_reader *ini.File) ([]float64, error) {
+  buckets := []float64{}
+  for _, bound := range config_reader.Section("zookeeper").Key("latency_buckets").Strings(",") {
+    value, err := strconv.ParseFloat(bound, 64)
+    if err != nil || value <= 0 || (len(buckets) > 0 && value <= buckets[len(buckets) - 1]) {
+      log.Err_msg(error_msg_bad_latency_buckets)
+      return nil, errors.New(error_msg_bad_latency_buckets)
+    }
+    buckets = append(buckets, value)
+  }
+  return buckets, nil
+}
+
+// Add the maintenance label to the ZooKeeper health checks
+func parse_maintenance_label (config_reader *ini.File) bool {
+  return config_reader.Section("zookeeper").Key("maintenance_label").MustBool(false)
+}
+
+// Collect the ZooKeeper health rates, deprecated by the health checks
+func parse_health_rates (config_reader *ini.File) bool {
+  return config_reader.Section("zookeeper").Key("health_rates").MustBool(true)
+}
+
+// Interval between the snapshots of the ZooKeeper services configuration
+func parse_config_snapshot_interval (config_reader *ini.File) (time.Duration, error) {
+  interval, err := time.ParseDuration(config_reader.Section("zookeeper").Key("config_snapshot_interval").MustString("5m"))
+  if err != nil || interval < 0 {
+    log.Err_msg(error_msg_bad_config_snapshot_interval)
+    return 0, errors.New(error_msg_bad_config_snapshot_interval)
+  }
+  return interval, nil
+}
+
+// Age of the oldest ZooKeeper events counted by the first scrape
+func parse_events_lookback (config_reader *ini.File) (time.Duration, error) {
+  lookback, err := time.ParseDuration(config_reader.Section("zookeeper").Key("events_lookback").MustString("10m"))
+  if err != nil || lookback < 0 {
+    log.Err_msg(error_msg_bad_events_lookback)
+    return 0, errors.New(error_msg_bad_events_lookback)
+  }
+  return lookback, nil
+}
+
+// Path prefixes of the znodes counted by the znodes module
+func parse_znode_prefixes (config_reader *ini.File) ([]string, error) {
+  prefixes := []string{}
+  for _, prefix := range config_reader.Section("zookeeper").Key("znode_prefixes").Strings(",") {
+    if !strings.HasPrefix(prefix, "/") || (prefix != "/" && strings.HasSuffix(prefix, "/")) || strings.Contains(prefix, "//") {
+      log.Err_msg("%s: %s", error_msg_bad_znode_prefixes, prefix)
+      return nil, errors.New(error_msg_bad_znode_prefixes)
+    }
+    prefixes = append(prefixes, prefix)
+  }
+  return prefixes, nil
+}
+
+// Interval between the walks of the znode tree of the znodes module
+func parse_znode_walk_interval (config_reader *ini.File) (time.Duration, error) {
+  interval, err := time.ParseDuration(config_reader.Section("zookeeper").Key("znode_walk_interval").MustString("15m"))
+  if err != nil || interval < 0 {
+    log.Err_msg(error_msg_bad_znode_walk)
+    return 0, errors.New(error_msg_bad_znode_walk)
+  }
+  return interval, nil
+}
+
+// Client port of the ZooKeeper servers, read by the znodes module
+func parse_znode_client_port (config_reader *ini.File) (uint, error) {
+  port := config_reader.Section("zookeeper").Key("znode_client_port").MustUint(2181)
+  if port == 0 || port > 65535 {
+    log.Err_msg(error_msg_bad_znode_walk)
+    return 0, errors.New(error_msg_bad_znode_walk)
+  }
+  return port, nil
+}
+
+// Max number of role-level series of a metric before it is aggregated by
+// service. 0 disables the backoff
+func parse_max_role_series (config_reader *ini.File) int {
+  return config_reader.Section("zookeeper").Key("max_role_series").MustInt(0)
+}
+
 func parse_num_procs (config_reader *ini.File) (int, error) {
   num_procs := config_reader.Section("system").Key("num_procs").MustInt(0)
   if num_procs == 0 {
@@ -185,7 +874,104 @@ func parse_log_level (config_reader *ini.File) (int, error) {
 }
 
 
-func Parse_config(config interface{}) (*CE_config, error) {
+// Metrics derived from the collected ones. Each key of the [derived_metrics]
+// section is the name of the metric and its value the expression
+func parse_derived_metrics (config_reader *ini.File) ([]cl.Derived_metric, error) {
+  derived_metrics := []cl.Derived_metric{}
+  for _, key := range config_reader.Section("derived_metrics").Keys() {
+    derived, err := cl.New_derived_metric(key.Name(), key.String())
+    if err != nil {
+      log.Err_msg("Can't compile the derived metric %s: %s", key.Name(), err)
+      return nil, err
+    }
+    derived_metrics = append(derived_metrics, derived)
+  }
+  return derived_metrics, nil
+}
+
+
+// Metrics defined with a raw tsquery. Each [custom_metric.<name>] section
+// defines the metric kbdi_custom_<name> with its help, labels and query
+func parse_custom_metrics (config_reader *ini.File) ([]cl.Custom_metric, error) {
+  custom_metrics := []cl.Custom_metric{}
+  for _, section := range config_reader.Sections() {
+    if !strings.HasPrefix(section.Name(), CUSTOM_METRIC_SECTION_PREFIX) {
+      continue
+    }
+    name := strings.TrimPrefix(section.Name(), CUSTOM_METRIC_SECTION_PREFIX)
+    custom, err := cl.New_custom_metric(
+      name,
+      section.Key("help").String(),
+      section.Key("labels").String(),
+      section.Key("query").String(),
+    )
+    if err != nil {
+      log.Err_msg("Can't compile the custom metric %s: %s", name, err)
+      return nil, err
+    }
+    custom_metrics = append(custom_metrics, custom)
+  }
+  return custom_metrics, nil
+}
+
+
+// Entity attributes of the TimeSeries metadata added as labels. Each key of
+// the [entity_labels] section is an attribute and its value the label name
+func parse_entity_labels (config_reader *ini.File) ([]cl.Entity_label, error) {
+  entity_labels := []cl.Entity_label{}
+  for _, key := range config_reader.Section("entity_labels").Keys() {
+    entity_label, err := cl.New_entity_label(key.Name(), key.String())
+    if err != nil {
+      log.Err_msg("Can't parse the entity labels: %s", err)
+      return nil, err
+    }
+    entity_labels = append(entity_labels, entity_label)
+  }
+  return entity_labels, nil
+}
+
+
+// Identity labels of this exporter instance. Used to deduplicate HA exporter
+// pairs. If drop_identity_labels is set, the labels are not exposed even if
+// they have a value
+func parse_identity_labels (config_reader *ini.File) map[string]string {
+  identity_labels := make(map[string]string)
+  if config_reader.Section("system").Key("drop_identity_labels").MustBool(false) {
+    log.Warn_msg("Dropping the identity labels of the exporter")
+    return identity_labels
+  }
+  for _, label := range []string{"replica", "instance_id"} {
+    if value := config_reader.Section("system").Key(label).String(); value != "" {
+      identity_labels[label] = value
+    }
+  }
+  return identity_labels
+}
+
+// Constant labels added to every exposed metric (environment, datacenter,
+// team...). Each key of the [const_labels] section is a label and its value
+// the value of the label. The blank ones are not added
+func parse_const_labels (config_reader *ini.File, identity_labels map[string]string) (map[string]string, error) {
+  const_labels := make(map[string]string)
+  for _, key := range config_reader.Section("const_labels").Keys() {
+    name := key.Name()
+    if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+      log.Err_msg("%s: %s", error_msg_bad_const_label, name)
+      return nil, errors.New(error_msg_bad_const_label)
+    }
+    if _, ok := identity_labels[name]; ok {
+      log.Err_msg("%s: %s", error_msg_const_identity_label, name)
+      return nil, errors.New(error_msg_const_identity_label)
+    }
+    if key.String() != "" {
+      const_labels[name] = key.String()
+    }
+  }
+  return const_labels, nil
+}
+
+
+func Parse_config(config interface{}, secret_files Secret_files) (*CE_config, error) {
   var err error
 
   opts := ini.LoadOptions {
@@ -200,19 +986,35 @@ func Parse_config(config interface{}) (*CE_config, error) {
   // Parse File Options
 
   // Username
-  user, err := parse_user(cfg)
+  vault_secret, err := parse_vault_secret(cfg)
+  if err != nil {
+    return nil, err
+  }
+  user_secret, err := parse_user(cfg, secret_files.User_file, vault_secret)
   if err != nil {
     log.Err_msg("Can't parse user field")
     return nil, err
   }
 
   // Password
-  password, err := parse_passwd(cfg)
+  password_secret, err := parse_passwd(cfg, secret_files.Password_file, vault_secret)
   if err != nil {
     log.Err_msg("Can't parse password field")
     return nil, err
   }
 
+  // Read the credentials from their secrets
+  credentials, err := cm.New_credentials(user_secret, password_secret)
+  if err != nil {
+    log.Err_msg("Can't read the credentials: %s", err)
+    return nil, err
+  }
+  user, password := credentials.Get()
+  secrets_refresh_interval, err := parse_secrets_refresh_interval(cfg)
+  if err != nil {
+    return nil, err
+  }
+
   // Cloudera Manager entrypoint
   host, err := parse_host(cfg)
   if err != nil {
@@ -239,7 +1041,86 @@ func Parse_config(config interface{}) (*CE_config, error) {
   impala_module_flag := parse_impala_module_flag (cfg)
   hdfs_module_flag := parse_hdfs_module_flag (cfg)
   yarn_module_flag := parse_yarn_module_flag (cfg)
-  zookeeper_module_flag := parse_zookeeper_module_flag(cfg)
+  max_role_series := parse_max_role_series(cfg)
+  latency_mode, err := parse_latency_mode(cfg)
+  if err != nil {
+    return nil, err
+  }
+  latency_buckets, err := parse_latency_buckets(cfg)
+  if err != nil {
+    return nil, err
+  }
+  config_snapshot_interval, err := parse_config_snapshot_interval(cfg)
+  if err != nil {
+    return nil, err
+  }
+  events_lookback, err := parse_events_lookback(cfg)
+  if err != nil {
+    return nil, err
+  }
+  znode_prefixes, err := parse_znode_prefixes(cfg)
+  if err != nil {
+    return nil, err
+  }
+  znode_walk_interval, err := parse_znode_walk_interval(cfg)
+  if err != nil {
+    return nil, err
+  }
+  znode_client_port, err := parse_znode_client_port(cfg)
+  if err != nil {
+    return nil, err
+  }
+  no_data, err := parse_no_data(cfg)
+  if err != nil {
+    return nil, err
+  }
+
+  // Derived metrics
+  derived_metrics, err := parse_derived_metrics(cfg)
+  if err != nil {
+    return nil, err
+  }
+
+  // Custom metrics
+  custom_metrics, err := parse_custom_metrics(cfg)
+  if err != nil {
+    return nil, err
+  }
+  entity_labels, err := parse_entity_labels(cfg)
+  if err != nil {
+    return nil, err
+  }
+
+  // TimeSeries Queries window and rollup
+  timeseries_window, err := parse_timeseries_window(cfg)
+  if err != nil {
+    return nil, err
+  }
+  desired_rollup, must_use_desired_rollup, err := parse_desired_rollup(cfg)
+  if err != nil {
+    return nil, err
+  }
+  rollup_statistic, err := parse_rollup_statistic(cfg)
+  if err != nil {
+    return nil, err
+  }
+  honor_timestamps := parse_honor_timestamps(cfg)
+
+  // Connections to Cloudera Manager
+  http_client_options, err := parse_http_client_options(cfg)
+  if err != nil {
+    return nil, err
+  }
+  http_client := cm.New_http_client(http_client_options)
+  cluster_endpoints, err := parse_cluster_endpoints(cfg, http_client)
+  if err != nil {
+    return nil, err
+  }
+  max_sample_age, err := parse_max_sample_age(cfg)
+  if err != nil {
+    return nil, err
+  }
+  timeout_retries := parse_timeout_retries(cfg)
 
 
 
@@ -264,30 +1145,140 @@ func Parse_config(config interface{}) (*CE_config, error) {
     log.Err_msg("Can't parse log_level field")
     return nil, err
   }
+  identity_labels := parse_identity_labels(cfg)
+  const_labels, err := parse_const_labels(cfg, identity_labels)
+  if err != nil {
+    return nil, err
+  }
+  standby := parse_standby(cfg)
+  standby_token, err := parse_standby_token(cfg)
+  if err != nil {
+    return nil, err
+  }
+  feature_flags, err := parse_feature_flags(cfg)
+  if err != nil {
+    return nil, err
+  }
+  otlp_options, err := parse_otlp_options(cfg)
+  if err != nil {
+    return nil, err
+  }
+  remote_write_options, err := parse_remote_write_options(cfg)
+  if err != nil {
+    return nil, err
+  }
+  sd_target_port, err := parse_sd_target_port(cfg)
+  if err != nil {
+    return nil, err
+  }
+  collection_interval, err := parse_collection_interval(cfg)
+  if err != nil {
+    return nil, err
+  }
+  update_check_options, err := parse_update_check_options(cfg)
+  if err != nil {
+    return nil, err
+  }
+  shutdown_grace_period, err := parse_shutdown_grace_period(cfg)
+  if err != nil {
+    return nil, err
+  }
+  debug_token, err := parse_debug_token(cfg)
+  if err != nil {
+    return nil, err
+  }
+  reload_token, err := parse_reload_token(cfg)
+  if err != nil {
+    return nil, err
+  }
+
+  // Modules
+  collectors_flags := map [cl.Scraper] bool {
+    cl.ScrapeStatus{}: global_status_module_flag,
+    cl.ScrapeHost{}: host_module_flag,
+    cl.ScrapeImpalaMetrics{}: impala_module_flag,
+    cl.ScrapeHDFS{}: hdfs_module_flag,
+    cl.ScrapeYARNMetrics{}: yarn_module_flag,
+    cl.ScrapeCustomMetrics{}: len(custom_metrics) > 0,
+  }
+  parse_service_collector_flags(cfg, collectors_flags)
 
+  // Series that would collide at scrape time
+  series_config := cl.Collector_connection_data {
+    Custom_metrics: custom_metrics,
+    Derived_metrics: derived_metrics,
+    Entity_labels: entity_labels,
+    No_data: no_data,
+    Legacy_metric_names: parse_legacy_metric_names(cfg),
+  }
+  if err := cl.Validate_series(series_config, identity_labels); err != nil {
+    log.Err_msg(err.Error())
+    return nil, err
+  }
 
-  return &CE_config {
+
+  ce_config := &CE_config {
     num_procs,
     cl.Collector_connection_data {
-      host,
-      port,
-      api_version,
-      user,
-      password,
-    },
-    CE_collectors_flags{
-      map [cl.Scraper] bool {
-        cl.ScrapeStatus{}: global_status_module_flag,
-        cl.ScrapeHost{}: host_module_flag,
-        cl.ScrapeImpalaMetrics{}: impala_module_flag,
-        cl.ScrapeHDFS{}: hdfs_module_flag,
-        cl.ScrapeYARNMetrics{}: yarn_module_flag,
-        cl.ScrapeZookeeperMetrics{}: zookeeper_module_flag,
-      },
+      Host: host,
+      Port: port,
+      Api_version: api_version,
+      Api_version_pinned: api_version != "",
+      User: user,
+      Passwd: password,
+      Credentials: credentials,
+      Max_role_series: max_role_series,
+      Latency_mode: latency_mode,
+      Latency_buckets: latency_buckets,
+      Config_snapshot_interval: config_snapshot_interval,
+      Maintenance_label: parse_maintenance_label(cfg),
+      Health_rates: parse_health_rates(cfg),
+      Events_lookback: events_lookback,
+      Znode_prefixes: znode_prefixes,
+      Znode_walk_interval: znode_walk_interval,
+      Znode_client_port: znode_client_port,
+      Derived_metrics: derived_metrics,
+      Custom_metrics: custom_metrics,
+      Entity_labels: entity_labels,
+      Timeseries_window: timeseries_window,
+      Desired_rollup: desired_rollup,
+      Must_use_desired_rollup: must_use_desired_rollup,
+      Rollup_statistic: rollup_statistic,
+      Honor_timestamps: honor_timestamps,
+      Max_sample_age: max_sample_age,
+      Timeout_retries: timeout_retries,
+      Validate_metrics: parse_validate_metrics(cfg),
+      No_data: no_data,
+      Legacy_metric_names: parse_legacy_metric_names(cfg),
+      Cm_debug: parse_debug_endpoint(cfg),
+      Http_client: http_client,
+      Cluster_endpoints: cluster_endpoints,
     },
+    CE_collectors_flags{collectors_flags},
   deploy_ip,
   deploy_port,
   log_level,
-  },
-  nil
+  identity_labels,
+  standby,
+  parse_standby_endpoint(cfg),
+  standby_token,
+  otlp_options,
+  secrets_refresh_interval,
+  feature_flags,
+  parse_reload_endpoint(cfg),
+  reload_token,
+  parse_service_discovery(cfg),
+  sd_target_port,
+  collection_interval,
+  update_check_options,
+  shutdown_grace_period,
+  parse_debug_endpoint(cfg),
+  debug_token,
+  const_labels,
+  remote_write_options,
+  }
+  if err := Set_shard(ce_config, parse_shard(cfg)); err != nil {
+    return nil, err
+  }
+  return ce_config, nil
 }
@@ -0,0 +1,84 @@
+/*
+ *
+ * title           :const_labels.go
+ * description     :Constant labels of the config file added to every exposed
+ *                  metric, as the external labels of Prometheus
+ * author          :Enes Erdoğan
+ * date            :2025/11/03
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "sort"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Gatherer that adds the constant labels to the gathered metrics
+type const_labels_gatherer struct {
+  prometheus.Gatherer
+  labels map[string]string
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the metric families with the constant labels the metrics don't
+// have already. The families are copied, as the snapshot of the background
+// collection is shared by the scrapes
+func add_const_labels(families []*dto.MetricFamily, labels map[string]string) []*dto.MetricFamily {
+  if len(labels) == 0 {
+    return families
+  }
+  labeled := make([]*dto.MetricFamily, 0, len(families))
+  for _, family := range families {
+    metrics := make([]*dto.Metric, 0, len(family.GetMetric()))
+    for _, metric := range family.GetMetric() {
+      present := make(map[string]bool, len(metric.GetLabel()))
+      for _, label := range metric.GetLabel() {
+        present[label.GetName()] = true
+      }
+      pairs := append([]*dto.LabelPair{}, metric.GetLabel()...)
+      for name, value := range labels {
+        if !present[name] {
+          name, value := name, value
+          pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+        }
+      }
+      sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+
+      copied := *metric
+      copied.Label = pairs
+      metrics = append(metrics, &copied)
+    }
+    labeled = append(labeled, &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type, Metric: metrics})
+  }
+  return labeled
+}
+
+
+// Gather implements prometheus.Gatherer.
+func (g const_labels_gatherer) Gather() ([]*dto.MetricFamily, error) {
+  families, err := g.Gatherer.Gather()
+  return add_const_labels(families, g.labels), err
+}
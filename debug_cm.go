@@ -0,0 +1,74 @@
+/*
+ *
+ * title           :debug_cm.go
+ * description     :/debug/cm endpoint with the TimeSeries queries built, the
+ *                  last raw responses of Cloudera Manager and their timing
+ * author          :Enes Erdoğan
+ * date            :2025/10/20
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "crypto/subtle"
+  "encoding/json"
+  "net/http"
+  "strings"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Report of the /debug/cm endpoint
+type cm_debug_report struct {
+  Queries []cl.Cm_debug_query `json:"queries"`
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Create and returns a Handler that reports the last execution of each
+// TimeSeries query, only if the endpoint is enabled and with its token as a
+// bearer token. The query and collector parameters keep the queries that
+// contain the given text and the ones of the given collector
+func newCmDebugHandler() http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    current := get_serving_state().config
+    if !current.Debug_endpoint {
+      http.NotFound(w, r)
+      return
+    }
+    token := []byte("Bearer " + current.Debug_token)
+    if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), token) != 1 {
+      http.Error(w, "Unauthorized", http.StatusUnauthorized)
+      return
+    }
+
+    query, collector := r.URL.Query().Get("query"), r.URL.Query().Get("collector")
+    report := cm_debug_report{Queries: []cl.Cm_debug_query{}}
+    for _, entry := range cl.Get_cm_debug_queries() {
+      if strings.Contains(entry.Query, query) && (collector == "" || entry.Collector == collector) {
+        report.Queries = append(report.Queries, entry)
+      }
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(report)
+  }
+}
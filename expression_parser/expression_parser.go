@@ -0,0 +1,354 @@
+/*
+ *
+ * title           :expression_parser.go
+ * description     :Small arithmetic expression language for the derived metrics
+ * author          :Enes Erdoğan
+ * date            :2025/02/10
+ * version         :1.0
+ * notes           :Grammar:
+ *                    expr   := term (("+" | "-") term)*
+ *                    term   := factor (("*" | "/") factor)*
+ *                    factor := ("+" | "-") factor | number | variable
+ *                            | function "(" expr ("," expr)* ")" | "(" expr ")"
+ *                  Variables are metric names. Functions: abs, min, max
+ *
+ */
+package expression_parser
+
+/*
+ * Dependencies
+ */
+import (
+  // Go Default libraries
+  "errors"
+  "fmt"
+  "math"
+  "sort"
+  "strconv"
+  "unicode"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Compiled expression
+type Expression struct {
+  source string
+  root node
+  variables []string
+}
+
+// Node of the expression tree
+type node interface {
+  eval(values map[string]float64) (float64, error)
+}
+
+type number_node float64
+
+type variable_node string
+
+type unary_node struct {
+  operand node
+}
+
+type binary_node struct {
+  operator byte
+  left node
+  right node
+}
+
+type function_node struct {
+  name string
+  args []node
+}
+
+// Lexer and parser state
+type parser struct {
+  input []rune
+  pos int
+  variables map[string]bool
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Functions of the language and their number of arguments (-1 for variadic)
+var functions = map[string]int {
+  "abs": 1,
+  "min": -1,
+  "max": -1,
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Parse and compile an expression
+func Parse_expression(source string) (*Expression, error) {
+  p := &parser{input: []rune(source), variables: make(map[string]bool)}
+  root, err := p.parse_expr()
+  if err != nil {
+    return nil, fmt.Errorf("Invalid expression %q: %s", source, err)
+  }
+  p.skip_spaces()
+  if p.pos < len(p.input) {
+    return nil, fmt.Errorf("Invalid expression %q: unexpected %q at position %d", source, string(p.input[p.pos]), p.pos)
+  }
+
+  variables := make([]string, 0, len(p.variables))
+  for variable := range p.variables {
+    variables = append(variables, variable)
+  }
+  sort.Strings(variables)
+  return &Expression{source, root, variables}, nil
+}
+
+
+// Returns the source code of the expression
+func (e *Expression) String() string {
+  return e.source
+}
+
+
+// Returns the sorted list of variables used by the expression
+func (e *Expression) Variables() []string {
+  return e.variables
+}
+
+
+// Evaluate the expression with the given variable values
+func (e *Expression) Eval(values map[string]float64) (float64, error) {
+  return e.root.eval(values)
+}
+
+
+func (n number_node) eval(values map[string]float64) (float64, error) {
+  return float64(n), nil
+}
+
+
+func (n variable_node) eval(values map[string]float64) (float64, error) {
+  value, ok := values[string(n)]
+  if !ok {
+    return 0, fmt.Errorf("No value for %s", string(n))
+  }
+  return value, nil
+}
+
+
+func (n unary_node) eval(values map[string]float64) (float64, error) {
+  value, err := n.operand.eval(values)
+  return -value, err
+}
+
+
+func (n binary_node) eval(values map[string]float64) (float64, error) {
+  left, err := n.left.eval(values)
+  if err != nil {
+    return 0, err
+  }
+  right, err := n.right.eval(values)
+  if err != nil {
+    return 0, err
+  }
+  switch n.operator {
+  case '+':
+    return left + right, nil
+  case '-':
+    return left - right, nil
+  case '*':
+    return left * right, nil
+  default:
+    if right == 0 {
+      return 0, errors.New("Division by zero")
+    }
+    return left / right, nil
+  }
+}
+
+
+func (n function_node) eval(values map[string]float64) (float64, error) {
+  args := make([]float64, len(n.args))
+  for i, arg := range n.args {
+    value, err := arg.eval(values)
+    if err != nil {
+      return 0, err
+    }
+    args[i] = value
+  }
+  switch n.name {
+  case "abs":
+    return math.Abs(args[0]), nil
+  case "min":
+    result := args[0]
+    for _, value := range args[1:] {
+      result = math.Min(result, value)
+    }
+    return result, nil
+  default:
+    result := args[0]
+    for _, value := range args[1:] {
+      result = math.Max(result, value)
+    }
+    return result, nil
+  }
+}
+
+
+func (p *parser) skip_spaces() {
+  for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+    p.pos++
+  }
+}
+
+
+// Returns the next non space character without consuming it
+func (p *parser) peek() rune {
+  p.skip_spaces()
+  if p.pos >= len(p.input) {
+    return 0
+  }
+  return p.input[p.pos]
+}
+
+
+func (p *parser) parse_expr() (node, error) {
+  left, err := p.parse_term()
+  if err != nil {
+    return nil, err
+  }
+  for operator := p.peek(); operator == '+' || operator == '-'; operator = p.peek() {
+    p.pos++
+    right, err := p.parse_term()
+    if err != nil {
+      return nil, err
+    }
+    left = binary_node{byte(operator), left, right}
+  }
+  return left, nil
+}
+
+
+func (p *parser) parse_term() (node, error) {
+  left, err := p.parse_factor()
+  if err != nil {
+    return nil, err
+  }
+  for operator := p.peek(); operator == '*' || operator == '/'; operator = p.peek() {
+    p.pos++
+    right, err := p.parse_factor()
+    if err != nil {
+      return nil, err
+    }
+    left = binary_node{byte(operator), left, right}
+  }
+  return left, nil
+}
+
+
+func (p *parser) parse_factor() (node, error) {
+  c := p.peek()
+  switch {
+  case c == 0:
+    return nil, errors.New("unexpected end of expression")
+  case c == '+':
+    p.pos++
+    return p.parse_factor()
+  case c == '-':
+    p.pos++
+    operand, err := p.parse_factor()
+    if err != nil {
+      return nil, err
+    }
+    return unary_node{operand}, nil
+  case c == '(':
+    p.pos++
+    inner, err := p.parse_expr()
+    if err != nil {
+      return nil, err
+    }
+    if p.peek() != ')' {
+      return nil, fmt.Errorf("missing ')' at position %d", p.pos)
+    }
+    p.pos++
+    return inner, nil
+  case unicode.IsDigit(c) || c == '.':
+    return p.parse_number()
+  case unicode.IsLetter(c) || c == '_' || c == ':':
+    return p.parse_identifier()
+  default:
+    return nil, fmt.Errorf("unexpected %q at position %d", string(c), p.pos)
+  }
+}
+
+
+func (p *parser) parse_number() (node, error) {
+  start := p.pos
+  for p.pos < len(p.input) {
+    c := p.input[p.pos]
+    exponent_sign := (c == '+' || c == '-') && p.pos > start && (p.input[p.pos-1] == 'e' || p.input[p.pos-1] == 'E')
+    if !unicode.IsDigit(c) && c != '.' && c != 'e' && c != 'E' && !exponent_sign {
+      break
+    }
+    p.pos++
+  }
+  value, err := strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+  if err != nil {
+    return nil, fmt.Errorf("invalid number %q", string(p.input[start:p.pos]))
+  }
+  return number_node(value), nil
+}
+
+
+func (p *parser) parse_identifier() (node, error) {
+  start := p.pos
+  for p.pos < len(p.input) {
+    c := p.input[p.pos]
+    if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != ':' {
+      break
+    }
+    p.pos++
+  }
+  name := string(p.input[start:p.pos])
+
+  // Variable
+  if p.peek() != '(' {
+    p.variables[name] = true
+    return variable_node(name), nil
+  }
+
+  // Function call
+  num_args, ok := functions[name]
+  if !ok {
+    return nil, fmt.Errorf("unknown function %s", name)
+  }
+  p.pos++
+  args := []node{}
+  for {
+    arg, err := p.parse_expr()
+    if err != nil {
+      return nil, err
+    }
+    args = append(args, arg)
+    if p.peek() != ',' {
+      break
+    }
+    p.pos++
+  }
+  if p.peek() != ')' {
+    return nil, fmt.Errorf("missing ')' at position %d", p.pos)
+  }
+  p.pos++
+  if num_args >= 0 && len(args) != num_args {
+    return nil, fmt.Errorf("function %s takes %d arguments, %d given", name, num_args, len(args))
+  }
+  return function_node{name, args}, nil
+}
@@ -0,0 +1,154 @@
+/*
+ *
+ * title           :fixtures_command.go
+ * description     :Fixtures command: generates promtool rule test fixtures
+ *                  from a live collection
+ * author          :Enes Erdoğan
+ * date            :2025/03/17
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+  cp "keedio/cloudera_exporter/config_parser"
+
+  // Go external libraries
+  "gopkg.in/alecthomas/kingpin.v2"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  dto "github.com/prometheus/client_model/go"
+  "github.com/prometheus/common/model"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Command and flags of the fixtures command
+var (
+  fixtures_command = kingpin.Command("fixtures", "Collect the metrics once and print them as a promtool rules test file.")
+  fixtures_rule_files = fixtures_command.Flag("rule-file", "Rule file to test. Repeat for several files.").Default("rules.yml").Strings()
+  fixtures_interval = fixtures_command.Flag("interval", "Interval between the samples of the input series.").Default("1m").Duration()
+  fixtures_samples = fixtures_command.Flag("samples", "Repetitions of the collected value in each input series (promtool xN notation).").Default("10").Int()
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Run the enabled scrapers once and gather the collected metrics, with the
+// constant labels
+func collect_once(ctx context.Context, config *cp.CE_config, metrics cl.Metrics, scrapers []cl.Scraper) ([]*dto.MetricFamily, error) {
+  registry := prometheus.NewRegistry()
+  if err := prometheus.WrapRegistererWith(config.Identity_labels, registry).Register(cl.New(ctx, config.Connection, metrics, scrapers)); err != nil {
+    return nil, err
+  }
+  families, err := registry.Gather()
+  return add_const_labels(families, config.Const_labels), err
+}
+
+
+// Returns the PromQL series selector of a metric (name{label="value",...})
+func series_selector(name string, labels []*dto.LabelPair) string {
+  pairs := make([]string, 0, len(labels))
+  for _, label := range labels {
+    pairs = append(pairs, fmt.Sprintf("%s=%s", label.GetName(), strconv.Quote(label.GetValue())))
+  }
+  sort.Strings(pairs)
+  return fmt.Sprintf("%s{%s}", name, strings.Join(pairs, ","))
+}
+
+
+// Returns the value of a gauge, counter or untyped metric
+func sample_value(metric *dto.Metric) (float64, bool) {
+  switch {
+  case metric.Gauge != nil:
+    return metric.Gauge.GetValue(), true
+  case metric.Counter != nil:
+    return metric.Counter.GetValue(), true
+  case metric.Untyped != nil:
+    return metric.Untyped.GetValue(), true
+  }
+  return 0, false
+}
+
+
+// Quote a string for YAML
+func yaml_quote(value string) string {
+  return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+
+// Print the collected metrics as the input series of a promtool test file.
+// Each series repeats the collected value, as promtool expanding notation
+func write_promtool_fixtures(output io.Writer, families []*dto.MetricFamily, rule_files []string, interval time.Duration, samples int) error {
+  series := []string{}
+  for _, family := range families {
+    for _, metric := range family.Metric {
+      value, ok := sample_value(metric)
+      if !ok {
+        continue
+      }
+      series = append(series, fmt.Sprintf("      - series: %s\n        values: %s\n",
+        yaml_quote(series_selector(family.GetName(), metric.Label)),
+        yaml_quote(fmt.Sprintf("%s+0x%d", strconv.FormatFloat(value, 'g', -1, 64), samples))))
+    }
+  }
+  sort.Strings(series)
+
+  fmt.Fprintf(output, "# Generated by cloudera_exporter fixtures on %s\n", time.Now().UTC().Format(time.RFC3339))
+  fmt.Fprintln(output, "rule_files:")
+  for _, rule_file := range rule_files {
+    fmt.Fprintf(output, "  - %s\n", yaml_quote(rule_file))
+  }
+  fmt.Fprintf(output, "evaluation_interval: %s\n", model.Duration(interval))
+  fmt.Fprintln(output, "tests:")
+  fmt.Fprintf(output, "  - interval: %s\n", model.Duration(interval))
+  fmt.Fprintln(output, "    input_series:")
+  for _, s := range series {
+    if _, err := io.WriteString(output, s); err != nil {
+      return err
+    }
+  }
+  fmt.Fprintln(output, "    # Add the expected alerts and expressions of the rules under test")
+  fmt.Fprintln(output, "    alert_rule_tests: []")
+  _, err := fmt.Fprintln(output, "    promql_expr_test: []")
+  return err
+}
+
+
+// Print the fixtures on the standard output
+func fixtures_main(scrapers []cl.Scraper) {
+  families, err := collect_once(context.Background(), config, cl.NewMetrics(), scrapers)
+  if err == nil {
+    err = write_promtool_fixtures(os.Stdout, families, *fixtures_rule_files, *fixtures_interval, *fixtures_samples)
+  }
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
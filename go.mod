@@ -3,7 +3,10 @@ module keedio/cloudera_exporter
 go 1.12
 
 require (
+	github.com/go-zookeeper/zk v1.0.3
+	github.com/golang/snappy v0.0.4
 	github.com/prometheus/client_golang v0.9.2
+	github.com/prometheus/client_model v0.0.0-20180712105110-5c3871d89910
 	github.com/prometheus/common v0.3.0
 	github.com/tidwall/gjson v1.2.1
 	github.com/tidwall/match v1.0.1 // indirect
@@ -0,0 +1,119 @@
+/*
+ *
+ * title           :human_format.go
+ * description     :Human-readable formatting of the values printed by the
+ *                  commands: durations, bytes and SI suffixes
+ * author          :Enes Erdoğan
+ * date            :2025/09/15
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "math"
+  "strconv"
+  "time"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Suffixes of the multiples of 1000, and of 1024 for the bytes
+var si_suffixes = []string{"", "k", "M", "G", "T", "P", "E"}
+var iec_suffixes = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the value with 3 significant digits at most. The integers of 4
+// digits (bytes below 1 KiB) are not written in exponent form
+func format_significant(value float64) string {
+  if math.Abs(value) >= 1000 {
+    return strconv.FormatFloat(value, 'f', 0, 64)
+  }
+  return strconv.FormatFloat(value, 'g', 3, 64)
+}
+
+
+// Returns the value rounded to 3 significant digits
+func round_significant(value float64) float64 {
+  rounded, _ := strconv.ParseFloat(format_significant(value), 64)
+  return rounded
+}
+
+
+// Returns the value as it is, with every digit
+func format_raw(value float64) string {
+  return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+
+// Returns the value scaled to the largest multiple of base below it, with
+// the suffix of the multiple (e.g. 12300 is 12.3k)
+func format_scaled(value float64, base float64, suffixes []string, separator string) string {
+  scaled := math.Abs(value)
+  index := 0
+  for round_significant(scaled) >= base && index < len(suffixes) - 1 {
+    scaled /= base
+    index++
+  }
+  if value < 0 {
+    scaled = -scaled
+  }
+  return format_significant(scaled) + separator + suffixes[index]
+}
+
+
+// Returns the duration in the largest unit below it (e.g. 350ms, 2.5s,
+// 3m20s)
+func format_duration(seconds float64) string {
+  sign := ""
+  if seconds < 0 {
+    sign, seconds = "-", -seconds
+  }
+  // The unit is chosen by the rounded value, so 999.9ms is 1s
+  rounded := round_significant(seconds)
+  switch {
+  case rounded == 0:
+    return "0s"
+  case rounded < 1e-3:
+    return sign + format_significant(seconds * 1e6) + "µs"
+  case rounded < 1:
+    return sign + format_significant(seconds * 1e3) + "ms"
+  case rounded < 60:
+    return sign + format_significant(seconds) + "s"
+  }
+  return sign + time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+
+// Returns the value in a human-readable form for its unit (ms, seconds,
+// bytes or none). NaN and infinite values are returned as they are
+func format_human(value float64, unit string) string {
+  if math.IsNaN(value) || math.IsInf(value, 0) {
+    return format_raw(value)
+  }
+  switch unit {
+  case "ms":
+    return format_duration(value / 1e3)
+  case "seconds":
+    return format_duration(value)
+  case "bytes":
+    return format_scaled(value, 1024, iec_suffixes, " ")
+  }
+  return format_scaled(value, 1000, si_suffixes, "")
+}
@@ -0,0 +1,563 @@
+/*
+ *
+ * title           :internal/cmmock/cmmock.go
+ * description     :Fake Cloudera Manager API server, to run the exporter
+ *                  against a known topology, TimeSeries and failures
+ * author          :Enes Erdoğan
+ * date            :2025/08/18
+ * version         :1.0
+ *
+ */
+package cmmock
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "encoding/json"
+  "fmt"
+  "net"
+  "net/http"
+  "net/http/httptest"
+  "net/url"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+  "unicode"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Cluster managed by the fake Cloudera Manager
+type Cluster struct {
+  Name string
+  Display_name string
+  Full_version string
+  Maintenance_mode bool
+  Services []Service
+}
+
+// Service of a cluster, with its roles, health checks and configuration
+// (only the values that differ from the defaults, as Cloudera Manager lists)
+type Service struct {
+  Name string
+  Type string
+  State string
+  Health string
+  Health_checks map[string]string
+  Maintenance_mode bool
+  Roles []Role
+  Config map[string]string
+}
+
+// Role of a service
+type Role struct {
+  Name string
+  Type string
+  Host_id string
+  State string
+  Health string
+  Config_staleness string
+  Maintenance_mode bool
+  Commission_state string
+}
+
+// Host managed by Cloudera Manager
+type Host struct {
+  Host_id string
+  Hostname string
+  Ip_address string
+}
+
+// TimeSerie returned by a tsquery: its metadata attributes (entityName,
+// clusterName, serviceName, ...) and its datapoints
+type Timeseries struct {
+  Attributes map[string]string
+  Datapoints []Datapoint
+}
+
+// Datapoint of a TimeSerie
+type Datapoint struct {
+  Timestamp time.Time
+  Value float64
+}
+
+// Event of Cloudera Manager, with its attributes (CLUSTER, SERVICE,
+// SERVICE_TYPE, ROLE...)
+type Event struct {
+  Id string
+  Category string
+  Severity string
+  Content string
+  Time_occurred time.Time
+  Time_received time.Time
+  Attributes map[string]string
+}
+
+// Failure injected in the requests whose API path (without /api/vXX/)
+// starts with a prefix: an HTTP status and/or a delay, for the next Times
+// requests (0 for all of them)
+type Fault struct {
+  Status int
+  Delay time.Duration
+  Times int
+}
+
+// Fake Cloudera Manager API server
+type Server struct {
+  *httptest.Server
+  mutex sync.Mutex
+  api_version string
+  user string
+  password string
+  token string
+  clusters []Cluster
+  hosts []Host
+  timeseries map[string][]Timeseries
+  timeseries_warnings map[string][]string
+  events []Event
+  faults map[string]*Fault
+  requests []string
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Start a fake Cloudera Manager that supports the given API version (vXX).
+// Close it with Close
+func New_server(api_version string) *Server {
+  s := &Server {
+    api_version: api_version,
+    timeseries: map[string][]Timeseries{},
+    timeseries_warnings: map[string][]string{},
+    faults: map[string]*Fault{},
+  }
+  s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+  return s
+}
+
+
+// Require the basic authentication of the user in every request
+func (s *Server) Set_basic_auth(user string, password string) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.user, s.password = user, password
+}
+
+
+// Require the bearer token in every request
+func (s *Server) Set_bearer_token(token string) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.token = token
+}
+
+
+// Add a cluster with its services
+func (s *Server) Add_cluster(cluster Cluster) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.clusters = append(s.clusters, cluster)
+}
+
+
+// Add a host
+func (s *Server) Add_host(host Host) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.hosts = append(s.hosts, host)
+}
+
+
+// Set the TimeSeries returned by a tsquery, with optional warnings. The
+// queries without TimeSeries return none
+func (s *Server) Set_timeseries(query string, warnings []string, series ...Timeseries) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.timeseries[query] = series
+  s.timeseries_warnings[query] = warnings
+}
+
+
+// Add an event. The events API filters them by the timeReceived=ge= and
+// the attribute==value conditions of the query (e.g. serviceType==ZOOKEEPER
+// for the SERVICE_TYPE attribute)
+func (s *Server) Add_event(event Event) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.events = append(s.events, event)
+}
+
+
+// Inject a failure in the requests whose API path starts with the prefix
+// (e.g. "timeseries", "clusters/c1/services")
+func (s *Server) Inject_fault(path_prefix string, fault Fault) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  s.faults[path_prefix] = &fault
+}
+
+
+// Returns the requests received (path and query), in order
+func (s *Server) Requests() []string {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  return append([]string(nil), s.requests...)
+}
+
+
+// Returns the host and port of the server, as set in the config file
+func (s *Server) Host_port() (string, string) {
+  server_url, _ := url.Parse(s.URL)
+  host, port, _ := net.SplitHostPort(server_url.Host)
+  return host, port
+}
+
+
+// Returns true if the request has the required credentials
+func (s *Server) authorized(r *http.Request) bool {
+  if s.token != "" {
+    return r.Header.Get("Authorization") == "Bearer " + s.token
+  }
+  if s.user != "" {
+    user, password, ok := r.BasicAuth()
+    return ok && user == s.user && password == s.password
+  }
+  return true
+}
+
+
+// Returns the fault to inject in the request, if any, counting it
+func (s *Server) take_fault(path string) *Fault {
+  for prefix, fault := range s.faults {
+    if !strings.HasPrefix(path, prefix) {
+      continue
+    }
+    taken := *fault
+    if fault.Times > 0 {
+      fault.Times--
+      if fault.Times == 0 {
+        delete(s.faults, prefix)
+      }
+    }
+    return &taken
+  }
+  return nil
+}
+
+
+// Serve a request of the API
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+  s.mutex.Lock()
+  s.requests = append(s.requests, r.URL.RequestURI())
+  authorized := s.authorized(r)
+  path := strings.TrimPrefix(r.URL.Path, "/api/" + s.api_version + "/")
+  fault := s.take_fault(path)
+  s.mutex.Unlock()
+
+  if !authorized {
+    write_error(w, http.StatusUnauthorized, "Bad credentials")
+    return
+  }
+  if fault != nil {
+    if fault.Delay > 0 {
+      select {
+      case <-time.After(fault.Delay):
+      case <-r.Context().Done():
+        return
+      }
+    }
+    if fault.Status != 0 {
+      write_error(w, fault.Status, "Injected fault")
+      return
+    }
+  }
+  if r.URL.Path == "/api/version" {
+    fmt.Fprint(w, s.api_version)
+    return
+  }
+  if path == r.URL.Path {
+    write_error(w, http.StatusNotFound, "Unsupported API version")
+    return
+  }
+
+  s.mutex.Lock()
+  body, found := s.route(path, r.URL.Query())
+  s.mutex.Unlock()
+  if !found {
+    write_error(w, http.StatusNotFound, "Unknown resource " + path)
+    return
+  }
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(body)
+}
+
+
+// Returns the response of the API path, or false if the resource does not
+// exist
+func (s *Server) route(path string, query url.Values) (interface{}, bool) {
+  parts := strings.Split(path, "/")
+  switch {
+  case path == "timeseries":
+    return s.timeseries_response(query.Get("query")), true
+  case path == "events":
+    return s.events_response(query), true
+  case path == "hosts":
+    items := []interface{}{}
+    for _, host := range s.hosts {
+      items = append(items, map[string]interface{}{"hostId": host.Host_id, "hostname": host.Hostname, "ipAddress": host.Ip_address})
+    }
+    return paginate(items, query), true
+  case path == "clusters":
+    items := []interface{}{}
+    for _, cluster := range s.clusters {
+      items = append(items, cluster_json(cluster))
+    }
+    return paginate(items, query), true
+  case len(parts) == 2 && parts[0] == "clusters":
+    cluster, ok := s.find_cluster(parts[1])
+    if !ok {
+      return nil, false
+    }
+    return cluster_json(cluster), true
+  case len(parts) == 3 && parts[0] == "clusters" && parts[2] == "services":
+    cluster, ok := s.find_cluster(parts[1])
+    if !ok {
+      return nil, false
+    }
+    items := []interface{}{}
+    for _, service := range cluster.Services {
+      items = append(items, service_json(service))
+    }
+    return paginate(items, query), true
+  case len(parts) >= 4 && parts[0] == "clusters" && parts[2] == "services":
+    service, ok := s.find_service(parts[1], parts[3])
+    if !ok {
+      return nil, false
+    }
+    return service_resource(service, parts[4:], query)
+  }
+  return nil, false
+}
+
+
+// Returns the response of a service or of one of its sub-resources
+func service_resource(service Service, resource []string, query url.Values) (interface{}, bool) {
+  if len(resource) == 0 {
+    return service_json(service), true
+  }
+  if len(resource) > 1 {
+    return nil, false
+  }
+  items := []interface{}{}
+  switch resource[0] {
+  case "roles":
+    for _, role := range service.Roles {
+      items = append(items, map[string]interface{} {
+        "name": role.Name,
+        "type": role.Type,
+        "hostRef": map[string]string{"hostId": role.Host_id},
+        "roleState": role.State,
+        "healthSummary": role.Health,
+        "configStalenessStatus": role.Config_staleness,
+        "maintenanceMode": role.Maintenance_mode,
+        "commissionState": role.Commission_state,
+      })
+    }
+  case "config":
+    for _, name := range sorted_keys(service.Config) {
+      items = append(items, map[string]string{"name": name, "value": service.Config[name]})
+    }
+  default:
+    return nil, false
+  }
+  return paginate(items, query), true
+}
+
+
+// Returns the JSON of a cluster
+func cluster_json(cluster Cluster) map[string]interface{} {
+  return map[string]interface{} {
+    "name": cluster.Name,
+    "displayName": cluster.Display_name,
+    "fullVersion": cluster.Full_version,
+    "maintenanceMode": cluster.Maintenance_mode,
+  }
+}
+
+
+// Returns the JSON of a service, with its health checks
+func service_json(service Service) map[string]interface{} {
+  checks := []interface{}{}
+  for _, name := range sorted_keys(service.Health_checks) {
+    checks = append(checks, map[string]string{"name": name, "summary": service.Health_checks[name]})
+  }
+  return map[string]interface{} {
+    "name": service.Name,
+    "type": service.Type,
+    "serviceState": service.State,
+    "healthSummary": service.Health,
+    "healthChecks": checks,
+    "maintenanceMode": service.Maintenance_mode,
+  }
+}
+
+
+// Returns the response of a TimeSeries query
+func (s *Server) timeseries_response(query string) map[string]interface{} {
+  series := []interface{}{}
+  for _, serie := range s.timeseries[query] {
+    data := []interface{}{}
+    for _, datapoint := range serie.Datapoints {
+      data = append(data, map[string]interface{} {
+        "timestamp": datapoint.Timestamp.UTC().Format(time.RFC3339Nano),
+        "value": datapoint.Value,
+        "type": "SAMPLE",
+      })
+    }
+    series = append(series, map[string]interface{}{"metadata": map[string]interface{}{"attributes": serie.Attributes}, "data": data})
+  }
+  warnings := s.timeseries_warnings[query]
+  if warnings == nil {
+    warnings = []string{}
+  }
+  return map[string]interface{}{"items": []interface{}{map[string]interface{}{"timeSeries": series, "warnings": warnings}}}
+}
+
+
+// Returns the events received since the time of the query, paginated with
+// the resultOffset and maxResults parameters
+func (s *Server) events_response(query url.Values) map[string]interface{} {
+  var since time.Time
+  attributes := map[string]string{}
+  for _, condition := range strings.Split(query.Get("query"), ";") {
+    if value := strings.TrimPrefix(condition, "timeReceived=ge="); value != condition {
+      since, _ = time.Parse(time.RFC3339Nano, value)
+    } else if equal := strings.Index(condition, "=="); equal > 0 {
+      attributes[attribute_name(condition[:equal])] = condition[equal + 2:]
+    }
+  }
+  items := []interface{}{}
+  for _, event := range s.events {
+    if event.Time_received.Before(since) || !has_attributes(event, attributes) {
+      continue
+    }
+    attributes := []interface{}{}
+    for _, name := range sorted_keys(event.Attributes) {
+      attributes = append(attributes, map[string]interface{}{"name": name, "values": []string{event.Attributes[name]}})
+    }
+    items = append(items, map[string]interface{} {
+      "id": event.Id,
+      "content": event.Content,
+      "timeOccurred": event.Time_occurred.UTC().Format(time.RFC3339Nano),
+      "timeReceived": event.Time_received.UTC().Format(time.RFC3339Nano),
+      "category": event.Category,
+      "severity": event.Severity,
+      "attributes": attributes,
+    })
+  }
+  total := len(items)
+  page := url.Values{"offset": {query.Get("resultOffset")}, "limit": {query.Get("maxResults")}}
+  response := paginate(items, page)
+  response["totalResults"] = total
+  return response
+}
+
+
+// Returns the name of an event attribute from its name in the queries:
+// serviceType for SERVICE_TYPE
+func attribute_name(query_name string) string {
+  var name strings.Builder
+  for _, r := range query_name {
+    if unicode.IsUpper(r) {
+      name.WriteByte('_')
+    }
+    name.WriteRune(unicode.ToUpper(r))
+  }
+  return name.String()
+}
+
+
+// Returns true if the event has the attributes with the values
+func has_attributes(event Event, attributes map[string]string) bool {
+  for name, value := range attributes {
+    if event.Attributes[name] != value {
+      return false
+    }
+  }
+  return true
+}
+
+
+// Returns the cluster by name
+func (s *Server) find_cluster(name string) (Cluster, bool) {
+  name, _ = url.PathUnescape(name)
+  for _, cluster := range s.clusters {
+    if cluster.Name == name {
+      return cluster, true
+    }
+  }
+  return Cluster{}, false
+}
+
+
+// Returns the service of the cluster by name
+func (s *Server) find_service(cluster_name string, name string) (Service, bool) {
+  cluster, ok := s.find_cluster(cluster_name)
+  if !ok {
+    return Service{}, false
+  }
+  name, _ = url.PathUnescape(name)
+  for _, service := range cluster.Services {
+    if service.Name == name {
+      return service, true
+    }
+  }
+  return Service{}, false
+}
+
+
+// Returns the keys of the map, sorted
+func sorted_keys(values map[string]string) []string {
+  keys := make([]string, 0, len(values))
+  for key := range values {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+
+// Returns the items of the page selected by the offset and limit parameters
+func paginate(items []interface{}, query url.Values) map[string]interface{} {
+  offset, _ := strconv.Atoi(query.Get("offset"))
+  if offset < 0 || offset > len(items) {
+    offset = len(items)
+  }
+  items = items[offset:]
+  if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit >= 0 && limit < len(items) {
+    items = items[:limit]
+  }
+  return map[string]interface{}{"items": items}
+}
+
+
+// Write an error response as Cloudera Manager does
+func write_error(w http.ResponseWriter, status int, message string) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -94,6 +94,11 @@ func Get_api_query_cluster_maintenance_mode(json_api gjson.Result) string {
   return Get_json_field (json_api, "maintenanceMode")
 }
 
+// Return the Service Maintenance Mode parameter for a API Query
+func Get_api_query_service_maintenance_mode(json_api gjson.Result) string {
+  return Get_json_field (json_api, "maintenanceMode")
+}
+
 // Return the Service Name parameter for a API Query
 func Get_api_query_service_name(json_api gjson.Result, serie_index int) string {
   return Get_json_field (json_api, fmt.Sprintf("items.%d.name", serie_index))
@@ -134,6 +139,21 @@ func Get_api_query_role_health(json_api gjson.Result, serie_index int) string {
   return Get_json_field (json_api, fmt.Sprintf("items.%d.healthSummary", serie_index))
 }
 
+// Return the Role Config Staleness Status parameter for a API Query
+func Get_api_query_role_config_staleness(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.configStalenessStatus", serie_index))
+}
+
+// Return the Role Maintenance Mode parameter for a API Query
+func Get_api_query_role_maintenance_mode(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.maintenanceMode", serie_index))
+}
+
+// Return the Role Commission State parameter for a API Query
+func Get_api_query_role_commission_state(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.commissionState", serie_index))
+}
+
 // Return the Cloudera Management Service Name parameter for a API Query
 func Get_api_query_cm_service_name(json_api gjson.Result) string {
   return Get_json_field (json_api, "name")
@@ -182,3 +202,54 @@ func Get_api_query_clusters_list(json_api gjson.Result) []gjson.Result {
 func Get_api_query_cm_version(json_api gjson.Result) string {
   return Get_json_field (json_api, "version")
 }
+
+// Return A list of Cluster Names (API identifiers) for a API Query
+func Get_api_query_cluster_names_list(json_api gjson.Result) []gjson.Result {
+  return Get_json_array (json_api, "items.#.name")
+}
+
+// Return the name of a configuration parameter for a API Query
+func Get_api_query_config_name(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.name", serie_index))
+}
+
+// Return the value of a configuration parameter for a API Query
+func Get_api_query_config_value(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.value", serie_index))
+}
+
+// Return the ID of an event for a API Query
+func Get_api_query_event_id(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.id", serie_index))
+}
+
+// Return the category of an event (HEALTH_CHECK, LOG_MESSAGE...) for a API Query
+func Get_api_query_event_category(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.category", serie_index))
+}
+
+// Return the severity of an event (INFORMATIONAL, IMPORTANT, CRITICAL) for a API Query
+func Get_api_query_event_severity(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.severity", serie_index))
+}
+
+// Return the time an event occurred for a API Query
+func Get_api_query_event_time_occurred(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.timeOccurred", serie_index))
+}
+
+// Return the time Cloudera Manager received an event for a API Query
+func Get_api_query_event_time_received(json_api gjson.Result, serie_index int) string {
+  return Get_json_field (json_api, fmt.Sprintf("items.%d.timeReceived", serie_index))
+}
+
+// Return the first value of an attribute (CLUSTER, SERVICE, ROLE...) of an
+// event for a API Query. Empty if the event has not the attribute
+func Get_api_query_event_attribute(json_api gjson.Result, serie_index int, name string) string {
+  for _, attribute := range Get_json_array (json_api, fmt.Sprintf("items.%d.attributes", serie_index)) {
+    if attribute.Get("name").String() == name {
+      return attribute.Get("values.0").String()
+    }
+  }
+  return ""
+}
@@ -0,0 +1,139 @@
+/*
+ *
+ * title           :json_parser/json_cloudera_api_timeseries_legacy_test.go
+ * description     :Baseline of the benchmarks: the TimeSeries getters that
+ *                  searched the response by path on every call, before the
+ *                  responses were decoded in a single pass
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package json_parser
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "errors"
+  "fmt"
+  "strconv"
+  "time"
+
+  // Go JSON parsing libraries
+  "github.com/tidwall/gjson"
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Kept as they were in json_parser, so the baseline does not change with the
+// parser it is compared to
+func legacy_timeseries_path(json_timeseries gjson.Result, serie_index int) string {
+  if json_timeseries.Get("items.#").Int() > 1 {
+    for item_index, series := range json_timeseries.Get("items.#.timeSeries").Array() {
+      num_series := int(series.Get("#").Int())
+      if serie_index < num_series {
+        return fmt.Sprintf("items.%d.timeSeries.%d", item_index, serie_index)
+      }
+      serie_index -= num_series
+    }
+  }
+  return fmt.Sprintf("items.0.timeSeries.%d", serie_index)
+}
+
+
+func legacy_last_datapoint_path(json_timeseries gjson.Result, serie_index int) string {
+  serie := legacy_timeseries_path(json_timeseries, serie_index)
+  num_datapoints := json_timeseries.Get(fmt.Sprintf("%s.data.#", serie)).Int()
+  if num_datapoints == 0 {
+    num_datapoints = 1
+  }
+  return fmt.Sprintf("%s.data.%d", serie, num_datapoints - 1)
+}
+
+
+func legacy_datapoint_value(json_timeseries gjson.Result, datapoint string, statistic string) (float64, error) {
+  field := fmt.Sprintf("%s.value", datapoint)
+  if statistic != "value" && json_timeseries.Get(fmt.Sprintf("%s.aggregateStatistics", datapoint)).Exists() {
+    field = fmt.Sprintf("%s.aggregateStatistics.%s", datapoint, statistic)
+  }
+  if value, err := strconv.ParseFloat(json_timeseries.Get(field).String(), 64); err == nil {
+    return value, nil
+  } else {
+    return -999999.999999, errors.New("Cannot parse timeseries value")
+  }
+}
+
+
+func legacy_timeseries_num(json_timeseries gjson.Result) (int, error) {
+  items_series := json_timeseries.Get("items.#.timeSeries").Array()
+  if len(items_series) == 0 {
+    return -999999, errors.New("Cannot parse timeseries value")
+  }
+  num_series := 0
+  for _, series := range items_series {
+    num_series += int(series.Get("#").Int())
+  }
+  return num_series, nil
+}
+
+
+func legacy_timeseries_datapoints_num(json_timeseries gjson.Result) int {
+  num_datapoints := 0
+  for _, series := range json_timeseries.Get("items.#.timeSeries").Array() {
+    for _, serie := range series.Array() {
+      num_datapoints += int(serie.Get("data.#").Int())
+    }
+  }
+  return num_datapoints
+}
+
+
+func legacy_timeseries_warnings(json_timeseries gjson.Result) []string {
+  warnings := []string{}
+  for _, item_warnings := range json_timeseries.Get("items.#.warnings").Array() {
+    for _, warning := range item_warnings.Array() {
+      warnings = append(warnings, warning.String())
+    }
+  }
+  return warnings
+}
+
+
+func legacy_attribute(json_timeseries gjson.Result, serie_index int, attribute string) string {
+  return json_timeseries.Get(fmt.Sprintf("%s.metadata.attributes.%s", legacy_timeseries_path(json_timeseries, serie_index), attribute)).String()
+}
+
+
+func legacy_datapoints_num(json_timeseries gjson.Result, serie_index int) int {
+  return int(json_timeseries.Get(fmt.Sprintf("%s.data.#", legacy_timeseries_path(json_timeseries, serie_index))).Int())
+}
+
+
+func legacy_rollup_value(json_timeseries gjson.Result, serie_index int, statistic string) (float64, error) {
+  return legacy_datapoint_value(json_timeseries, legacy_last_datapoint_path(json_timeseries, serie_index), statistic)
+}
+
+
+func legacy_timestamp(json_timeseries gjson.Result, serie_index int) (time.Time, error) {
+  return time.Parse(time.RFC3339, json_timeseries.Get(fmt.Sprintf("%s.timestamp", legacy_last_datapoint_path(json_timeseries, serie_index))).String())
+}
+
+
+func legacy_datapoint(json_timeseries gjson.Result, serie_index int, datapoint_index int, statistic string) (time.Time, float64, error) {
+  datapoint := fmt.Sprintf("%s.data.%d", legacy_timeseries_path(json_timeseries, serie_index), datapoint_index)
+  timestamp, err := time.Parse(time.RFC3339, json_timeseries.Get(fmt.Sprintf("%s.timestamp", datapoint)).String())
+  if err != nil {
+    return time.Time{}, 0, err
+  }
+  value, err := legacy_datapoint_value(json_timeseries, datapoint, statistic)
+  return timestamp, value, err
+}
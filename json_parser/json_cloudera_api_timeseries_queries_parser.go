@@ -18,58 +18,160 @@ import (
   "fmt"
   "strconv"
   "errors"
-
-  // Go JSON parsing libraries
-  "github.com/tidwall/gjson"
+  "net/url"
+  "time"
 )
 
 // Base string to the Cloudera URL TimeSeries Query API
 const TIMESERIES_API_BASE_URL="http://%s:%s/api/%s/timeseries?%s"
 
+// Time format of the from and to parameters of the TimeSeries Query API
+const TIMESERIES_TIME_FORMAT="2006-01-02T15:04:05.000Z"
+
+// Rollup statistic that returns the plain value of the datapoint
+const ROLLUP_STATISTIC_VALUE="value"
+
+// Window of the TimeSeries Queries without from parameter
+const TIMESERIES_DEFAULT_WINDOW = 5 * time.Minute
+
+// Rollups of the TimeSeries Query API, from the finest to the coarsest
+var ROLLUPS = []string{"RAW", "TEN_MINUTELY", "HOURLY", "SIX_HOURLY", "DAILY", "WEEKLY"}
+
 // Compose the URL connection to the Cloudera API TimeSeries Query
 func Build_timeseries_api_query_url(host string, port string, timeseries_version string, query string) string {
   return fmt.Sprintf(TIMESERIES_API_BASE_URL, host, port, timeseries_version, query)
 }
 
 // Return the host_id metadata parameter from a TimeSeries Query
-func Get_timeseries_query_host_id(json_timeseries gjson.Result, serie_index int) string {
-  return Get_json_field(json_timeseries, fmt.Sprintf("items.0.timeSeries.%d.metadata.attributes.hostId", serie_index))
+func Get_timeseries_query_host_id(json_timeseries Timeseries_response, serie_index int) string {
+  return Get_timeseries_query_attribute(json_timeseries, serie_index, "hostId")
 }
 
 // Return the entityName metadata parameter from a TimeSeries Query
-func Get_timeseries_query_entity_name(json_timeseries gjson.Result, serie_index int) string {
-  return Get_json_field(json_timeseries, fmt.Sprintf("items.0.timeSeries.%d.metadata.attributes.entityName", serie_index))
+func Get_timeseries_query_entity_name(json_timeseries Timeseries_response, serie_index int) string {
+  return Get_timeseries_query_attribute(json_timeseries, serie_index, "entityName")
 }
 
 // Return the host_name metadata parameter from a TimeSeries Query
-func Get_timeseries_query_host_name(json_timeseries gjson.Result, serie_index int) string {
-  return Get_json_field(json_timeseries, fmt.Sprintf("items.0.timeSeries.%d.metadata.attributes.hostname", serie_index))
+func Get_timeseries_query_host_name(json_timeseries Timeseries_response, serie_index int) string {
+  return Get_timeseries_query_attribute(json_timeseries, serie_index, "hostname")
+}
+
+// Return the serviceName metadata parameter from a TimeSeries Query
+func Get_timeseries_query_service_name(json_timeseries Timeseries_response, serie_index int) string {
+  return Get_timeseries_query_attribute(json_timeseries, serie_index, "serviceName")
 }
 
 // Return the cluster metadata parameter from a TimeSeries Query
-func Get_timeseries_query_cluster_display_name(json_timeseries gjson.Result, serie_index int) string {
-  return Get_json_field(json_timeseries, fmt.Sprintf("items.0.timeSeries.%d.metadata.attributes.clusterDisplayName", serie_index))
+func Get_timeseries_query_cluster_display_name(json_timeseries Timeseries_response, serie_index int) string {
+  return Get_timeseries_query_attribute(json_timeseries, serie_index, "clusterDisplayName")
 }
 
 // Return the cluster metadata parameter from a TimeSeries Query
-func Get_timeseries_query_cluster(json_timeseries gjson.Result, serie_index int) string {
-  return Get_json_field(json_timeseries, fmt.Sprintf("items.0.timeSeries.%d.metadata.attributes.clusterName", serie_index))
+func Get_timeseries_query_cluster(json_timeseries Timeseries_response, serie_index int) string {
+  return Get_timeseries_query_attribute(json_timeseries, serie_index, "clusterName")
+}
+
+// Return a metadata attribute (roleName, hostname, serviceType...) from a TimeSeries Query
+func Get_timeseries_query_attribute(json_timeseries Timeseries_response, serie_index int, attribute string) string {
+  return json_timeseries.serie(serie_index).attributes[attribute]
 }
 
 // Return the last timeseries value from a TimeSeries Query
-func Get_timeseries_query_value(json_timeseries gjson.Result, serie_index int) (float64, error) {
-  if value, err := strconv.ParseFloat(Get_json_field(json_timeseries, fmt.Sprintf("items.0.timeSeries.%d.data.0.value", serie_index)), 64); err == nil {
-    return value, nil
+func Get_timeseries_query_value(json_timeseries Timeseries_response, serie_index int) (float64, error) {
+  return Get_timeseries_query_rollup_value(json_timeseries, serie_index, ROLLUP_STATISTIC_VALUE)
+}
+
+// Return the last timeseries value from a TimeSeries Query. If the datapoint
+// is a rollup (type CALCULATED), the given aggregate statistic (mean, min,
+// max, count, sampleValue, stdDev) is returned instead of the value
+func Get_timeseries_query_rollup_value(json_timeseries Timeseries_response, serie_index int, statistic string) (float64, error) {
+  datapoint, _ := json_timeseries.last_datapoint(serie_index)
+  return datapoint_value(datapoint, statistic)
+}
+
+// Return the number of datapoints of a TimeSerie
+func Get_timeseries_query_datapoints_num(json_timeseries Timeseries_response, serie_index int) int {
+  return json_timeseries.serie(serie_index).num_datapoints
+}
+
+// Return the timestamp and the value (or the given rollup statistic) of a
+// datapoint of a TimeSerie
+func Get_timeseries_query_datapoint(json_timeseries Timeseries_response, serie_index int, datapoint_index int, statistic string) (time.Time, float64, error) {
+  datapoint, _ := json_timeseries.datapoint(serie_index, datapoint_index)
+  timestamp, err := time.Parse(time.RFC3339, datapoint.Timestamp)
+  if err != nil {
+    return time.Time{}, 0, err
+  }
+  value, err := datapoint_value(datapoint, statistic)
+  return timestamp, value, err
+}
+
+// Return the value of the datapoint, or the rollup statistic
+func datapoint_value(datapoint datapoint, statistic string) (float64, error) {
+  number := datapoint.Value
+  if statistic != ROLLUP_STATISTIC_VALUE && datapoint.Aggregate_statistics != nil {
+    number = datapoint.Aggregate_statistics[statistic]
+  }
+  if number.valid {
+    return number.value, nil
   } else {
     return -999999.999999, errors.New("Cannot parse timeseries value")
   }
 }
 
-// Return the number of different TimeSeries from a TimeSeriesQuery
-func Get_timeseries_num(json_timeseries gjson.Result) (int, error) {
-  if value, err := strconv.Atoi(Get_json_field(json_timeseries, "items.0.timeSeries.#")); err == nil {
-    return value, nil
-  } else {
+// Return the timestamp of the last datapoint from a TimeSeries Query
+func Get_timeseries_query_timestamp(json_timeseries Timeseries_response, serie_index int) (time.Time, error) {
+  datapoint, _ := json_timeseries.last_datapoint(serie_index)
+  return time.Parse(time.RFC3339, datapoint.Timestamp)
+}
+
+// Return the number of datapoints of all the TimeSeries from a TimeSeries Query
+func Get_timeseries_datapoints_num(json_timeseries Timeseries_response) int {
+  return len(json_timeseries.datapoints)
+}
+
+// Return the number of different TimeSeries from a TimeSeriesQuery. The
+// TimeSeries of all the items of the response are counted
+func Get_timeseries_num(json_timeseries Timeseries_response) (int, error) {
+  if !json_timeseries.has_series {
     return -999999, errors.New("Cannot parse timeseries value")
   }
+  return len(json_timeseries.series), nil
+}
+
+// Return the warnings of all the items of a TimeSeries Query
+func Get_timeseries_warnings(json_timeseries Timeseries_response) []string {
+  return json_timeseries.warnings
+}
+
+// Return the next coarser rollup. The first rollup coarser than RAW if it is
+// blank, and WEEKLY for the coarsest
+func Get_coarser_rollup(rollup string) string {
+  for i, r := range ROLLUPS[:len(ROLLUPS) - 1] {
+    if r == rollup {
+      return ROLLUPS[i + 1]
+    }
+  }
+  if rollup == "" {
+    return ROLLUPS[1]
+  }
+  return ROLLUPS[len(ROLLUPS) - 1]
+}
+
+// Compose the time window and rollup parameters of a TimeSeries Query
+func Encode_timeseries_window(from time.Time, to time.Time, desired_rollup string, must_use_desired_rollup bool) string {
+  params := url.Values{}
+  if !from.IsZero() {
+    params.Set("from", from.UTC().Format(TIMESERIES_TIME_FORMAT))
+    params.Set("to", to.UTC().Format(TIMESERIES_TIME_FORMAT))
+  }
+  if desired_rollup != "" {
+    params.Set("desiredRollup", desired_rollup)
+    params.Set("mustUseDesiredRollup", strconv.FormatBool(must_use_desired_rollup))
+  }
+  if len(params) == 0 {
+    return ""
+  }
+  return "&" + params.Encode()
 }
@@ -0,0 +1,286 @@
+/*
+ *
+ * title           :json_parser/json_cloudera_api_timeseries_response.go
+ * description     :TimeSeries Query responses decoded from the stream of
+ *                  JSON tokens in a single pass, so the series are read
+ *                  without searching the response again
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package json_parser
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "encoding/json"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Bytes of a small datapoint of Cloudera Manager, with its timestamp, value
+// and type: {"timestamp":"2025-01-01T00:00:00.000Z","value":0,"type":"SAMPLE"}
+const min_datapoint_size = 64
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// TimeSeries Query response. The response has an item for each tsquery
+// statement, and the TimeSeries of all of them are decoded one after another
+type Timeseries_response struct {
+  series []timeserie
+  datapoints []datapoint
+  warnings []string
+  // False if no item has a timeSeries list (e.g. an error response), or if
+  // the response is not valid JSON
+  has_series bool
+}
+
+// TimeSerie of a response: the metadata attributes and the position of its
+// datapoints, from the oldest to the most recent
+type timeserie struct {
+  attributes map[string]string
+  first_datapoint int
+  num_datapoints int
+}
+
+// Datapoint of a TimeSerie. The aggregate statistics are only in the
+// rollups (type CALCULATED)
+type datapoint struct {
+  Timestamp string `json:"timestamp"`
+  Value datapoint_number `json:"value"`
+  Aggregate_statistics map[string]datapoint_number `json:"aggregateStatistics"`
+}
+
+// Number of a datapoint. Cloudera Manager can send it quoted, and a value
+// that is not a number is kept as invalid instead of failing the response
+type datapoint_number struct {
+  value float64
+  valid bool
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the TimeSeries Query response decoded. The JSON is read as a
+// stream of tokens, and the values that are not read by the getters are
+// skipped without being decoded
+func Parse_timeseries_response(json string) Timeseries_response {
+  // The datapoints are allocated once for the most datapoints the response
+  // can have, instead of growing with each TimeSerie
+  response := Timeseries_response{datapoints: make([]datapoint, 0, len(json) / min_datapoint_size)}
+  if err := response.decode(strings.NewReader(json)); err != nil {
+    return Timeseries_response{}
+  }
+  return response
+}
+
+
+// Decodes the items of the response
+func (response *Timeseries_response) decode(reader io.Reader) error {
+  decoder := new_token_decoder(reader)
+  return decoder.object(func(key string) error {
+    if key != "items" {
+      return decoder.skip()
+    }
+    return decoder.array(func() error {
+      return decoder.object(func(key string) error {
+        switch key {
+        case "timeSeries":
+          response.has_series = true
+          return decoder.array(func() error {
+            return response.decode_serie(decoder)
+          })
+        case "warnings":
+          return decoder.array(func() error {
+            warning, err := decoder.string_value()
+            response.warnings = append(response.warnings, warning)
+            return err
+          })
+        }
+        return decoder.skip()
+      })
+    })
+  })
+}
+
+
+// Decodes a TimeSerie, with its datapoints appended to the response
+func (response *Timeseries_response) decode_serie(decoder *token_decoder) error {
+  serie := timeserie{first_datapoint: len(response.datapoints)}
+  err := decoder.object(func(key string) error {
+    switch key {
+    case "metadata":
+      // The attributes are strings. One that is not is left blank, without
+      // failing the response
+      var metadata struct {
+        Attributes map[string]string `json:"attributes"`
+      }
+      err := decoder.Decode(&metadata)
+      if _, type_error := err.(*json.UnmarshalTypeError); type_error {
+        err = nil
+      }
+      serie.attributes = metadata.Attributes
+      return err
+    case "data":
+      return decoder.array(func() error {
+        response.datapoints = append(response.datapoints, datapoint{})
+        return decoder.Decode(&response.datapoints[len(response.datapoints) - 1])
+      })
+    }
+    return decoder.skip()
+  })
+  serie.num_datapoints = len(response.datapoints) - serie.first_datapoint
+  response.series = append(response.series, serie)
+  return err
+}
+
+
+// Returns the TimeSerie, or an empty one if the index is out of the response
+func (response Timeseries_response) serie(serie_index int) timeserie {
+  if serie_index < 0 || serie_index >= len(response.series) {
+    return timeserie{}
+  }
+  return response.series[serie_index]
+}
+
+
+// Returns the datapoint of a TimeSerie, and false if it does not exist
+func (response Timeseries_response) datapoint(serie_index int, datapoint_index int) (datapoint, bool) {
+  serie := response.serie(serie_index)
+  if datapoint_index < 0 || datapoint_index >= serie.num_datapoints {
+    return datapoint{}, false
+  }
+  return response.datapoints[serie.first_datapoint + datapoint_index], true
+}
+
+
+// Returns the most recent datapoint of a TimeSerie, and false if it has none
+func (response Timeseries_response) last_datapoint(serie_index int) (datapoint, bool) {
+  return response.datapoint(serie_index, response.serie(serie_index).num_datapoints - 1)
+}
+
+
+// Reads the number, quoted or not. Any other value is kept as invalid
+func (number *datapoint_number) UnmarshalJSON(data []byte) error {
+  value, err := strconv.ParseFloat(strings.Trim(string(data), `"`), 64)
+  *number = datapoint_number{value: value, valid: err == nil}
+  return nil
+}
+
+
+
+
+/* ======================================================================
+ * Token decoder
+ * ====================================================================== */
+// json.Decoder that walks the objects and the arrays of the stream
+type token_decoder struct {
+  *json.Decoder
+  // Reused to skip the values without keeping them
+  skipped json.RawMessage
+}
+
+
+func new_token_decoder(reader io.Reader) *token_decoder {
+  decoder := json.NewDecoder(reader)
+  decoder.UseNumber()
+  return &token_decoder{Decoder: decoder}
+}
+
+
+// Reads the delimiter that opens an object or an array. Returns false for
+// null, read as an empty object or array
+func (decoder *token_decoder) open(delimiter json.Delim) (bool, error) {
+  token, err := decoder.Token()
+  if err != nil || token == nil {
+    return false, err
+  }
+  if token != delimiter {
+    return false, fmt.Errorf("Found %v instead of %v", token, delimiter)
+  }
+  return true, nil
+}
+
+
+// Reads an object, calling the function with each key. The function must
+// read the value of the key
+func (decoder *token_decoder) object(read_value func(key string) error) error {
+  if opened, err := decoder.open('{'); !opened {
+    return err
+  }
+  for decoder.More() {
+    token, err := decoder.Token()
+    if err != nil {
+      return err
+    }
+    if err := read_value(token.(string)); err != nil {
+      return err
+    }
+  }
+  _, err := decoder.Token()
+  return err
+}
+
+
+// Reads an array, calling the function with each element. The function
+// must read the element
+func (decoder *token_decoder) array(read_element func() error) error {
+  if opened, err := decoder.open('['); !opened {
+    return err
+  }
+  for decoder.More() {
+    if err := read_element(); err != nil {
+      return err
+    }
+  }
+  _, err := decoder.Token()
+  return err
+}
+
+
+// Returns a scalar value as a string: the strings unquoted, the numbers and
+// the booleans as they are written, and "" for null
+func (decoder *token_decoder) string_value() (string, error) {
+  token, err := decoder.Token()
+  if err != nil {
+    return "", err
+  }
+  switch value := token.(type) {
+  case string:
+    return value, nil
+  case json.Number:
+    return value.String(), nil
+  case bool:
+    return strconv.FormatBool(value), nil
+  case nil:
+    return "", nil
+  }
+  return "", fmt.Errorf("Found %v instead of a scalar value", token)
+}
+
+
+// Reads the next value without decoding it
+func (decoder *token_decoder) skip() error {
+  return decoder.Decode(&decoder.skipped)
+}
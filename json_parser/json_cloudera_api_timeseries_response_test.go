@@ -0,0 +1,347 @@
+/*
+ *
+ * title           :json_parser/json_cloudera_api_timeseries_response_test.go
+ * description     :Tests and benchmarks of the parsing of the TimeSeries
+ *                  Query responses, with the access pattern of the
+ *                  collectors, against the path-based getters it replaced
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package json_parser
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "encoding/json"
+  "flag"
+  "fmt"
+  "testing"
+  "time"
+
+  // Go JSON parsing libraries
+  "github.com/tidwall/gjson"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Parser under test. Each workload returns the sum of the values read, so
+// the results of the parsers can be compared
+type parser struct {
+  name string
+  latest func(body string) float64
+  window func(body string) float64
+}
+
+// TimeSeries Query response, with the fields and the order of Cloudera
+// Manager
+type cm_datapoint struct {
+  Timestamp string `json:"timestamp"`
+  Value float64 `json:"value"`
+  Type string `json:"type"`
+}
+
+type cm_metadata struct {
+  Metric_name string `json:"metricName"`
+  Entity_name string `json:"entityName"`
+  Start_time string `json:"startTime"`
+  End_time string `json:"endTime"`
+  Attributes map[string]string `json:"attributes"`
+  Unit_numerators []string `json:"unitNumerators"`
+  Unit_denominators []string `json:"unitDenominators"`
+  Expression string `json:"expression"`
+  Alias *string `json:"alias"`
+  Metric_collection_frequency_ms int `json:"metricCollectionFrequencyMs"`
+  Rollup_used string `json:"rollupUsed"`
+}
+
+type cm_serie struct {
+  Metadata cm_metadata `json:"metadata"`
+  Data []cm_datapoint `json:"data"`
+}
+
+type cm_item struct {
+  Time_series []cm_serie `json:"timeSeries"`
+  Warnings []string `json:"warnings"`
+  Time_series_query string `json:"timeSeriesQuery"`
+}
+
+type cm_response struct {
+  Items []cm_item `json:"items"`
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Size of the response of the benchmarks, set after -args
+var (
+  bench_series = flag.Int("series", 100, "TimeSeries of the benchmark response.")
+  bench_datapoints = flag.Int("datapoints", 10, "Datapoints of each TimeSerie of the benchmark response.")
+  bench_items = flag.Int("items", 1, "Items (tsquery statements) the TimeSeries of the benchmark response are split between.")
+)
+
+var parsers = []parser{
+  {"legacy", legacy_latest, legacy_window},
+  {"streaming", streaming_latest, streaming_window},
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns a TimeSeries Query response with the given size. The datapoints
+// are a minute apart and end now
+func generate_response(series int, datapoints int, items int) string {
+  response := cm_response{Items: make([]cm_item, items)}
+  end := time.Now().UTC().Truncate(time.Minute)
+  for serie_index := 0; serie_index < series; serie_index++ {
+    entity := fmt.Sprintf("zookeeper-SERVER-%04d", serie_index)
+    serie := cm_serie{
+      Metadata: cm_metadata{
+        Metric_name: "avg_request_latency",
+        Entity_name: entity,
+        Start_time: end.Add(-time.Duration(datapoints) * time.Minute).Format(time.RFC3339Nano),
+        End_time: end.Format(time.RFC3339Nano),
+        Attributes: map[string]string{
+          "clusterName": fmt.Sprintf("cluster%02d", serie_index % 40),
+          "clusterDisplayName": fmt.Sprintf("Cluster %02d", serie_index % 40),
+          "serviceName": "zookeeper",
+          "serviceDisplayName": "ZooKeeper",
+          "serviceType": "ZOOKEEPER",
+          "roleName": entity,
+          "roleType": "SERVER",
+          "roleConfigGroup": "zookeeper-SERVER-BASE",
+          "hostId": fmt.Sprintf("4a9c6e2d-%04d-4f6b-9a1e-0c8d7b3f2e1a", serie_index),
+          "hostname": fmt.Sprintf("node%04d.example.com", serie_index),
+          "rackId": "/default",
+          "entityName": entity,
+          "category": "ROLE",
+          "version": "CDH 6.3.4",
+          "active": "true",
+        },
+        Unit_numerators: []string{"ms"},
+        Unit_denominators: []string{},
+        Expression: "SELECT avg_request_latency WHERE roleType = SERVER",
+        Metric_collection_frequency_ms: 60000,
+        Rollup_used: "RAW",
+      },
+    }
+    for datapoint_index := 0; datapoint_index < datapoints; datapoint_index++ {
+      serie.Data = append(serie.Data, cm_datapoint{
+        Timestamp: end.Add(-time.Duration(datapoints - 1 - datapoint_index) * time.Minute).Format(time.RFC3339Nano),
+        Value: float64(serie_index * 7 + datapoint_index) / 8,
+        Type: "SAMPLE",
+      })
+    }
+    item := &response.Items[serie_index * items / series]
+    item.Time_series = append(item.Time_series, serie)
+  }
+  for item_index := range response.Items {
+    response.Items[item_index].Warnings = []string{}
+    response.Items[item_index].Time_series_query = "SELECT avg_request_latency WHERE roleType = SERVER"
+  }
+  body, err := json.Marshal(response)
+  if err != nil {
+    panic(err)
+  }
+  return string(body)
+}
+
+
+// Reads the most recent datapoint of each TimeSerie with its labels, as the
+// collectors do on every scrape
+func legacy_latest(body string) float64 {
+  json_parsed := gjson.Parse(body)
+  legacy_timeseries_datapoints_num(json_parsed)
+  legacy_timeseries_warnings(json_parsed)
+  series, err := legacy_timeseries_num(json_parsed)
+  if err != nil {
+    return 0
+  }
+  sum := 0.0
+  for serie_index := 0; serie_index < series; serie_index++ {
+    if legacy_datapoints_num(json_parsed, serie_index) == 0 {
+      continue
+    }
+    value, _ := legacy_rollup_value(json_parsed, serie_index, ROLLUP_STATISTIC_VALUE)
+    legacy_timestamp(json_parsed, serie_index)
+    legacy_attribute(json_parsed, serie_index, "clusterName")
+    legacy_attribute(json_parsed, serie_index, "entityName")
+    legacy_attribute(json_parsed, serie_index, "hostname")
+    sum += value
+  }
+  return sum
+}
+
+
+func streaming_latest(body string) float64 {
+  json_parsed := Parse_timeseries_response(body)
+  Get_timeseries_datapoints_num(json_parsed)
+  Get_timeseries_warnings(json_parsed)
+  series, err := Get_timeseries_num(json_parsed)
+  if err != nil {
+    return 0
+  }
+  sum := 0.0
+  for serie_index := 0; serie_index < series; serie_index++ {
+    if Get_timeseries_query_datapoints_num(json_parsed, serie_index) == 0 {
+      continue
+    }
+    value, _ := Get_timeseries_query_rollup_value(json_parsed, serie_index, ROLLUP_STATISTIC_VALUE)
+    Get_timeseries_query_timestamp(json_parsed, serie_index)
+    Get_timeseries_query_cluster(json_parsed, serie_index)
+    Get_timeseries_query_entity_name(json_parsed, serie_index)
+    Get_timeseries_query_host_name(json_parsed, serie_index)
+    sum += value
+  }
+  return sum
+}
+
+
+// Reads every datapoint of each TimeSerie, as the window histograms and the
+// query command do
+func legacy_window(body string) float64 {
+  json_parsed := gjson.Parse(body)
+  series, err := legacy_timeseries_num(json_parsed)
+  if err != nil {
+    return 0
+  }
+  sum := 0.0
+  for serie_index := 0; serie_index < series; serie_index++ {
+    legacy_attribute(json_parsed, serie_index, "clusterName")
+    legacy_attribute(json_parsed, serie_index, "entityName")
+    datapoints := legacy_datapoints_num(json_parsed, serie_index)
+    for datapoint_index := 0; datapoint_index < datapoints; datapoint_index++ {
+      _, value, _ := legacy_datapoint(json_parsed, serie_index, datapoint_index, ROLLUP_STATISTIC_VALUE)
+      sum += value
+    }
+  }
+  return sum
+}
+
+
+func streaming_window(body string) float64 {
+  json_parsed := Parse_timeseries_response(body)
+  series, err := Get_timeseries_num(json_parsed)
+  if err != nil {
+    return 0
+  }
+  sum := 0.0
+  for serie_index := 0; serie_index < series; serie_index++ {
+    Get_timeseries_query_cluster(json_parsed, serie_index)
+    Get_timeseries_query_entity_name(json_parsed, serie_index)
+    datapoints := Get_timeseries_query_datapoints_num(json_parsed, serie_index)
+    for datapoint_index := 0; datapoint_index < datapoints; datapoint_index++ {
+      _, value, _ := Get_timeseries_query_datapoint(json_parsed, serie_index, datapoint_index, ROLLUP_STATISTIC_VALUE)
+      sum += value
+    }
+  }
+  return sum
+}
+
+
+// The streaming parser must read the same values as the getters it replaced,
+// with one or several items
+func TestParse_timeseries_response(t *testing.T) {
+  for _, items := range []int{1, 4} {
+    body := generate_response(50, 5, items)
+    want_latest, want_window := parsers[0].latest(body), parsers[0].window(body)
+    if want_latest == 0 || want_window == 0 {
+      t.Fatalf("The %s parser reads no values with %d items", parsers[0].name, items)
+    }
+    for _, p := range parsers[1:] {
+      if got := p.latest(body); got != want_latest {
+        t.Errorf("The %s parser reads %g as the latest values with %d items, want %g", p.name, got, items, want_latest)
+      }
+      if got := p.window(body); got != want_window {
+        t.Errorf("The %s parser reads %g as the window values with %d items, want %g", p.name, got, items, want_window)
+      }
+    }
+  }
+}
+
+
+// The values the generated responses don't have: rollups, quoted and
+// invalid values, null lists, non-string attributes and invalid JSON
+func TestParse_timeseries_response_values(t *testing.T) {
+  body := `{"items":[{"timeSeries":null,"warnings":null},{"timeSeries":[{
+    "metadata":{"metricName":"avg_request_latency","attributes":{"entityName":"zookeeper-SERVER-1","active":true,"clusterName":"cluster1"}},
+    "data":[
+      {"timestamp":"2025-01-01T00:00:00.000Z","value":"1.5","type":"SAMPLE"},
+      {"timestamp":"2025-01-01T00:10:00.000Z","value":2,"type":"CALCULATED","aggregateStatistics":{"sampleTime":"2025-01-01T00:09:00.000Z","mean":2,"max":4,"count":3}},
+      {"timestamp":"2025-01-01T00:20:00.000Z","value":null,"type":"SAMPLE"}
+    ]}],"warnings":["Query used a rollup"]}]}`
+  response := Parse_timeseries_response(body)
+
+  if series, err := Get_timeseries_num(response); series != 1 || err != nil {
+    t.Fatalf("Got %d series (%v), want 1", series, err)
+  }
+  if warnings := Get_timeseries_warnings(response); len(warnings) != 1 || warnings[0] != "Query used a rollup" {
+    t.Errorf("Got the warnings %q", warnings)
+  }
+  if cluster, entity := Get_timeseries_query_cluster(response, 0), Get_timeseries_query_entity_name(response, 0); cluster != "cluster1" || entity != "zookeeper-SERVER-1" {
+    t.Errorf("Got the cluster %q and the entity %q", cluster, entity)
+  }
+  if _, value, err := Get_timeseries_query_datapoint(response, 0, 0, ROLLUP_STATISTIC_VALUE); value != 1.5 || err != nil {
+    t.Errorf("Got the quoted value %g (%v), want 1.5", value, err)
+  }
+  if timestamp, value, err := Get_timeseries_query_datapoint(response, 0, 1, "max"); value != 4 || err != nil || timestamp.Minute() != 10 {
+    t.Errorf("Got the max %g at %s (%v), want 4 at 00:10", value, timestamp, err)
+  }
+  if _, value, err := Get_timeseries_query_datapoint(response, 0, 1, "stdDev"); err == nil {
+    t.Errorf("Got the missing stdDev %g, want an error", value)
+  }
+  if value, err := Get_timeseries_query_value(response, 0); err == nil {
+    t.Errorf("Got the null value %g, want an error", value)
+  }
+  if _, err := Get_timeseries_query_value(response, 1); err == nil {
+    t.Error("Got a value for a missing serie")
+  }
+
+  for _, invalid := range []string{"", `{"items":[{"timeSeries":[{"data":[}]}]}`, `{"items":[{"timeSeries":[`, `{"items":{}}`} {
+    if series, err := Get_timeseries_num(Parse_timeseries_response(invalid)); err == nil {
+      t.Errorf("Got %d series from %q, want an error", series, invalid)
+    }
+  }
+}
+
+
+// Runs a workload of each parser over the response of the size of the flags
+func benchmark_parsers(b *testing.B, workload func(p parser) func(body string) float64) {
+  body := generate_response(*bench_series, *bench_datapoints, *bench_items)
+  for _, p := range parsers {
+    read := workload(p)
+    b.Run(p.name, func(b *testing.B) {
+      b.ReportAllocs()
+      b.SetBytes(int64(len(body)))
+      for i := 0; i < b.N; i++ {
+        read(body)
+      }
+    })
+  }
+}
+
+
+func BenchmarkLatest(b *testing.B) {
+  benchmark_parsers(b, func(p parser) func(body string) float64 { return p.latest })
+}
+
+
+func BenchmarkWindow(b *testing.B) {
+  benchmark_parsers(b, func(p parser) func(body string) float64 { return p.window })
+}
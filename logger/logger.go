@@ -36,6 +36,7 @@ var (
     Error     *log.Logger
     Debug     *log.Logger
     Log_level int
+    handles   []io.Writer
 )
 
 
@@ -49,6 +50,7 @@ func Init(okHandle io.Writer, infoHandle io.Writer, warningHandle io.Writer, err
     error_head := "\033[37m[\033[31mERROR\033[37m]\033[0m"
     debug_head := "\033[37m[\033[36mDEBUG\033[37m]\033[0m"
     Log_level = log_level
+    handles = []io.Writer{okHandle, infoHandle, warningHandle, errorHandle, debugHandle}
 
     Ok = log.New(okHandle,
       fmt.Sprintf("%s  %s ", ok_head, head),
@@ -105,3 +107,13 @@ func Debug_msg (msg ...interface{}){
   _, fileName, fileLine, _ := runtime.Caller(1)
   Debug.Printf("%s:%d:  %s", path.Base(fileName), fileLine, format_msg(msg...))
 }
+
+// Flush the log files to disk before the exporter exits. The errors of the
+// writers that can't be synced (e.g. pipes) are ignored
+func Flush() {
+  for _, handle := range handles {
+    if file, ok := handle.(interface{ Sync() error }); ok {
+      file.Sync()
+    }
+  }
+}
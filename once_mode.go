@@ -0,0 +1,109 @@
+/*
+ *
+ * title           :once_mode.go
+ * description     :One-shot mode: collects the metrics once, prints them in
+ *                  the Prometheus text format and exits
+ * author          :Enes Erdoğan
+ * date            :2025/04/21
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+
+  // Go external libraries
+  "gopkg.in/alecthomas/kingpin.v2"
+
+  // Go Prometheus libraries
+  dto "github.com/prometheus/client_model/go"
+  "github.com/prometheus/common/expfmt"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Flag of the one-shot mode of the serve command
+var once_flag = kingpin.Flag("once", "Collect the metrics once, print them to stdout and exit (non-zero on failure).").Bool()
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the sum of the values of a metric family
+func sum_family(families []*dto.MetricFamily, name string) float64 {
+  sum := 0.0
+  for _, family := range families {
+    if family.GetName() != name {
+      continue
+    }
+    for _, metric := range family.Metric {
+      if value, ok := sample_value(metric); ok {
+        sum += value
+      }
+    }
+  }
+  return sum
+}
+
+
+// Print the metrics in the Prometheus text format and returns an error if
+// any scraper or request to Cloudera Manager failed
+func write_once(output io.Writer, families []*dto.MetricFamily) error {
+  for _, family := range families {
+    if _, err := expfmt.MetricFamilyToText(output, family); err != nil {
+      return err
+    }
+  }
+
+  failures := []string{}
+  if errors := sum_family(families, "kbdi_exporter_cm_request_errors_total"); errors > 0 {
+    failures = append(failures, fmt.Sprintf("%.0f failed requests to Cloudera Manager", errors))
+  }
+  if sum_family(families, "kbdi_exporter_last_scrape_error") > 0 {
+    failures = append(failures, "scrape errors")
+  }
+  if len(failures) > 0 {
+    return fmt.Errorf("Collection failed: %s (see the errors above)", strings.Join(failures, ", "))
+  }
+  return nil
+}
+
+
+// Collect once and print the metrics on the standard output. The metrics
+// gathered are printed even if the collection failed, and the errors of both
+// make the exit status non-zero
+func once_main(scrapers []cl.Scraper) {
+  families, collect_err := collect_once(context.Background(), config, cl.NewMetrics(), scrapers)
+  write_err := write_once(os.Stdout, families)
+  failed := false
+  for _, err := range []error{collect_err, write_err} {
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      failed = true
+    }
+  }
+  if failed {
+    os.Exit(1)
+  }
+}
@@ -0,0 +1,369 @@
+/*
+ *
+ * title           :otlp/otlp.go
+ * description     :Push of the collected metrics to an OpenTelemetry
+ *                  collector with the OTLP/HTTP protocol (JSON encoding)
+ * author          :Enes Erdoğan
+ * date            :2025/05/12
+ * version         :1.0
+ *
+ */
+package otlp
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "bytes"
+  "compress/gzip"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "math"
+  "net/http"
+  "sort"
+  "strconv"
+  "time"
+
+  // Go Prometheus libraries
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Aggregation temporality of the sums and histograms. Prometheus counters are
+// cumulative
+const AGGREGATION_TEMPORALITY_CUMULATIVE = 2
+
+// Name of the instrumentation scope of the metrics
+const SCOPE_NAME = "keedio/cloudera_exporter"
+
+// Compressions of the export requests
+const (
+  COMPRESSION_GZIP = "gzip"
+  COMPRESSION_NONE = "none"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Settings of the push to the OpenTelemetry collector
+type Options struct {
+  // OTLP/HTTP metrics URL (e.g. http://otel-collector:4318/v1/metrics)
+  Endpoint string
+  Interval time.Duration
+  Timeout time.Duration
+  Headers map[string]string
+  // Compression of the requests: gzip or none
+  Compression string
+  // Attributes of the resource (service.name...)
+  Resource_attributes map[string]string
+}
+
+// ExportMetricsServiceRequest of the OTLP protocol, in its JSON encoding
+type export_request struct {
+  Resource_metrics []resource_metrics `json:"resourceMetrics"`
+}
+
+type resource_metrics struct {
+  Resource resource `json:"resource"`
+  Scope_metrics []scope_metrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+  Attributes []key_value `json:"attributes"`
+}
+
+type scope_metrics struct {
+  Scope scope `json:"scope"`
+  Metrics []metric `json:"metrics"`
+}
+
+type scope struct {
+  Name string `json:"name"`
+  Version string `json:"version,omitempty"`
+}
+
+type key_value struct {
+  Key string `json:"key"`
+  Value any_value `json:"value"`
+}
+
+type any_value struct {
+  String_value string `json:"stringValue"`
+}
+
+type metric struct {
+  Name string `json:"name"`
+  Description string `json:"description,omitempty"`
+  Gauge *gauge `json:"gauge,omitempty"`
+  Sum *sum `json:"sum,omitempty"`
+  Histogram *histogram `json:"histogram,omitempty"`
+  Summary *summary `json:"summary,omitempty"`
+}
+
+type gauge struct {
+  Data_points []number_data_point `json:"dataPoints"`
+}
+
+type sum struct {
+  Data_points []number_data_point `json:"dataPoints"`
+  Aggregation_temporality int `json:"aggregationTemporality"`
+  Is_monotonic bool `json:"isMonotonic"`
+}
+
+type histogram struct {
+  Data_points []histogram_data_point `json:"dataPoints"`
+  Aggregation_temporality int `json:"aggregationTemporality"`
+}
+
+type summary struct {
+  Data_points []summary_data_point `json:"dataPoints"`
+}
+
+// The 64 bits integers are strings in the JSON encoding of OTLP
+type number_data_point struct {
+  Attributes []key_value `json:"attributes"`
+  Start_time_unix_nano string `json:"startTimeUnixNano,omitempty"`
+  Time_unix_nano string `json:"timeUnixNano"`
+  As_double float64 `json:"asDouble"`
+}
+
+type histogram_data_point struct {
+  Attributes []key_value `json:"attributes"`
+  Start_time_unix_nano string `json:"startTimeUnixNano"`
+  Time_unix_nano string `json:"timeUnixNano"`
+  Count string `json:"count"`
+  Sum float64 `json:"sum"`
+  Bucket_counts []string `json:"bucketCounts"`
+  Explicit_bounds []float64 `json:"explicitBounds"`
+}
+
+type summary_data_point struct {
+  Attributes []key_value `json:"attributes"`
+  Start_time_unix_nano string `json:"startTimeUnixNano"`
+  Time_unix_nano string `json:"timeUnixNano"`
+  Count string `json:"count"`
+  Sum float64 `json:"sum"`
+  Quantile_values []quantile_value `json:"quantileValues"`
+}
+
+type quantile_value struct {
+  Quantile float64 `json:"quantile"`
+  Value float64 `json:"value"`
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the time in nanoseconds as a string
+func unix_nano(t time.Time) string {
+  return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+
+// Returns the labels of a metric as OTLP attributes
+func label_attributes(labels []*dto.LabelPair) []key_value {
+  attributes := make([]key_value, 0, len(labels))
+  for _, label := range labels {
+    attributes = append(attributes, key_value{label.GetName(), any_value{label.GetValue()}})
+  }
+  return attributes
+}
+
+
+// Returns the time of the sample: its timestamp or the collection time
+func sample_time(m *dto.Metric, now time.Time) time.Time {
+  if m.TimestampMs != nil {
+    return time.Unix(0, m.GetTimestampMs() * int64(time.Millisecond))
+  }
+  return now
+}
+
+
+// Convert a Prometheus metric family to an OTLP metric. Counters are
+// cumulative sums started at start_time, gauges and untyped metrics gauges.
+// Returns false if the family has no samples
+func convert_family(family *dto.MetricFamily, start_time time.Time, now time.Time) (metric, bool) {
+  converted := metric{Name: family.GetName(), Description: family.GetHelp()}
+  start := unix_nano(start_time)
+
+  switch family.GetType() {
+  case dto.MetricType_COUNTER:
+    converted.Sum = &sum{Aggregation_temporality: AGGREGATION_TEMPORALITY_CUMULATIVE, Is_monotonic: true}
+    for _, m := range family.Metric {
+      converted.Sum.Data_points = append(converted.Sum.Data_points, number_data_point {
+        label_attributes(m.Label), start, unix_nano(sample_time(m, now)), m.GetCounter().GetValue(),
+      })
+    }
+    return converted, len(converted.Sum.Data_points) > 0
+
+  case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+    converted.Gauge = &gauge{}
+    for _, m := range family.Metric {
+      value := m.GetGauge().GetValue()
+      if m.Untyped != nil {
+        value = m.GetUntyped().GetValue()
+      }
+      converted.Gauge.Data_points = append(converted.Gauge.Data_points, number_data_point {
+        Attributes: label_attributes(m.Label), Time_unix_nano: unix_nano(sample_time(m, now)), As_double: value,
+      })
+    }
+    return converted, len(converted.Gauge.Data_points) > 0
+
+  case dto.MetricType_HISTOGRAM:
+    converted.Histogram = &histogram{Aggregation_temporality: AGGREGATION_TEMPORALITY_CUMULATIVE}
+    for _, m := range family.Metric {
+      h := m.GetHistogram()
+      // Prometheus buckets are cumulative, OTLP ones are not. The +Inf
+      // bucket is implicit in both
+      point := histogram_data_point {
+        Attributes: label_attributes(m.Label),
+        Start_time_unix_nano: start,
+        Time_unix_nano: unix_nano(sample_time(m, now)),
+        Count: strconv.FormatUint(h.GetSampleCount(), 10),
+        Sum: h.GetSampleSum(),
+      }
+      previous := uint64(0)
+      for _, bucket := range h.Bucket {
+        if math.IsInf(bucket.GetUpperBound(), 1) {
+          continue
+        }
+        point.Explicit_bounds = append(point.Explicit_bounds, bucket.GetUpperBound())
+        point.Bucket_counts = append(point.Bucket_counts, strconv.FormatUint(bucket.GetCumulativeCount() - previous, 10))
+        previous = bucket.GetCumulativeCount()
+      }
+      point.Bucket_counts = append(point.Bucket_counts, strconv.FormatUint(h.GetSampleCount() - previous, 10))
+      converted.Histogram.Data_points = append(converted.Histogram.Data_points, point)
+    }
+    return converted, len(converted.Histogram.Data_points) > 0
+
+  case dto.MetricType_SUMMARY:
+    converted.Summary = &summary{}
+    for _, m := range family.Metric {
+      s := m.GetSummary()
+      point := summary_data_point {
+        Attributes: label_attributes(m.Label),
+        Start_time_unix_nano: start,
+        Time_unix_nano: unix_nano(sample_time(m, now)),
+        Count: strconv.FormatUint(s.GetSampleCount(), 10),
+        Sum: s.GetSampleSum(),
+      }
+      for _, q := range s.Quantile {
+        point.Quantile_values = append(point.Quantile_values, quantile_value{q.GetQuantile(), q.GetValue()})
+      }
+      converted.Summary.Data_points = append(converted.Summary.Data_points, point)
+    }
+    return converted, len(converted.Summary.Data_points) > 0
+  }
+  return converted, false
+}
+
+
+// Encode the metric families as an OTLP/HTTP JSON export request
+func Encode_metrics(families []*dto.MetricFamily, resource_attributes map[string]string, scope_version string, start_time time.Time, now time.Time) ([]byte, error) {
+  request := export_request{[]resource_metrics{{
+    Scope_metrics: []scope_metrics{{Scope: scope{SCOPE_NAME, scope_version}, Metrics: []metric{}}},
+  }}}
+  resource_metric := &request.Resource_metrics[0]
+  resource_metric.Resource.Attributes = []key_value{}
+  keys := make([]string, 0, len(resource_attributes))
+  for key := range resource_attributes {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  for _, key := range keys {
+    resource_metric.Resource.Attributes = append(resource_metric.Resource.Attributes, key_value{key, any_value{resource_attributes[key]}})
+  }
+  for _, family := range families {
+    if converted, ok := convert_family(family, start_time, now); ok {
+      resource_metric.Scope_metrics[0].Metrics = append(resource_metric.Scope_metrics[0].Metrics, converted)
+    }
+  }
+  return json.Marshal(request)
+}
+
+
+// Send the export request to the OpenTelemetry collector, compressed with
+// the compression of the options. A collector that rejects the compression
+// (415 Unsupported Media Type) is sent the request again uncompressed.
+// Returns the compression the collector accepted, for the next requests
+func Push(ctx context.Context, client *http.Client, options Options, body []byte) (string, error) {
+  if options.Compression == COMPRESSION_GZIP {
+    err := push(ctx, client, options, body)
+    if status, ok := err.(http_status_error); !ok || status.code != http.StatusUnsupportedMediaType {
+      return COMPRESSION_GZIP, err
+    }
+  }
+  options.Compression = COMPRESSION_NONE
+  return COMPRESSION_NONE, push(ctx, client, options, body)
+}
+
+
+// Invalid HTTP status of the OTLP endpoint
+type http_status_error struct {
+  code int
+  status string
+}
+
+func (err http_status_error) Error() string {
+  return fmt.Sprintf("Invalid HTTP response code from the OTLP endpoint: %s", err.status)
+}
+
+
+// Send the export request once, with the compression of the options
+func push(ctx context.Context, client *http.Client, options Options, body []byte) error {
+  encoding := ""
+  if options.Compression == COMPRESSION_GZIP {
+    var compressed bytes.Buffer
+    writer := gzip.NewWriter(&compressed)
+    if _, err := writer.Write(body); err != nil {
+      return err
+    }
+    if err := writer.Close(); err != nil {
+      return err
+    }
+    body, encoding = compressed.Bytes(), COMPRESSION_GZIP
+  }
+
+  req, err := http.NewRequest(http.MethodPost, options.Endpoint, bytes.NewReader(body))
+  if err != nil {
+    return err
+  }
+  req = req.WithContext(ctx)
+  req.Header.Set("Content-Type", "application/json")
+  if encoding != "" {
+    req.Header.Set("Content-Encoding", encoding)
+  }
+  for name, value := range options.Headers {
+    req.Header.Set(name, value)
+  }
+
+  res, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  defer res.Body.Close()
+  // Drain the body so the connection is reused
+  io.Copy(ioutil.Discard, res.Body)
+  if res.StatusCode < 200 || res.StatusCode >= 300 {
+    return http_status_error{res.StatusCode, res.Status}
+  }
+  return nil
+}
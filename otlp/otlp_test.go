@@ -0,0 +1,93 @@
+/*
+ *
+ * title           :otlp/otlp_test.go
+ * description     :Tests of the push of the metrics to an OpenTelemetry
+ *                  collector
+ * author          :Enes Erdoğan
+ * date            :2025/12/01
+ * version         :1.0
+ *
+ */
+package otlp
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "compress/gzip"
+  "context"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// The requests are compressed with gzip, and sent again uncompressed to a
+// collector that rejects gzip
+func TestPush_compression(t *testing.T) {
+  body := []byte(`{"resourceMetrics":[]}`)
+  for _, c := range []struct {
+    name string
+    compression string
+    accept_gzip bool
+    want_compression string
+    want_encodings []string
+  }{
+    {"gzip", COMPRESSION_GZIP, true, COMPRESSION_GZIP, []string{"gzip"}},
+    {"gzip rejected", COMPRESSION_GZIP, false, COMPRESSION_NONE, []string{"gzip", ""}},
+    {"none", COMPRESSION_NONE, true, COMPRESSION_NONE, []string{""}},
+  } {
+    t.Run(c.name, func(t *testing.T) {
+      var encodings []string
+      server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        encoding := r.Header.Get("Content-Encoding")
+        encodings = append(encodings, encoding)
+        if encoding == "gzip" && !c.accept_gzip {
+          w.WriteHeader(http.StatusUnsupportedMediaType)
+          return
+        }
+        reader := r.Body
+        if encoding == "gzip" {
+          gzip_reader, err := gzip.NewReader(r.Body)
+          if err != nil {
+            t.Errorf("Cannot read the gzip request: %s", err)
+            w.WriteHeader(http.StatusBadRequest)
+            return
+          }
+          reader = gzip_reader
+        }
+        if received, _ := ioutil.ReadAll(reader); string(received) != string(body) {
+          t.Errorf("Received %q, want %q", received, body)
+        }
+      }))
+      defer server.Close()
+
+      options := Options{Endpoint: server.URL, Compression: c.compression}
+      compression, err := Push(context.Background(), server.Client(), options, body)
+      if err != nil {
+        t.Fatal(err)
+      }
+      if compression != c.want_compression {
+        t.Errorf("Compression %q accepted, want %q", compression, c.want_compression)
+      }
+      if len(encodings) != len(c.want_encodings) {
+        t.Fatalf("Requests with the encodings %q, want %q", encodings, c.want_encodings)
+      }
+      for i := range encodings {
+        if encodings[i] != c.want_encodings[i] {
+          t.Errorf("Requests with the encodings %q, want %q", encodings, c.want_encodings)
+        }
+      }
+    })
+  }
+}
@@ -0,0 +1,108 @@
+/*
+ *
+ * title           :otlp_push.go
+ * description     :Periodic push of the collected metrics to an
+ *                  OpenTelemetry collector, alongside the /metrics endpoint
+ * author          :Enes Erdoğan
+ * date            :2025/05/12
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "net/http"
+  "time"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+  log "keedio/cloudera_exporter/logger"
+  "keedio/cloudera_exporter/otlp"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/common/version"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+var (
+  otlp_pushes_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "otlp_pushes_total",
+    Help:      "Total number of pushes of the metrics to the OTLP endpoint.",
+  })
+  otlp_push_errors_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "otlp_push_errors_total",
+    Help:      "Total number of failed pushes of the metrics to the OTLP endpoint.",
+  })
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Collect the metrics with the current configuration and push them to the
+// OTLP endpoint
+func otlp_push(client *http.Client, options *otlp.Options, metrics cl.Metrics, start_time time.Time) error {
+  // The collection has the interval to finish, as a scrape has its timeout
+  ctx, cancel := context.WithTimeout(shutdown_ctx, options.Interval)
+  defer cancel()
+  state := get_serving_state()
+  families, err := collect_once(ctx, state.config, metrics, state.scrapers)
+  if err != nil {
+    return err
+  }
+  record_cardinality(families)
+  body, err := otlp.Encode_metrics(families, options.Resource_attributes, version.Version, start_time, time.Now())
+  if err != nil {
+    return err
+  }
+  // Once the collector rejects the compression, the next pushes are not
+  // compressed
+  compression, err := otlp.Push(ctx, client, *options, body)
+  if compression != options.Compression {
+    log.Warn_msg("The OTLP endpoint %s does not accept %s requests, pushing them uncompressed", options.Endpoint, options.Compression)
+    options.Compression = compression
+  }
+  return err
+}
+
+
+// Push the metrics to the OTLP endpoint on every interval. The collection is
+// the same of the /metrics endpoint, which keeps serving the Prometheus pulls
+func otlp_push_loop(options otlp.Options) {
+  prometheus.MustRegister(otlp_pushes_total, otlp_push_errors_total)
+  client := &http.Client{Timeout: options.Timeout}
+  metrics := cl.NewMetrics()
+  start_time := time.Now()
+
+  log.Info_msg("Pushing the metrics to %s every %s", options.Endpoint, options.Interval)
+  ticker := time.NewTicker(options.Interval)
+  defer ticker.Stop()
+  for {
+    otlp_pushes_total.Inc()
+    if err := otlp_push(client, &options, metrics, start_time); err != nil {
+      log.Err_msg("Failed to push the metrics to %s: %s", options.Endpoint, err)
+      otlp_push_errors_total.Inc()
+    }
+    <-ticker.C
+  }
+}
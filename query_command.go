@@ -0,0 +1,199 @@
+/*
+ *
+ * title           :query_command.go
+ * description     :Query command: prints a historical window of a metric
+ *                  fetched from Cloudera Manager
+ * author          :Enes Erdoğan
+ * date            :2025/03/10
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+  "time"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+
+  // Go external libraries
+  "gopkg.in/alecthomas/kingpin.v2"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Datapoint of the JSON output, with the human-readable value
+type formatted_datapoint struct {
+  cl.Datapoint
+  Display string `json:"display,omitempty"`
+}
+
+// TimeSerie of the JSON output
+type formatted_history struct {
+  Cluster string `json:"cluster"`
+  Entity_name string `json:"entityName"`
+  Datapoints []formatted_datapoint `json:"datapoints"`
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Commands and flags of the query command
+var (
+  serve_command = kingpin.Command("serve", "Publish the metrics for Prometheus (default).").Default()
+  query_command = kingpin.Command("query", "Print a historical window of a metric fetched from Cloudera Manager.")
+  query_metric = query_command.Flag("metric", "Name of the metric (e.g. kbdi_zookeeper_alerts_rate).").String()
+  query_tsquery = query_command.Flag("tsquery", "TSquery to run instead of the one of a metric.").String()
+  query_from = query_command.Flag("from", "Start of the window: RFC3339 time or duration before now (e.g. 6h).").Required().String()
+  query_to = query_command.Flag("to", "End of the window: RFC3339 time or duration before now.").Default("0s").String()
+  query_format = query_command.Flag("format", "Output format: table, csv or json.").Default("table").Enum("table", "csv", "json")
+  query_raw = query_command.Flag("raw", "Print the values as Cloudera Manager returns them, without human-readable units.").Bool()
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Parse a RFC3339 time or a duration before now
+func parse_query_time(value string, now time.Time) (time.Time, error) {
+  if t, err := time.Parse(time.RFC3339, value); err == nil {
+    return t, nil
+  }
+  duration, err := time.ParseDuration(value)
+  if err != nil {
+    return time.Time{}, fmt.Errorf("Invalid time %q: use a RFC3339 time or a duration before now", value)
+  }
+  return now.Add(-duration), nil
+}
+
+
+// Fetch the window of the metric and print it
+func run_query_command(output io.Writer) error {
+  query := *query_tsquery
+  if query == "" {
+    var ok bool
+    if query, ok = cl.Get_metric_query(*query_metric); !ok {
+      return fmt.Errorf("Unknown metric %q. Available metrics: %s", *query_metric, strings.Join(cl.Get_metric_names(), ", "))
+    }
+  }
+
+  now := time.Now()
+  from, err := parse_query_time(*query_from, now)
+  if err != nil {
+    return err
+  }
+  to, err := parse_query_time(*query_to, now)
+  if err != nil {
+    return err
+  }
+  if !from.Before(to) {
+    return fmt.Errorf("The start of the window (%s) must be before its end (%s)", from, to)
+  }
+
+  history, err := cl.Query_timeseries_history(context.Background(), config.Connection, query, from, to)
+  if err != nil {
+    return err
+  }
+
+  // The values of the metrics are printed in their unit (durations, bytes),
+  // those of a raw TSquery with SI suffixes
+  format_value := format_raw
+  if !*query_raw {
+    unit := ""
+    if *query_tsquery == "" {
+      unit = cl.Get_metric_unit(*query_metric)
+    }
+    format_value = func(value float64) string { return format_human(value, unit) }
+  }
+
+  switch *query_format {
+  case "csv":
+    return print_history_csv(output, history)
+  case "json":
+    return print_history_json(output, history, *query_raw, format_value)
+  default:
+    return print_history_table(output, history, format_value)
+  }
+}
+
+
+func print_history_table(output io.Writer, history []cl.Timeseries_history, format_value func(float64) string) error {
+  writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+  fmt.Fprintln(writer, "CLUSTER\tENTITY\tTIMESTAMP\tVALUE")
+  for _, serie := range history {
+    for _, datapoint := range serie.Datapoints {
+      fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", serie.Cluster, serie.Entity_name, datapoint.Timestamp.Format(time.RFC3339), format_value(datapoint.Value))
+    }
+  }
+  return writer.Flush()
+}
+
+
+// Print the history as JSON. The values are kept as numbers, with their
+// human-readable form in display unless the output is raw
+func print_history_json(output io.Writer, history []cl.Timeseries_history, raw bool, format_value func(float64) string) error {
+  formatted := make([]formatted_history, 0, len(history))
+  for _, serie := range history {
+    datapoints := make([]formatted_datapoint, 0, len(serie.Datapoints))
+    for _, datapoint := range serie.Datapoints {
+      display := ""
+      if !raw {
+        display = format_value(datapoint.Value)
+      }
+      datapoints = append(datapoints, formatted_datapoint{datapoint, display})
+    }
+    formatted = append(formatted, formatted_history{serie.Cluster, serie.Entity_name, datapoints})
+  }
+  encoder := json.NewEncoder(output)
+  encoder.SetIndent("", "  ")
+  return encoder.Encode(formatted)
+}
+
+
+// Print the history as CSV, always with the raw values so it can be loaded
+// in other tools
+func print_history_csv(output io.Writer, history []cl.Timeseries_history) error {
+  writer := csv.NewWriter(output)
+  writer.Write([]string{"cluster", "entity", "timestamp", "value"})
+  for _, serie := range history {
+    for _, datapoint := range serie.Datapoints {
+      writer.Write([]string{serie.Cluster, serie.Entity_name, datapoint.Timestamp.Format(time.RFC3339), strconv.FormatFloat(datapoint.Value, 'g', -1, 64)})
+    }
+  }
+  writer.Flush()
+  return writer.Error()
+}
+
+
+// Print the metric window on the standard output
+func query_main() {
+  if err := run_query_command(os.Stdout); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
@@ -0,0 +1,186 @@
+/*
+ *
+ * title           :reload.go
+ * description     :Hot reload of the configuration on SIGHUP or a POST to
+ *                  /-/reload, without dropping the in-flight scrapes
+ * author          :Enes Erdoğan
+ * date            :2025/06/02
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "crypto/subtle"
+  "fmt"
+  "net/http"
+  "os"
+  "os/signal"
+  "runtime"
+  "sync"
+  "sync/atomic"
+  "syscall"
+  "time"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+  cp "keedio/cloudera_exporter/config_parser"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Configuration and scrapers the exporter is serving with. Each scrape takes
+// the current one when it starts, so a reload does not affect it
+type serving_state struct {
+  config *cp.CE_config
+  scrapers []cl.Scraper
+  // Closed when the state is replaced, to stop its background tasks
+  stop chan struct{}
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Current serving state (*serving_state)
+var serving atomic.Value
+
+// Only one reload at a time
+var reload_mutex sync.Mutex
+
+var (
+  config_last_reload_successful = prometheus.NewGauge(prometheus.GaugeOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "config_last_reload_successful",
+    Help:      "Whether the last configuration reload attempt was successful.",
+  })
+  config_last_reload_success_timestamp_seconds = prometheus.NewGauge(prometheus.GaugeOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "config_last_reload_success_timestamp_seconds",
+    Help:      "Timestamp of the last successful configuration reload.",
+  })
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the current serving state
+func get_serving_state() *serving_state {
+  return serving.Load().(*serving_state)
+}
+
+
+// Serve with the configuration and start its background tasks. The previous
+// state keeps serving the scrapes already started with it
+func set_serving_state(new_config *cp.CE_config) {
+  state := &serving_state{new_config, register_scrapers(new_config), make(chan struct{})}
+  if new_config.Secrets_refresh_interval > 0 {
+    go new_config.Connection.Credentials.Watch(new_config.Secrets_refresh_interval, state.stop)
+  }
+
+  previous, _ := serving.Load().(*serving_state)
+  serving.Store(state)
+  if previous != nil {
+    close(previous.stop)
+  }
+}
+
+
+// Read the config file again and serve with it. The current configuration is
+// kept if the new one is not valid
+func reload_config() error {
+  reload_mutex.Lock()
+  defer reload_mutex.Unlock()
+
+  log.Info_msg("Reloading the configuration")
+  // The feature flags are set again, the ones not in the config file to
+  // their default, once the new configuration is valid
+  new_config, err := load_config()
+  if err == nil {
+    err = cl.Set_feature_flags(new_config.Feature_flags)
+  }
+  if err != nil {
+    config_last_reload_successful.Set(0)
+    log.Err_msg("Configuration not reloaded, keeping the current one: %s", err)
+    return err
+  }
+  runtime.GOMAXPROCS(new_config.Num_procs)
+  set_serving_state(new_config)
+  config_last_reload_successful.Set(1)
+  config_last_reload_success_timestamp_seconds.Set(float64(time.Now().Unix()))
+  log.Ok_msg("Configuration reloaded")
+  return nil
+}
+
+
+// Reload the configuration on every SIGHUP
+func reload_on_sighup() {
+  hup := make(chan os.Signal, 1)
+  signal.Notify(hup, syscall.SIGHUP)
+  for range hup {
+    reload_config()
+  }
+}
+
+
+// Returns true if the request to a control endpoint (/-/reload, /-/standby,
+// /-/activate...) can be served: the endpoint is enabled, the request is a
+// POST and it has the token as a bearer token. A blank token authorizes no
+// request, as the endpoints can't be enabled without one. Otherwise the error
+// response is written
+func authorize_control_request(w http.ResponseWriter, r *http.Request, enabled bool, token string) bool {
+  if !enabled {
+    http.NotFound(w, r)
+    return false
+  }
+  if r.Method != http.MethodPost {
+    w.Header().Set("Allow", http.MethodPost)
+    http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+    return false
+  }
+  if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer " + token)) != 1 {
+    http.Error(w, "Unauthorized", http.StatusUnauthorized)
+    return false
+  }
+  return true
+}
+
+
+// Create and returns a Handler that reloads the configuration. Only POST
+// requests are accepted, and only if the endpoint is enabled, with the token
+// as a bearer token
+func newReloadHandler() http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    current := get_serving_state().config
+    if !authorize_control_request(w, r, current.Reload_endpoint, current.Reload_token) {
+      return
+    }
+    if err := reload_config(); err != nil {
+      http.Error(w, fmt.Sprintf("Configuration not reloaded: %s", err), http.StatusInternalServerError)
+      return
+    }
+    fmt.Fprintln(w, "Configuration reloaded")
+  }
+}
@@ -0,0 +1,266 @@
+/*
+ *
+ * title           :remote_write/remote_write.go
+ * description     :Push of the collected metrics to a Prometheus-compatible
+ *                  endpoint (Mimir, Thanos, VictoriaMetrics...) with the
+ *                  remote write protocol (protobuf and snappy)
+ * author          :Enes Erdoğan
+ * date            :2025/11/10
+ * version         :1.0
+ *
+ */
+package remote_write
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "bytes"
+  "context"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "math"
+  "net/http"
+  "sort"
+  "strconv"
+  "time"
+
+  // Go external libraries
+  "github.com/golang/snappy"
+
+  // Go Prometheus libraries
+  dto "github.com/prometheus/client_model/go"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Version of the remote write protocol, sent in its header
+const PROTOCOL_VERSION = "0.1.0"
+
+// Protobuf wire types of the fields of the WriteRequest
+const (
+  wire_varint = 0
+  wire_fixed64 = 1
+  wire_bytes = 2
+)
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Settings of the push to the remote write endpoint
+type Options struct {
+  // Remote write URL (e.g. http://mimir:9009/api/v1/push)
+  Url string
+  Interval time.Duration
+  Timeout time.Duration
+  Headers map[string]string
+}
+
+// Label and sample of a series of the WriteRequest. Each series of a push
+// has a single sample
+type label struct {
+  name string
+  value string
+}
+
+type series struct {
+  labels []label
+  value float64
+  timestamp_ms int64
+}
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the time of the sample in milliseconds: its timestamp or the
+// collection time
+func sample_time_ms(m *dto.Metric, now time.Time) int64 {
+  if m.TimestampMs != nil {
+    return m.GetTimestampMs()
+  }
+  return now.UnixNano() / int64(time.Millisecond)
+}
+
+
+// Returns a series with the name and the labels of the metric, plus the
+// extra label if it is not empty (le, quantile). The labels are sorted by
+// name, as the protocol requires
+func new_series(name string, m *dto.Metric, extra label, value float64, timestamp_ms int64) series {
+  labels := make([]label, 0, len(m.Label) + 2)
+  labels = append(labels, label{"__name__", name})
+  for _, pair := range m.Label {
+    labels = append(labels, label{pair.GetName(), pair.GetValue()})
+  }
+  if extra.name != "" {
+    labels = append(labels, extra)
+  }
+  sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+  return series{labels, value, timestamp_ms}
+}
+
+
+// Returns the value of the le and quantile labels, as Prometheus formats them
+func format_bound(value float64) string {
+  if math.IsInf(value, 1) {
+    return "+Inf"
+  }
+  return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+
+// Convert a Prometheus metric family to the series Prometheus would store
+// from a scrape: one for counters, gauges and untyped metrics, and the
+// _bucket, _sum and _count series for histograms and summaries
+func convert_family(family *dto.MetricFamily, now time.Time) []series {
+  name := family.GetName()
+  converted := []series{}
+  for _, m := range family.Metric {
+    timestamp_ms := sample_time_ms(m, now)
+    switch family.GetType() {
+    case dto.MetricType_COUNTER:
+      converted = append(converted, new_series(name, m, label{}, m.GetCounter().GetValue(), timestamp_ms))
+
+    case dto.MetricType_GAUGE:
+      converted = append(converted, new_series(name, m, label{}, m.GetGauge().GetValue(), timestamp_ms))
+
+    case dto.MetricType_UNTYPED:
+      converted = append(converted, new_series(name, m, label{}, m.GetUntyped().GetValue(), timestamp_ms))
+
+    case dto.MetricType_HISTOGRAM:
+      h := m.GetHistogram()
+      has_inf := false
+      for _, bucket := range h.Bucket {
+        has_inf = has_inf || math.IsInf(bucket.GetUpperBound(), 1)
+        converted = append(converted, new_series(name + "_bucket", m, label{"le", format_bound(bucket.GetUpperBound())}, float64(bucket.GetCumulativeCount()), timestamp_ms))
+      }
+      // The +Inf bucket is implicit in the client metrics
+      if !has_inf {
+        converted = append(converted, new_series(name + "_bucket", m, label{"le", "+Inf"}, float64(h.GetSampleCount()), timestamp_ms))
+      }
+      converted = append(converted,
+        new_series(name + "_sum", m, label{}, h.GetSampleSum(), timestamp_ms),
+        new_series(name + "_count", m, label{}, float64(h.GetSampleCount()), timestamp_ms),
+      )
+
+    case dto.MetricType_SUMMARY:
+      s := m.GetSummary()
+      for _, q := range s.Quantile {
+        converted = append(converted, new_series(name, m, label{"quantile", format_bound(q.GetQuantile())}, q.GetValue(), timestamp_ms))
+      }
+      converted = append(converted,
+        new_series(name + "_sum", m, label{}, s.GetSampleSum(), timestamp_ms),
+        new_series(name + "_count", m, label{}, float64(s.GetSampleCount()), timestamp_ms),
+      )
+    }
+  }
+  return converted
+}
+
+
+// Append the key of a protobuf field
+func append_key(buf []byte, field int, wire_type int) []byte {
+  return append_varint(buf, uint64(field << 3 | wire_type))
+}
+
+
+func append_varint(buf []byte, value uint64) []byte {
+  var encoded [binary.MaxVarintLen64]byte
+  return append(buf, encoded[:binary.PutUvarint(encoded[:], value)]...)
+}
+
+
+// Append a length-delimited field (string or embedded message)
+func append_bytes(buf []byte, field int, value []byte) []byte {
+  buf = append_key(buf, field, wire_bytes)
+  buf = append_varint(buf, uint64(len(value)))
+  return append(buf, value...)
+}
+
+
+// Encode the series as a TimeSeries message:
+//   TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//   Label { string name = 1; string value = 2; }
+//   Sample { double value = 1; int64 timestamp = 2; }
+func encode_series(buf []byte, s series) []byte {
+  var message []byte
+  for _, l := range s.labels {
+    var encoded []byte
+    encoded = append_bytes(encoded, 1, []byte(l.name))
+    encoded = append_bytes(encoded, 2, []byte(l.value))
+    message = append_bytes(message, 1, encoded)
+  }
+
+  var sample []byte
+  var value [8]byte
+  binary.LittleEndian.PutUint64(value[:], math.Float64bits(s.value))
+  sample = append_key(sample, 1, wire_fixed64)
+  sample = append(sample, value[:]...)
+  sample = append_key(sample, 2, wire_varint)
+  sample = append_varint(sample, uint64(s.timestamp_ms))
+  message = append_bytes(message, 2, sample)
+
+  return append_bytes(buf, 1, message)
+}
+
+
+// Encode the metric families as a remote write WriteRequest, compressed with
+// snappy (block format):
+//   WriteRequest { repeated TimeSeries timeseries = 1; }
+// Returns the request and its number of series
+func Encode_metrics(families []*dto.MetricFamily, now time.Time) ([]byte, int) {
+  var request []byte
+  num_series := 0
+  for _, family := range families {
+    for _, s := range convert_family(family, now) {
+      request = encode_series(request, s)
+      num_series++
+    }
+  }
+  return snappy.Encode(nil, request), num_series
+}
+
+
+// Send the write request to the remote write endpoint
+func Push(ctx context.Context, client *http.Client, options Options, body []byte) error {
+  req, err := http.NewRequest(http.MethodPost, options.Url, bytes.NewReader(body))
+  if err != nil {
+    return err
+  }
+  req = req.WithContext(ctx)
+  req.Header.Set("Content-Encoding", "snappy")
+  req.Header.Set("Content-Type", "application/x-protobuf")
+  req.Header.Set("X-Prometheus-Remote-Write-Version", PROTOCOL_VERSION)
+  for name, value := range options.Headers {
+    req.Header.Set(name, value)
+  }
+
+  res, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  defer res.Body.Close()
+  if res.StatusCode < 200 || res.StatusCode >= 300 {
+    // The endpoints explain the rejected samples in the body
+    message, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+    return fmt.Errorf("Invalid HTTP response code from the remote write endpoint: %s: %s", res.Status, bytes.TrimSpace(message))
+  }
+  // Drain the body so the connection is reused
+  io.Copy(ioutil.Discard, res.Body)
+  return nil
+}
@@ -0,0 +1,107 @@
+/*
+ *
+ * title           :remote_write_push.go
+ * description     :Periodic push of the collected metrics to a Prometheus
+ *                  remote write endpoint, alongside the /metrics endpoint
+ * author          :Enes Erdoğan
+ * date            :2025/11/10
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "net/http"
+  "time"
+
+  // Own libraries
+  cl "keedio/cloudera_exporter/collector"
+  log "keedio/cloudera_exporter/logger"
+  "keedio/cloudera_exporter/remote_write"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+var (
+  remote_write_pushes_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "remote_write_pushes_total",
+    Help:      "Total number of pushes of the metrics to the remote write endpoint.",
+  })
+  remote_write_push_errors_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "remote_write_push_errors_total",
+    Help:      "Total number of failed pushes of the metrics to the remote write endpoint.",
+  })
+  remote_write_series_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "remote_write_series_total",
+    Help:      "Total number of series sent to the remote write endpoint.",
+  })
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Collect the metrics with the current configuration and push them to the
+// remote write endpoint
+func remote_write_push(client *http.Client, options remote_write.Options, metrics cl.Metrics) error {
+  // The collection has the interval to finish, as a scrape has its timeout
+  ctx, cancel := context.WithTimeout(shutdown_ctx, options.Interval)
+  defer cancel()
+  state := get_serving_state()
+  families, err := collect_once(ctx, state.config, metrics, state.scrapers)
+  if err != nil {
+    return err
+  }
+  record_cardinality(families)
+  body, num_series := remote_write.Encode_metrics(families, time.Now())
+  if err := remote_write.Push(ctx, client, options, body); err != nil {
+    return err
+  }
+  remote_write_series_total.Add(float64(num_series))
+  return nil
+}
+
+
+// Push the metrics to the remote write endpoint on every interval, for the
+// Prometheus servers that can't reach the exporter. The collection is the
+// same of the /metrics endpoint, which keeps serving the Prometheus pulls
+func remote_write_push_loop(options remote_write.Options) {
+  prometheus.MustRegister(remote_write_pushes_total, remote_write_push_errors_total, remote_write_series_total)
+  client := &http.Client{Timeout: options.Timeout}
+  metrics := cl.NewMetrics()
+
+  log.Info_msg("Pushing the metrics to the remote write endpoint %s every %s", options.Url, options.Interval)
+  ticker := time.NewTicker(options.Interval)
+  defer ticker.Stop()
+  for {
+    remote_write_pushes_total.Inc()
+    if err := remote_write_push(client, options, metrics); err != nil {
+      log.Err_msg("Failed to push the metrics to %s: %s", options.Url, err)
+      remote_write_push_errors_total.Inc()
+    }
+    <-ticker.C
+  }
+}
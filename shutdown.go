@@ -0,0 +1,94 @@
+/*
+ *
+ * title           :shutdown.go
+ * description     :Graceful shutdown of the exporter on SIGTERM and SIGINT,
+ *                  so the rolling restarts don't fail the scrapes in progress
+ * author          :Enes Erdoğan
+ * date            :2025/10/13
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "net/http"
+  "os"
+  "os/signal"
+  "syscall"
+  "time"
+
+  // Own libraries
+  log "keedio/cloudera_exporter/logger"
+)
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+// Parent context of the Cloudera Manager requests of the scrapes and of the
+// background collections, cancelled when the shutdown grace period expires
+var shutdown_ctx, cancel_in_flight = context.WithCancel(context.Background())
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns a context that is cancelled with the parent or when the shutdown
+// grace period expires
+func with_shutdown(parent context.Context) (context.Context, context.CancelFunc) {
+  ctx, cancel := context.WithCancel(parent)
+  go func() {
+    select {
+    case <-shutdown_ctx.Done():
+      cancel()
+    case <-ctx.Done():
+    }
+  }()
+  return ctx, cancel
+}
+
+
+// Serve the HTTP requests until SIGTERM or SIGINT. Then the listener is
+// closed, so no new scrape starts, and the scrapes in progress have the grace
+// period to finish before their Cloudera Manager requests are cancelled. A
+// second signal stops the exporter at once
+func serve_until_signal(server *http.Server, grace_period time.Duration) error {
+  stop := make(chan os.Signal, 1)
+  signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+
+  served := make(chan error, 1)
+  go func() { served <- server.ListenAndServe() }()
+
+  select {
+  case err := <-served:
+    return err
+  case sig := <-stop:
+    log.Info_msg("Received %s, shutting down (grace period %s)", sig, grace_period)
+  }
+  signal.Stop(stop)
+  defer log.Flush()
+
+  ctx, cancel := context.WithTimeout(context.Background(), grace_period)
+  defer cancel()
+  if err := server.Shutdown(ctx); err != nil {
+    log.Warn_msg("Shutdown grace period expired, cancelling the scrapes in progress")
+    cancel_in_flight()
+    server.Close()
+    return nil
+  }
+  cancel_in_flight()
+  log.Ok_msg("Keedio's Cloudera Exporter stopped")
+  return nil
+}
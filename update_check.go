@@ -0,0 +1,201 @@
+/*
+ *
+ * title           :update_check.go
+ * description     :Periodic check of the latest release of the exporter,
+ *                  so the outdated instances can be tracked from Prometheus
+ * author          :Enes Erdoğan
+ * date            :2025/10/13
+ * version         :1.0
+ *
+ */
+package main
+
+
+
+
+/* ======================================================================
+ * Dependencies and libraries
+ * ====================================================================== */
+import (
+  // Go Default libraries
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  // Own libraries
+  cp "keedio/cloudera_exporter/config_parser"
+  log "keedio/cloudera_exporter/logger"
+
+  // Go Prometheus libraries
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/common/version"
+)
+
+
+
+
+/* ======================================================================
+ * Constants
+ * ====================================================================== */
+// Size limit of the release metadata
+const UPDATE_CHECK_MAX_BODY = 1 << 20
+
+
+
+
+/* ======================================================================
+ * Data Structs
+ * ====================================================================== */
+// Fields of the release metadata with the latest version: version, or
+// tag_name in the releases of the GitHub API
+type release_metadata struct {
+  Version string `json:"version"`
+  Tag_name string `json:"tag_name"`
+}
+
+
+
+
+/* ======================================================================
+ * Global variables
+ * ====================================================================== */
+var (
+  update_available = prometheus.NewGaugeVec(prometheus.GaugeOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "update_available",
+    Help:      "Whether a release newer than the running exporter is available (1) or not (0), with the latest release version.",
+  }, []string{"latest_version"})
+  update_checks_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "update_checks_total",
+    Help:      "Total number of checks of the latest release of the exporter.",
+  })
+  update_check_errors_total = prometheus.NewCounter(prometheus.CounterOpts {
+    Namespace: "kbdi",
+    Subsystem: "exporter",
+    Name:      "update_check_errors_total",
+    Help:      "Total number of checks of the latest release of the exporter that failed.",
+  })
+)
+
+
+
+
+/* ======================================================================
+ * Functions
+ * ====================================================================== */
+// Returns the numeric parts of the version (v1.3.2-rc1 is 1, 3, 2). The
+// parts that are not a number count as 0
+func version_parts(v string) []int {
+  v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+  if end := strings.IndexAny(v, "-+"); end >= 0 {
+    v = v[:end]
+  }
+  parts := []int{}
+  for _, field := range strings.Split(v, ".") {
+    part, _ := strconv.Atoi(field)
+    parts = append(parts, part)
+  }
+  return parts
+}
+
+
+// Returns true if the version a is newer than b. The missing parts count as
+// 0, so 1.3 and 1.3.0 are the same version
+func is_newer_version(a string, b string) bool {
+  parts_a, parts_b := version_parts(a), version_parts(b)
+  for index := 0; index < len(parts_a) || index < len(parts_b); index++ {
+    part_a, part_b := 0, 0
+    if index < len(parts_a) {
+      part_a = parts_a[index]
+    }
+    if index < len(parts_b) {
+      part_b = parts_b[index]
+    }
+    if part_a != part_b {
+      return part_a > part_b
+    }
+  }
+  return false
+}
+
+
+// Returns the latest version in the release metadata of the URL
+func fetch_latest_version(client *http.Client, options cp.Update_check_options) (string, error) {
+  ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+  defer cancel()
+  req, err := http.NewRequest(http.MethodGet, options.Url, nil)
+  if err != nil {
+    return "", err
+  }
+  req.Header.Set("Accept", "application/json")
+  res, err := client.Do(req.WithContext(ctx))
+  if err != nil {
+    return "", err
+  }
+  defer res.Body.Close()
+  if res.StatusCode != http.StatusOK {
+    return "", fmt.Errorf("unexpected status %s", res.Status)
+  }
+  body, err := ioutil.ReadAll(http.MaxBytesReader(nil, res.Body, UPDATE_CHECK_MAX_BODY))
+  if err != nil {
+    return "", err
+  }
+
+  var metadata release_metadata
+  if err := json.Unmarshal(body, &metadata); err != nil {
+    return "", err
+  }
+  latest := metadata.Version
+  if latest == "" {
+    latest = metadata.Tag_name
+  }
+  if latest == "" {
+    return "", errors.New("no version or tag_name field in the release metadata")
+  }
+  return strings.TrimPrefix(latest, "v"), nil
+}
+
+
+// Check the latest release and update the metric. The last result is kept
+// if the check fails
+func check_update(client *http.Client, options cp.Update_check_options) {
+  update_checks_total.Inc()
+  latest, err := fetch_latest_version(client, options)
+  if err != nil {
+    log.Warn_msg("Failed to check the latest release of the exporter at %s: %s", options.Url, err)
+    update_check_errors_total.Inc()
+    return
+  }
+
+  available := 0.0
+  if is_newer_version(latest, version.Version) {
+    available = 1
+    log.Info_msg("Exporter release %s available (running %s)", latest, version.Version)
+  }
+  update_available.Reset()
+  update_available.WithLabelValues(latest).Set(available)
+}
+
+
+// Check the latest release on every interval
+func update_check_loop(options cp.Update_check_options) {
+  prometheus.MustRegister(update_available, update_checks_total, update_check_errors_total)
+  client := &http.Client{}
+
+  log.Info_msg("Checking the latest release of the exporter at %s every %s", options.Url, options.Interval)
+  ticker := time.NewTicker(options.Interval)
+  defer ticker.Stop()
+  for {
+    check_update(client, options)
+    <-ticker.C
+  }
+}